@@ -21,4 +21,18 @@ const (
 	Prefix = "spiffe.csi.cert-manager.io"
 
 	SPIFFEIdentityAnnnotationKey = "spiffe.csi.cert-manager.io/identity"
+
+	// PodNameAnnotationKey is set by the driver to the name of the mounting
+	// pod, so that the approver can look up the pod to verify the requested
+	// identity.
+	PodNameAnnotationKey = "spiffe.csi.cert-manager.io/pod-name"
+
+	// PodUIDAnnotationKey is set by the driver to the UID of the mounting pod,
+	// so that the approver can verify the pod the request was made for.
+	PodUIDAnnotationKey = "spiffe.csi.cert-manager.io/pod-uid"
+
+	// NodeNameAnnotationKey is set by the driver to the name of the node it
+	// runs on, so that the approver can verify the mounting pod is scheduled
+	// on the node of the driver which made the request.
+	NodeNameAnnotationKey = "spiffe.csi.cert-manager.io/node-name"
 )
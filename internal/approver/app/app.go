@@ -18,12 +18,15 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/cert-manager/cert-manager/pkg/api"
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/scale/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -34,7 +37,13 @@ import (
 	"github.com/cert-manager/csi-driver-spiffe/internal/approver/app/options"
 	"github.com/cert-manager/csi-driver-spiffe/internal/approver/controller"
 	"github.com/cert-manager/csi-driver-spiffe/internal/approver/evaluator"
+	"github.com/cert-manager/csi-driver-spiffe/internal/bundleendpoint"
+	"github.com/cert-manager/csi-driver-spiffe/internal/clustertrustbundle"
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/jwtsvid"
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/runtimeconfig"
+	"github.com/cert-manager/csi-driver-spiffe/internal/identity"
+	"github.com/cert-manager/csi-driver-spiffe/internal/keyalgorithm"
 )
 
 const (
@@ -46,6 +55,7 @@ var intscheme = runtime.NewScheme()
 func init() {
 	utilruntime.Must(scheme.AddToScheme(intscheme))
 	utilruntime.Must(api.AddToScheme(intscheme))
+	utilruntime.Must(certificatesv1beta1.AddToScheme(intscheme))
 }
 
 // NewCommand returns an new command instance of the approver component of csi-driver-spiffe.
@@ -86,6 +96,28 @@ func NewCommand(ctx context.Context) *cobra.Command {
 				return err
 			}
 
+			spiffeIDTemplate, err := identity.Parse(opts.CertManager.SPIFFEIDPathTemplate, opts.CertManager.SPIFFEIDPodLabels)
+			if err != nil {
+				return err
+			}
+
+			allowedKeyAlgorithms, err := keyalgorithm.ParseMinimumSizes(opts.CertManager.AllowedKeyAlgorithms)
+			if err != nil {
+				return fmt.Errorf("invalid --allowed-key-algorithms: %w", err)
+			}
+
+			minimumDuration := opts.CertManager.MinimumCertificateRequestDuration
+			if minimumDuration == 0 {
+				minimumDuration = opts.CertManager.CertificateRequestDuration
+			}
+			maximumDuration := opts.CertManager.MaximumCertificateRequestDuration
+			if maximumDuration == 0 {
+				maximumDuration = opts.CertManager.CertificateRequestDuration
+			}
+			if minimumDuration > maximumDuration {
+				return fmt.Errorf("minimum certificate request duration %s is longer than the maximum %s", minimumDuration, maximumDuration)
+			}
+
 			if opts.CertManager.AutoApproveNonSPIFFE {
 				log.Info("auto-approval of non-SPIFFE CertificateRequests enabled: this approver will approve all CertificateRequests not targeting the configured SPIFFE issuer")
 			}
@@ -112,10 +144,15 @@ func NewCommand(ctx context.Context) *cobra.Command {
 			}
 
 			evaluator := evaluator.New(evaluator.Options{
-				TrustDomain:                opts.CertManager.TrustDomain,
-				CertificateRequestDuration: opts.CertManager.CertificateRequestDuration,
-				UseOwnServiceAccount:       opts.CertManager.UseOwnServiceAccount,
-				DriverServiceAccount:       opts.CertManager.DriverServiceAccount,
+				TrustDomain:                       opts.CertManager.TrustDomain,
+				MinimumCertificateRequestDuration: minimumDuration,
+				MaximumCertificateRequestDuration: maximumDuration,
+				UseOwnServiceAccount:              opts.CertManager.UseOwnServiceAccount,
+				DriverServiceAccount:              opts.CertManager.DriverServiceAccount,
+				SPIFFEIDTemplate:                  spiffeIDTemplate,
+				ClusterName:                       opts.CertManager.ClusterName,
+				PodReader:                         mgr.GetAPIReader(),
+				AllowedKeyAlgorithms:              allowedKeyAlgorithms,
 			})
 
 			if err := controller.AddApprover(ctx, opts.Logr, controller.Options{
@@ -123,10 +160,109 @@ func NewCommand(ctx context.Context) *cobra.Command {
 				Manager:              mgr,
 				RuntimeConfig:        rtConfig,
 				AutoApproveNonSPIFFE: opts.CertManager.AutoApproveNonSPIFFE,
+				ServiceAccountEvents: opts.Controller.ServiceAccountEvents,
+				UseOwnServiceAccount: opts.CertManager.UseOwnServiceAccount,
 			}); err != nil {
 				return fmt.Errorf("failed to register approver controller: %w", err)
 			}
 
+			// rootCAsFiles caches the root CAs by file path, so that each file
+			// is only watched once.
+			rootCAsFiles := make(map[string]rootca.Interface)
+			rootCAsFromFile := func(filepath string) (rootca.Interface, error) {
+				if rootCAs, ok := rootCAsFiles[filepath]; ok {
+					return rootCAs, nil
+				}
+				rootCAs, err := rootca.NewFile(ctx, opts.Logr, filepath, rootca.SanitizeOptions{DropExpired: opts.CertManager.DropExpiredRootCAs})
+				if err != nil {
+					return nil, fmt.Errorf("failed to build root CA: %w", err)
+				}
+				rootCAsFiles[filepath] = rootCAs
+				return rootCAs, nil
+			}
+
+			if len(opts.BundleEndpoint.Address) > 0 {
+				if len(opts.BundleEndpoint.SourceCABundleFile) == 0 {
+					return errors.New("--bundle-endpoint-source-ca-bundle is required to serve the bundle endpoint")
+				}
+
+				rootCAs, err := rootCAsFromFile(opts.BundleEndpoint.SourceCABundleFile)
+				if err != nil {
+					return err
+				}
+
+				var jwtAuthorities bundleendpoint.JWTAuthorities
+				if len(opts.BundleEndpoint.JWTSigningKeySecretName) > 0 {
+					secretClient, err := client.NewWithWatch(opts.RestConfig, client.Options{})
+					if err != nil {
+						return fmt.Errorf("failed to build kubernetes watcher client: %w", err)
+					}
+
+					// Only the public keys of the issuer's bundle are used.
+					jwtAuthorities, err = jwtsvid.NewFromSecret(ctx, secretClient, opts.CertManager.TrustDomain, types.NamespacedName{
+						Name:      opts.BundleEndpoint.JWTSigningKeySecretName,
+						Namespace: opts.BundleEndpoint.JWTSigningKeySecretNamespace,
+					}, opts.BundleEndpoint.JWTSigningKeySecretKey)
+					if err != nil {
+						return fmt.Errorf("failed to build JWT signing key watcher: %w", err)
+					}
+				}
+
+				bundleEndpoint, err := bundleendpoint.New(opts.Logr, bundleendpoint.Options{
+					TrustDomain:     opts.CertManager.TrustDomain,
+					RootCAs:         rootCAs,
+					JWTAuthorities:  jwtAuthorities,
+					Address:         opts.BundleEndpoint.Address,
+					Profile:         rootca.Profile(opts.BundleEndpoint.Profile),
+					CertificateFile: opts.BundleEndpoint.CertificateFile,
+					KeyFile:         opts.BundleEndpoint.KeyFile,
+					RefreshHint:     opts.BundleEndpoint.RefreshHint,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to build bundle endpoint: %w", err)
+				}
+
+				if err := mgr.Add(bundleEndpoint); err != nil {
+					return fmt.Errorf("failed to register bundle endpoint: %w", err)
+				}
+			}
+
+			if opts.ClusterTrustBundle.Publish {
+				sourceCABundleFile := opts.ClusterTrustBundle.SourceCABundleFile
+				if len(sourceCABundleFile) == 0 {
+					sourceCABundleFile = opts.BundleEndpoint.SourceCABundleFile
+				}
+				if len(sourceCABundleFile) == 0 {
+					return errors.New("--cluster-trust-bundle-source-ca-bundle is required to publish a ClusterTrustBundle")
+				}
+
+				rootCAs, err := rootCAsFromFile(sourceCABundleFile)
+				if err != nil {
+					return err
+				}
+
+				// Use an uncached client so that the ClusterTrustBundle is read
+				// straight from the API server.
+				ctbClient, err := client.New(opts.RestConfig, client.Options{Scheme: intscheme})
+				if err != nil {
+					return fmt.Errorf("failed to build kubernetes client: %w", err)
+				}
+
+				publisher, err := clustertrustbundle.New(opts.Logr, clustertrustbundle.Options{
+					DriverName:  opts.DriverName,
+					TrustDomain: opts.CertManager.TrustDomain,
+					RootCAs:     rootCAs,
+					Client:      ctbClient,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to build ClusterTrustBundle publisher: %w", err)
+				}
+
+				if err := mgr.Add(publisher); err != nil {
+					return fmt.Errorf("failed to register ClusterTrustBundle publisher: %w", err)
+				}
+			}
+
 			log.Info("starting SPIFFE approver...")
 
 			return mgr.Start(ctx)
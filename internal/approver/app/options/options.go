@@ -23,6 +23,7 @@ import (
 	"github.com/spf13/pflag"
 
 	"github.com/cert-manager/csi-driver-spiffe/internal/flags"
+	"github.com/cert-manager/csi-driver-spiffe/internal/identity"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
@@ -37,6 +38,60 @@ type Options struct {
 
 	// Controller are options specific to the controller.
 	Controller OptionsController
+
+	// BundleEndpoint are options specific to the SPIFFE bundle endpoint.
+	BundleEndpoint OptionsBundleEndpoint
+
+	// ClusterTrustBundle are options specific to publishing the trust domain
+	// bundle as a ClusterTrustBundle.
+	ClusterTrustBundle OptionsClusterTrustBundle
+}
+
+// OptionsClusterTrustBundle are options specific to publishing the trust
+// domain bundle as a ClusterTrustBundle.
+type OptionsClusterTrustBundle struct {
+	// Publish enables keeping a ClusterTrustBundle in sync with the root CAs.
+	Publish bool
+
+	// SourceCABundleFile is the file path location containing a bundle of PEM
+	// encoded X.509 root CA certificates of the trust domain which is
+	// published. Defaults to the bundle endpoint source if empty.
+	SourceCABundleFile string
+}
+
+// OptionsBundleEndpoint are options specific to the SPIFFE bundle endpoint
+// serving the trust domain bundle to federated trust domains.
+type OptionsBundleEndpoint struct {
+	// Address is the TCP address the bundle endpoint listens on. The bundle
+	// endpoint is not served if empty.
+	Address string
+
+	// SourceCABundleFile is the file path location containing a bundle of PEM
+	// encoded X.509 root CA certificates of the trust domain which is served.
+	SourceCABundleFile string
+
+	// Profile is the bundle endpoint profile, https_web or https_spiffe.
+	Profile string
+
+	// CertificateFile, KeyFile are the file paths of the serving certificate
+	// and private key.
+	CertificateFile, KeyFile string
+
+	// RefreshHint is the refresh hint set on the served bundle.
+	RefreshHint time.Duration
+
+	// JWTSigningKeySecretName is the name of the Secret containing the
+	// JWT-SVID signing key of the trust domain, whose public keys are served
+	// in the bundle. No JWT authorities are served if empty.
+	JWTSigningKeySecretName string
+
+	// JWTSigningKeySecretNamespace is the namespace of the JWT-SVID signing
+	// key Secret.
+	JWTSigningKeySecretNamespace string
+
+	// JWTSigningKeySecretKey is the key in the Secret data holding the PEM
+	// encoded JWT-SVID signing key.
+	JWTSigningKeySecretKey string
 }
 
 // OptionsController are options specific to the Kubernetes controller.
@@ -53,6 +108,10 @@ type OptionsController struct {
 	// LeaderElectionNamespace is the namespace that the approver controller will
 	// lease election in.
 	LeaderElectionNamespace string
+
+	// ServiceAccountEvents enables recording approval decision Events on the
+	// ServiceAccount which requested the CertificateRequest.
+	ServiceAccountEvents bool
 }
 
 // OptionsCertManager are options specific to cert-manager and the evaluator.
@@ -66,10 +125,33 @@ type OptionsCertManager struct {
 	// TrustDomain is the Trust Domain the evaluator will enforce requests request for.
 	TrustDomain string
 
+	// SPIFFEIDPathTemplate is the template of the SPIFFE ID path the evaluator
+	// will enforce requests request for. Must match the driver's template.
+	SPIFFEIDPathTemplate string
+
+	// SPIFFEIDPodLabels are the pod label keys which may be referenced in
+	// SPIFFEIDPathTemplate.
+	SPIFFEIDPodLabels []string
+
+	// ClusterName is the name of the cluster which may be referenced in
+	// SPIFFEIDPathTemplate.
+	ClusterName string
+
 	// CertificateRequestDuration is the duration the evaluator will enforce
-	// CertificateRequest request for.
+	// CertificateRequest request for, when no minimum or maximum duration is
+	// given.
 	CertificateRequestDuration time.Duration
 
+	// MinimumCertificateRequestDuration is the shortest duration the evaluator
+	// will allow CertificateRequests to request for. Defaults to
+	// CertificateRequestDuration if zero.
+	MinimumCertificateRequestDuration time.Duration
+
+	// MaximumCertificateRequestDuration is the longest duration the evaluator
+	// will allow CertificateRequests to request for. Defaults to
+	// CertificateRequestDuration if zero.
+	MaximumCertificateRequestDuration time.Duration
+
 	// UseOwnServiceAccount, when true, changes the approval validation strategy.
 	// Instead of verifying that the SPIFFE identity in the CSR matches the
 	// requesting pod's ServiceAccount, the approver verifies that the requester
@@ -81,9 +163,18 @@ type OptionsCertManager struct {
 	// Only used when UseOwnServiceAccount is true.
 	DriverServiceAccount string
 
+	// AllowedKeyAlgorithms maps each private key algorithm the evaluator will
+	// allow to its minimum key size in bits.
+	AllowedKeyAlgorithms map[string]int
+
 	// IssuerRef is the IssuerRef used when creating CertificateRequests.
 	IssuerRef cmmeta.IssuerReference
 
+	// DropExpiredRootCAs drops expired certificates from the root CAs which
+	// are served by the bundle endpoint and published as a
+	// ClusterTrustBundle.
+	DropExpiredRootCAs bool
+
 	// AutoApproveNonSPIFFE enables the auto approval of non csi-driver-spiffe CertificateRequest resources. This allows
 	// csi-driver-spiffe to act as a drop in replacement for the cert-manager approval controller.
 	AutoApproveNonSPIFFE bool
@@ -93,7 +184,9 @@ func New() *Options {
 	o := new(Options)
 	o.Flags = flags.New().
 		Add("cert-manager", o.addCertManagerFlags).
-		Add("Controller", o.addControllerFlags)
+		Add("Controller", o.addControllerFlags).
+		Add("Bundle endpoint", o.addBundleEndpointFlags).
+		Add("ClusterTrustBundle", o.addClusterTrustBundleFlags)
 	return o
 }
 
@@ -107,13 +200,41 @@ func (o *Options) addCertManagerFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.CertManager.TrustDomain, "trust-domain", "cluster.local",
 		"The trust domain this approver ensures is present on requests.")
 
+	fs.StringVar(&o.CertManager.SPIFFEIDPathTemplate, "spiffe-id-path-template", identity.DefaultPathTemplate,
+		"Template of the SPIFFE ID path this approver ensures is present on requests. "+
+			"Must match the template configured on the driver. Supports the placeholders "+
+			"{trust-domain}, {namespace}, {service-account}, {cluster-name} and {pod-label:<key>}.")
+
+	fs.StringSliceVar(&o.CertManager.SPIFFEIDPodLabels, "spiffe-id-pod-labels", nil,
+		"List of pod label keys which may be referenced in --spiffe-id-path-template. "+
+			"Only list labels which cannot be set by untrusted users of a ServiceAccount.")
+
+	fs.StringVar(&o.CertManager.ClusterName, "cluster-name", "",
+		"Name of the cluster, substituted for {cluster-name} in --spiffe-id-path-template.")
+
 	fs.DurationVar(&o.CertManager.CertificateRequestDuration, "certificate-request-duration", time.Hour,
-		"The duration which is enforced for requests to have.")
+		"The duration which is enforced for requests to have. Used as the minimum and/or maximum "+
+			"duration when --minimum-certificate-request-duration or --maximum-certificate-request-duration are not set.")
+
+	fs.DurationVar(&o.CertManager.MinimumCertificateRequestDuration, "minimum-certificate-request-duration", 0,
+		"The shortest duration which requests are allowed to have. Volumes may request a duration with the "+
+			"\"spiffe.csi.cert-manager.io/certificate-duration\" attribute. Defaults to --certificate-request-duration.")
+
+	fs.DurationVar(&o.CertManager.MaximumCertificateRequestDuration, "maximum-certificate-request-duration", 0,
+		"The longest duration which requests are allowed to have. Volumes may request a duration with the "+
+			"\"spiffe.csi.cert-manager.io/certificate-duration\" attribute. Defaults to --certificate-request-duration.")
+
+	fs.StringToIntVar(&o.CertManager.AllowedKeyAlgorithms, "allowed-key-algorithms",
+		map[string]int{"ECDSA": 256, "RSA": 2048, "Ed25519": 256},
+		"Comma-separated list of the private key algorithms which are allowed on requests, "+
+			"mapped to their minimum key size in bits e.g. '--allowed-key-algorithms=ECDSA=384,RSA=3072'. "+
+			"Supported algorithms are ECDSA, RSA and Ed25519.")
 
 	fs.BoolVar(&o.CertManager.UseOwnServiceAccount, "use-own-service-account", false,
 		"When true, the approver validates that CertificateRequests are made by the "+
 			"driver's own ServiceAccount (--driver-service-account) rather than by the "+
-			"mounting pod's ServiceAccount.")
+			"mounting pod's ServiceAccount. The mounting pod must then exist, be scheduled on "+
+			"the requesting driver's node and match the requested SPIFFE ID.")
 
 	fs.StringVar(&o.CertManager.DriverServiceAccount, "driver-service-account", "",
 		"Full Kubernetes username of the CSI driver's ServiceAccount "+
@@ -131,6 +252,10 @@ func (o *Options) addCertManagerFlags(fs *pflag.FlagSet) {
 
 	fs.BoolVar(&o.CertManager.AutoApproveNonSPIFFE, "auto-approve-non-spiffe", false,
 		"Enables the auto approval of non csi-driver-spiffe CertificateRequest resources. This allows csi-driver-spiffe to act as a drop in replacement for the cert-manager approval controller.")
+
+	fs.BoolVar(&o.CertManager.DropExpiredRootCAs, "drop-expired-root-cas", false,
+		"Drop expired certificates from the root CA certificates which are served by the bundle "+
+			"endpoint and published as a ClusterTrustBundle.")
 }
 
 func (o *Options) addControllerFlags(fs *pflag.FlagSet) {
@@ -145,4 +270,59 @@ func (o *Options) addControllerFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Controller.MetricsAddress, "metrics-bind-address", ":9402",
 		"TCP address for exposing HTTP Prometheus metrics which will be served on the "+
 			"HTTP path '/metrics'. The value \"0\" will disable exposing metrics.")
+
+	fs.BoolVar(&o.Controller.ServiceAccountEvents, "service-account-events", false,
+		"Record approval decision Events on the ServiceAccount which requested the "+
+			"CertificateRequest, in addition to the CertificateRequest itself. With "+
+			"--use-own-service-account, Events are recorded on the mounting pod's "+
+			"ServiceAccount instead. Requires permission to get ServiceAccounts.")
+}
+
+func (o *Options) addBundleEndpointFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.BundleEndpoint.Address, "bundle-endpoint-bind-address", "",
+		"TCP address for serving the trust domain bundle as a SPIFFE bundle endpoint, for "+
+			"federated trust domains to fetch. If undefined, the bundle endpoint is not served.")
+
+	fs.StringVar(&o.BundleEndpoint.SourceCABundleFile, "bundle-endpoint-source-ca-bundle", "",
+		"File path of the PEM encoded root CA certificates of the trust domain, served by the "+
+			"bundle endpoint. Required when --bundle-endpoint-bind-address is set.")
+
+	fs.StringVar(&o.BundleEndpoint.Profile, "bundle-endpoint-profile", "https_web",
+		"Profile the bundle endpoint is served with, either https_web or https_spiffe. With "+
+			"https_spiffe the serving certificate must be an X509-SVID issued by the served bundle.")
+
+	fs.StringVar(&o.BundleEndpoint.CertificateFile, "bundle-endpoint-certificate-file", "",
+		"File path of the PEM encoded serving certificate chain of the bundle endpoint. "+
+			"Reloaded when changed.")
+
+	fs.StringVar(&o.BundleEndpoint.KeyFile, "bundle-endpoint-key-file", "",
+		"File path of the PEM encoded private key of the bundle endpoint serving certificate. "+
+			"Reloaded when changed.")
+
+	fs.DurationVar(&o.BundleEndpoint.RefreshHint, "bundle-endpoint-refresh-hint", time.Minute*5,
+		"Refresh hint set on the served bundle, telling federated trust domains how often to "+
+			"fetch it.")
+
+	fs.StringVar(&o.BundleEndpoint.JWTSigningKeySecretName, "bundle-endpoint-jwt-signing-key-secret-name", "",
+		"Name of the Secret containing the JWT-SVID signing key of the trust domain, as given to "+
+			"the driver with --jwt-signing-key-secret-name. The public keys are served in the "+
+			"bundle alongside the root CA certificates, so that JWT-SVIDs can be verified with it. "+
+			"If undefined, no JWT authorities are served.")
+
+	fs.StringVar(&o.BundleEndpoint.JWTSigningKeySecretNamespace, "bundle-endpoint-jwt-signing-key-secret-namespace", "",
+		"Namespace of the Secret containing the JWT-SVID signing key.")
+
+	fs.StringVar(&o.BundleEndpoint.JWTSigningKeySecretKey, "bundle-endpoint-jwt-signing-key-secret-key", "tls.key",
+		"Key in the data of the Secret which contains the JWT-SVID signing key.")
+}
+
+func (o *Options) addClusterTrustBundleFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.ClusterTrustBundle.Publish, "publish-cluster-trust-bundle", false,
+		"Keep a ClusterTrustBundle in sync with the root CA certificates of the trust domain, "+
+			"with the signer name \"<csi-driver-name>/<trust-domain>\". Requires permission to "+
+			"attest for the signer name.")
+
+	fs.StringVar(&o.ClusterTrustBundle.SourceCABundleFile, "cluster-trust-bundle-source-ca-bundle", "",
+		"File path of the PEM encoded root CA certificates of the trust domain, published in "+
+			"the ClusterTrustBundle. Defaults to --bundle-endpoint-source-ca-bundle.")
 }
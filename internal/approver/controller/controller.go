@@ -18,17 +18,23 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 
 	apiutil "github.com/cert-manager/cert-manager/pkg/api/util"
 	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	"github.com/cert-manager/csi-driver-spiffe/internal/annotations"
@@ -36,6 +42,19 @@ import (
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/runtimeconfig"
 )
 
+const (
+	// denialReasonSPIFFEURISAN is the denial reason of a non-SPIFFE request
+	// which contains a SPIFFE URI SAN.
+	denialReasonSPIFFEURISAN = "SPIFFEURISAN"
+
+	// denialReasonSPIFFEIssuer is the denial reason of a non-SPIFFE request
+	// which targets the SPIFFE issuer.
+	denialReasonSPIFFEIssuer = "SPIFFEIssuer"
+
+	// eventReasonApproved is the Event reason of an approved request.
+	eventReasonApproved = "Approved"
+)
+
 type Options struct {
 	// Evaluator will be used to evaluate whether CertificateRequests should be
 	// Approved or Denied.
@@ -52,6 +71,16 @@ type Options struct {
 	// AutoApproveNonSPIFFE enables the auto approval of non csi-driver-spiffe CertificateRequest resources. This allows
 	// csi-driver-spiffe to act as a drop in replacement for the cert-manager approval controller.
 	AutoApproveNonSPIFFE bool
+
+	// ServiceAccountEvents enables recording approval decision Events on the
+	// ServiceAccount which requested the CertificateRequest, in addition to
+	// the CertificateRequest itself.
+	ServiceAccountEvents bool
+
+	// UseOwnServiceAccount is true when the driver requests
+	// CertificateRequests with its own ServiceAccount, in which case Events
+	// are recorded on the ServiceAccount of the mounting pod instead.
+	UseOwnServiceAccount bool
 }
 
 // approver watches for CertificateRequests which have been created by the
@@ -78,6 +107,21 @@ type approver struct {
 	// autoApproveNonSPIFFE enables the auto approval of non csi-driver-spiffe CertificateRequest resources. This allows
 	// csi-driver-spiffe to act as a drop in replacement for the cert-manager approval controller.
 	autoApproveNonSPIFFE bool
+
+	// recorder records Events for approval decisions.
+	recorder events.EventRecorder
+
+	// apiReader makes uncached requests to the Kubernetes API, used to look
+	// up ServiceAccounts for recording Events.
+	apiReader client.Reader
+
+	// serviceAccountEvents enables recording approval decision Events on the
+	// requesting ServiceAccount.
+	serviceAccountEvents bool
+
+	// useOwnServiceAccount is true when CertificateRequests are requested by
+	// the driver's own ServiceAccount, rather than the mounting pod's.
+	useOwnServiceAccount bool
 }
 
 // AddApprover will register the approver controller.
@@ -89,6 +133,16 @@ func AddApprover(ctx context.Context, log logr.Logger, opts Options) error {
 		evaluator:            opts.Evaluator,
 		runtimeConfig:        opts.RuntimeConfig,
 		autoApproveNonSPIFFE: opts.AutoApproveNonSPIFFE,
+		recorder:             opts.Manager.GetEventRecorder("csi-driver-spiffe-approver"),
+		apiReader:            opts.Manager.GetAPIReader(),
+		serviceAccountEvents: opts.ServiceAccountEvents,
+		useOwnServiceAccount: opts.UseOwnServiceAccount,
+	}
+
+	if opts.RuntimeConfig != nil {
+		if err := metrics.Registry.Register(&runtimeIssuerCollector{runtimeConfig: opts.RuntimeConfig}); err != nil {
+			return fmt.Errorf("failed to register runtime issuer metrics: %w", err)
+		}
 	}
 
 	return ctrl.NewControllerManagedBy(opts.Manager).
@@ -140,15 +194,20 @@ func (a *approver) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 
 	// If the annotation is set we use the normal evaluation flow
 	if _, annotationExists := cr.Annotations[annotations.SPIFFEIdentityAnnnotationKey]; annotationExists {
-		if err := a.evaluator.Evaluate(&cr); err != nil {
+		if err := a.evaluator.Evaluate(ctx, &cr); err != nil {
+			// Errors without a reason are failures to evaluate the request,
+			// such as the API server being unavailable, so are retried
+			// rather than denied.
+			if evaluator.Reason(err) == evaluator.DenialReasonUnknown {
+				return ctrl.Result{}, fmt.Errorf("failed to evaluate request: %w", err)
+			}
+
 			log.Error(err, "denying request")
-			apiutil.SetCertificateRequestCondition(&cr, cmapi.CertificateRequestConditionDenied, cmmeta.ConditionTrue, "spiffe.csi.cert-manager.io", "Denied request: "+err.Error())
-			return ctrl.Result{}, a.client.Status().Update(ctx, &cr)
+			return ctrl.Result{}, a.deny(ctx, &cr, requestTypeSPIFFE, string(evaluator.Reason(err)), "Denied request: "+err.Error())
 		}
 
 		log.Info("approving request")
-		apiutil.SetCertificateRequestCondition(&cr, cmapi.CertificateRequestConditionApproved, cmmeta.ConditionTrue, "spiffe.csi.cert-manager.io", "Approved request")
-		return ctrl.Result{}, a.client.Status().Update(ctx, &cr)
+		return ctrl.Result{}, a.approve(ctx, &cr, requestTypeSPIFFE)
 	}
 
 	// Deny unannotated requests that contain SPIFFE URI SANs to prevent
@@ -157,8 +216,7 @@ func (a *approver) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 		for _, uri := range csr.URIs {
 			if uri.Scheme == "spiffe" {
 				log.Info("denying request: non-SPIFFE certificate request contains SPIFFE URI SAN")
-				apiutil.SetCertificateRequestCondition(&cr, cmapi.CertificateRequestConditionDenied, cmmeta.ConditionTrue, "spiffe.csi.cert-manager.io", "Denied request: non-SPIFFE certificate request contains SPIFFE URI SAN")
-				return ctrl.Result{}, a.client.Status().Update(ctx, &cr)
+				return ctrl.Result{}, a.deny(ctx, &cr, requestTypeNonSPIFFE, denialReasonSPIFFEURISAN, "Denied request: non-SPIFFE certificate request contains SPIFFE URI SAN")
 			}
 		}
 	}
@@ -167,12 +225,131 @@ func (a *approver) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 	// obtaining a SPIFFE certificate outside of the normal validation path.
 	if cr.Spec.IssuerRef == a.runtimeConfig.Config().IssuerRef {
 		log.Info("denying request: non-SPIFFE certificate targeting configured SPIFFE issuer")
-		apiutil.SetCertificateRequestCondition(&cr, cmapi.CertificateRequestConditionDenied, cmmeta.ConditionTrue, "spiffe.csi.cert-manager.io", "Denied request: non-SPIFFE certificate targeting configured SPIFFE issuer")
-		return ctrl.Result{}, a.client.Status().Update(ctx, &cr)
+		return ctrl.Result{}, a.deny(ctx, &cr, requestTypeNonSPIFFE, denialReasonSPIFFEIssuer, "Denied request: non-SPIFFE certificate targeting configured SPIFFE issuer")
 	}
 
 	// Request is not for the spiffe issuer, auto approve
 	log.Info("approving request")
-	apiutil.SetCertificateRequestCondition(&cr, cmapi.CertificateRequestConditionApproved, cmmeta.ConditionTrue, "spiffe.csi.cert-manager.io", "Approved request")
-	return ctrl.Result{}, a.client.Status().Update(ctx, &cr)
+	return ctrl.Result{}, a.approve(ctx, &cr, requestTypeNonSPIFFE)
+}
+
+// approve sets the Approved condition on the CertificateRequest, and records
+// the decision in Events and metrics.
+func (a *approver) approve(ctx context.Context, cr *cmapi.CertificateRequest, requestType string) error {
+	apiutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionApproved, cmmeta.ConditionTrue, "spiffe.csi.cert-manager.io", "Approved request")
+	if err := a.client.Status().Update(ctx, cr); err != nil {
+		return err
+	}
+
+	a.recordEvent(ctx, cr, corev1.EventTypeNormal, eventReasonApproved, "Approve", "Approved request")
+
+	approvedRequests.WithLabelValues(requestType).Inc()
+	observeDecision(cr, requestType, decisionApproved)
+
+	return nil
+}
+
+// deny sets the Denied condition on the CertificateRequest with the given
+// reason and message, and records the decision with its reason in Events and
+// metrics.
+func (a *approver) deny(ctx context.Context, cr *cmapi.CertificateRequest, requestType, reason, message string) error {
+	apiutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionDenied, cmmeta.ConditionTrue, reason, message)
+	if err := a.client.Status().Update(ctx, cr); err != nil {
+		return err
+	}
+
+	a.recordEvent(ctx, cr, corev1.EventTypeWarning, reason, "Deny", message)
+
+	deniedRequests.WithLabelValues(requestType, reason).Inc()
+	observeDecision(cr, requestType, decisionDenied)
+
+	return nil
+}
+
+// observeDecision records the time from the CertificateRequest being created
+// to the decision.
+func observeDecision(cr *cmapi.CertificateRequest, requestType, decision string) {
+	if cr.CreationTimestamp.IsZero() {
+		return
+	}
+	decisionDuration.WithLabelValues(requestType, decision).Observe(apiutil.Clock.Since(cr.CreationTimestamp.Time).Seconds())
+}
+
+// recordEvent records an Event for a decision on the CertificateRequest and,
+// if enabled, on the ServiceAccount of the workload it was requested for.
+// Failing to look up the ServiceAccount is logged and otherwise ignored, so
+// that it never blocks a decision.
+func (a *approver) recordEvent(ctx context.Context, cr *cmapi.CertificateRequest, eventType, reason, action, message string) {
+	a.recorder.Eventf(cr, nil, eventType, reason, action, "%s", message)
+
+	if !a.serviceAccountEvents {
+		return
+	}
+
+	namespace, name, ok := a.requestingServiceAccount(ctx, cr)
+	if !ok {
+		return
+	}
+
+	// Get the ServiceAccount so that the Event references its UID, which is
+	// required for the Event to be shown when the ServiceAccount is described.
+	sa := new(metav1.PartialObjectMetadata)
+	sa.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ServiceAccount"))
+	if err := a.apiReader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, sa); err != nil {
+		a.log.Error(err, "failed to get requesting serviceaccount for event", "namespace", namespace, "name", name)
+		return
+	}
+
+	ref := &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "ServiceAccount",
+		Namespace:  sa.Namespace,
+		Name:       sa.Name,
+		UID:        sa.UID,
+	}
+	a.recorder.Eventf(ref, cr, eventType, reason, action, "CertificateRequest %s/%s: %s", cr.Namespace, cr.Name, message)
+}
+
+// requestingServiceAccount returns the namespace and name of the
+// ServiceAccount of the workload the CertificateRequest was requested for.
+// When the driver uses its own ServiceAccount, the request's username is the
+// driver's, so the ServiceAccount is that of the mounting pod instead. Returns
+// false if the ServiceAccount could not be determined.
+func (a *approver) requestingServiceAccount(ctx context.Context, cr *cmapi.CertificateRequest) (string, string, bool) {
+	if !a.useOwnServiceAccount {
+		return splitServiceAccountUsername(cr.Spec.Username)
+	}
+
+	podName := cr.Annotations[annotations.PodNameAnnotationKey]
+	if len(podName) == 0 {
+		return "", "", false
+	}
+
+	var pod corev1.Pod
+	if err := a.apiReader.Get(ctx, client.ObjectKey{Namespace: cr.Namespace, Name: podName}, &pod); err != nil {
+		a.log.Error(err, "failed to get mounting pod for event", "namespace", cr.Namespace, "name", podName)
+		return "", "", false
+	}
+
+	if podUID := cr.Annotations[annotations.PodUIDAnnotationKey]; podUID != string(pod.UID) {
+		return "", "", false
+	}
+
+	if len(pod.Spec.ServiceAccountName) == 0 {
+		return "", "", false
+	}
+
+	return pod.Namespace, pod.Spec.ServiceAccountName, true
+}
+
+// splitServiceAccountUsername returns the namespace and name of the
+// ServiceAccount of a username in the form
+// "system:serviceaccount:<namespace>:<name>". Returns false if the username
+// is not of a ServiceAccount.
+func splitServiceAccountUsername(username string) (string, string, bool) {
+	split := strings.Split(username, ":")
+	if len(split) != 4 || split[0] != "system" || split[1] != "serviceaccount" || split[2] == "" || split[3] == "" {
+		return "", "", false
+	}
+	return split[2], split[3], true
 }
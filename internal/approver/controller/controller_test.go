@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"encoding/pem"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,16 +31,21 @@ import (
 	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
 	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/klog/v2/ktesting"
 	fakeclock "k8s.io/utils/clock/testing"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/cert-manager/csi-driver-spiffe/internal/annotations"
 	"github.com/cert-manager/csi-driver-spiffe/internal/approver/evaluator"
 	"github.com/cert-manager/csi-driver-spiffe/internal/approver/evaluator/fake"
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/runtimeconfig"
@@ -90,6 +97,16 @@ func Test_Reconcile(t *testing.T) {
 		expResult            ctrl.Result
 		expError             bool
 		expObjects           []client.Object
+
+		// expApproved is the type label of the approved metric expected to
+		// be incremented, expDenied the type and reason labels of the denied
+		// metric.
+		expApproved []string
+		expDenied   []string
+
+		// expEvents are the Events expected to be recorded, in the form
+		// "<type> <reason> <note>".
+		expEvents []string
 	}{
 		"if CertificateRequest doesn't exist, ignore": {
 			existingCRObjects: []client.Object{},
@@ -107,9 +124,11 @@ func Test_Reconcile(t *testing.T) {
 			},
 			expResult: ctrl.Result{},
 			evaluator: fake.New().WithEvaluate(func(_ *cmapi.CertificateRequest) error {
-				return errors.New("this is an error")
+				return &evaluator.DenialError{Reason: evaluator.DenialReasonDurationMismatch, Details: "this is an error"}
 			}),
-			expError: false,
+			expError:  false,
+			expDenied: []string{requestTypeSPIFFE, string(evaluator.DenialReasonDurationMismatch)},
+			expEvents: []string{"Warning DurationMismatch Denied request: this is an error"},
 			expObjects: []client.Object{
 				&cmapi.CertificateRequest{
 					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cr", ResourceVersion: "11", Annotations: spiffeAnnotations},
@@ -118,7 +137,7 @@ func Test_Reconcile(t *testing.T) {
 							{
 								Type:               cmapi.CertificateRequestConditionDenied,
 								Status:             cmmeta.ConditionTrue,
-								Reason:             "spiffe.csi.cert-manager.io",
+								Reason:             string(evaluator.DenialReasonDurationMismatch),
 								Message:            "Denied request: this is an error",
 								LastTransitionTime: fixedmetatime,
 							},
@@ -127,6 +146,24 @@ func Test_Reconcile(t *testing.T) {
 				},
 			},
 		},
+		"if evaluator returns error without a reason, return error to retry": {
+			existingCRObjects: []client.Object{
+				&cmapi.CertificateRequest{
+					TypeMeta:   metav1.TypeMeta{Kind: "CertificateRequest", APIVersion: "cert-manager.io/v1"},
+					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cr", ResourceVersion: "10", Annotations: spiffeAnnotations},
+				},
+			},
+			expResult: ctrl.Result{},
+			evaluator: fake.New().WithEvaluate(func(_ *cmapi.CertificateRequest) error {
+				return errors.New("this is an error")
+			}),
+			expError: true,
+			expObjects: []client.Object{
+				&cmapi.CertificateRequest{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cr", ResourceVersion: "10", Annotations: spiffeAnnotations},
+				},
+			},
+		},
 		"if evaluator doesn't return error, update Approved": {
 			existingCRObjects: []client.Object{
 				&cmapi.CertificateRequest{
@@ -138,7 +175,9 @@ func Test_Reconcile(t *testing.T) {
 			evaluator: fake.New().WithEvaluate(func(_ *cmapi.CertificateRequest) error {
 				return nil
 			}),
-			expError: false,
+			expError:    false,
+			expApproved: []string{requestTypeSPIFFE},
+			expEvents:   []string{"Normal Approved Approved request"},
 			expObjects: []client.Object{
 				&cmapi.CertificateRequest{
 					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cr", ResourceVersion: "11", Annotations: spiffeAnnotations},
@@ -169,6 +208,8 @@ func Test_Reconcile(t *testing.T) {
 			autoApproveNonSPIFFE: true,
 			expResult:            ctrl.Result{},
 			expError:             false,
+			expApproved:          []string{requestTypeNonSPIFFE},
+			expEvents:            []string{"Normal Approved Approved request"},
 			expObjects: []client.Object{
 				&cmapi.CertificateRequest{
 					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cr", ResourceVersion: "11"},
@@ -200,6 +241,8 @@ func Test_Reconcile(t *testing.T) {
 			autoApproveNonSPIFFE: true,
 			expResult:            ctrl.Result{},
 			expError:             false,
+			expDenied:            []string{requestTypeNonSPIFFE, denialReasonSPIFFEIssuer},
+			expEvents:            []string{"Warning SPIFFEIssuer Denied request: non-SPIFFE certificate targeting configured SPIFFE issuer"},
 			expObjects: []client.Object{
 				&cmapi.CertificateRequest{
 					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cr", ResourceVersion: "11"},
@@ -209,7 +252,7 @@ func Test_Reconcile(t *testing.T) {
 							{
 								Type:               cmapi.CertificateRequestConditionDenied,
 								Status:             cmmeta.ConditionTrue,
-								Reason:             "spiffe.csi.cert-manager.io",
+								Reason:             denialReasonSPIFFEIssuer,
 								Message:            "Denied request: non-SPIFFE certificate targeting configured SPIFFE issuer",
 								LastTransitionTime: fixedmetatime,
 							},
@@ -234,6 +277,8 @@ func Test_Reconcile(t *testing.T) {
 			autoApproveNonSPIFFE: true,
 			expResult:            ctrl.Result{},
 			expError:             false,
+			expDenied:            []string{requestTypeNonSPIFFE, denialReasonSPIFFEURISAN},
+			expEvents:            []string{"Warning SPIFFEURISAN Denied request: non-SPIFFE certificate request contains SPIFFE URI SAN"},
 			expObjects: []client.Object{
 				&cmapi.CertificateRequest{
 					ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cr", ResourceVersion: "11"},
@@ -246,7 +291,7 @@ func Test_Reconcile(t *testing.T) {
 							{
 								Type:               cmapi.CertificateRequestConditionDenied,
 								Status:             cmmeta.ConditionTrue,
-								Reason:             "spiffe.csi.cert-manager.io",
+								Reason:             denialReasonSPIFFEURISAN,
 								Message:            "Denied request: non-SPIFFE certificate request contains SPIFFE URI SAN",
 								LastTransitionTime: fixedmetatime,
 							},
@@ -267,6 +312,8 @@ func Test_Reconcile(t *testing.T) {
 				WithStatusSubresource(test.existingCRObjects...).
 				Build()
 
+			recorder := events.NewFakeRecorder(len(test.expEvents) + 1)
+
 			a := &approver{
 				client:               fakeclient,
 				lister:               fakeclient,
@@ -274,12 +321,28 @@ func Test_Reconcile(t *testing.T) {
 				evaluator:            test.evaluator,
 				runtimeConfig:        test.runtimeConfig,
 				autoApproveNonSPIFFE: test.autoApproveNonSPIFFE,
+				recorder:             recorder,
+			}
+
+			var approved, denied float64
+			if test.expApproved != nil {
+				approved = testutil.ToFloat64(approvedRequests.WithLabelValues(test.expApproved...))
+			}
+			if test.expDenied != nil {
+				denied = testutil.ToFloat64(deniedRequests.WithLabelValues(test.expDenied...))
 			}
 
 			result, err := a.Reconcile(t.Context(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "test-ns", Name: "test-cr"}})
 			assert.Equalf(t, test.expError, err != nil, "%v", err)
 			assert.Equal(t, test.expResult, result)
 
+			if test.expApproved != nil {
+				assert.Equal(t, approved+1, testutil.ToFloat64(approvedRequests.WithLabelValues(test.expApproved...)))
+			}
+			if test.expDenied != nil {
+				assert.Equal(t, denied+1, testutil.ToFloat64(deniedRequests.WithLabelValues(test.expDenied...)))
+			}
+
 			for _, expObj := range test.expObjects {
 				var actual client.Object
 				switch expObj.(type) {
@@ -296,6 +359,202 @@ func Test_Reconcile(t *testing.T) {
 					t.Errorf("unexpected expected object (-want +got):\n%s", cmp.Diff(expObj, actual))
 				}
 			}
+
+			close(recorder.Events)
+			var actualEvents []string
+			for event := range recorder.Events {
+				actualEvents = append(actualEvents, event)
+			}
+			assert.Equal(t, test.expEvents, actualEvents)
+		})
+	}
+}
+
+func Test_recordEvent(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-sa", UID: "test-uid"},
+	}
+	driverSA := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "driver-sa", UID: "driver-uid"},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-pod", UID: "pod-uid"},
+		Spec:       corev1.PodSpec{ServiceAccountName: "test-sa"},
+	}
+	apiReader := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(sa, driverSA, pod).Build()
+
+	cr := &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cr"},
+		Spec:       cmapi.CertificateRequestSpec{Username: "system:serviceaccount:test-ns:test-sa"},
+	}
+
+	tests := map[string]struct {
+		serviceAccountEvents bool
+		useOwnServiceAccount bool
+		username             string
+		annotations          map[string]string
+		expEvents            []string
+	}{
+		"if ServiceAccount Events are disabled, expect an Event on the CertificateRequest only": {
+			serviceAccountEvents: false,
+			username:             "system:serviceaccount:test-ns:test-sa",
+			expEvents: []string{
+				"CertificateRequest test-ns/test-cr: Warning DurationMismatch Denied request: bad duration",
+			},
+		},
+		"if ServiceAccount Events are enabled, expect an Event on the ServiceAccount": {
+			serviceAccountEvents: true,
+			username:             "system:serviceaccount:test-ns:test-sa",
+			expEvents: []string{
+				"CertificateRequest test-ns/test-cr: Warning DurationMismatch Denied request: bad duration",
+				"ServiceAccount test-ns/test-sa (test-uid): Warning DurationMismatch CertificateRequest test-ns/test-cr: Denied request: bad duration",
+			},
+		},
+		"if requester is not a ServiceAccount, expect an Event on the CertificateRequest only": {
+			serviceAccountEvents: true,
+			username:             "test-user",
+			expEvents: []string{
+				"CertificateRequest test-ns/test-cr: Warning DurationMismatch Denied request: bad duration",
+			},
+		},
+		"if ServiceAccount doesn't exist, expect an Event on the CertificateRequest only": {
+			serviceAccountEvents: true,
+			username:             "system:serviceaccount:test-ns:other-sa",
+			expEvents: []string{
+				"CertificateRequest test-ns/test-cr: Warning DurationMismatch Denied request: bad duration",
+			},
+		},
+		"if driver uses its own ServiceAccount, expect an Event on the mounting pod's ServiceAccount": {
+			serviceAccountEvents: true,
+			useOwnServiceAccount: true,
+			username:             "system:serviceaccount:test-ns:driver-sa",
+			annotations: map[string]string{
+				annotations.PodNameAnnotationKey: "test-pod",
+				annotations.PodUIDAnnotationKey:  "pod-uid",
+			},
+			expEvents: []string{
+				"CertificateRequest test-ns/test-cr: Warning DurationMismatch Denied request: bad duration",
+				"ServiceAccount test-ns/test-sa (test-uid): Warning DurationMismatch CertificateRequest test-ns/test-cr: Denied request: bad duration",
+			},
+		},
+		"if driver uses its own ServiceAccount and the mounting pod doesn't exist, expect an Event on the CertificateRequest only": {
+			serviceAccountEvents: true,
+			useOwnServiceAccount: true,
+			username:             "system:serviceaccount:test-ns:driver-sa",
+			annotations: map[string]string{
+				annotations.PodNameAnnotationKey: "other-pod",
+				annotations.PodUIDAnnotationKey:  "pod-uid",
+			},
+			expEvents: []string{
+				"CertificateRequest test-ns/test-cr: Warning DurationMismatch Denied request: bad duration",
+			},
+		},
+		"if driver uses its own ServiceAccount and the mounting pod has a different UID, expect an Event on the CertificateRequest only": {
+			serviceAccountEvents: true,
+			useOwnServiceAccount: true,
+			username:             "system:serviceaccount:test-ns:driver-sa",
+			annotations: map[string]string{
+				annotations.PodNameAnnotationKey: "test-pod",
+				annotations.PodUIDAnnotationKey:  "other-uid",
+			},
+			expEvents: []string{
+				"CertificateRequest test-ns/test-cr: Warning DurationMismatch Denied request: bad duration",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := new(testRecorder)
+			a := &approver{
+				log:                  ktesting.NewLogger(t, ktesting.DefaultConfig),
+				recorder:             recorder,
+				apiReader:            apiReader,
+				serviceAccountEvents: test.serviceAccountEvents,
+				useOwnServiceAccount: test.useOwnServiceAccount,
+			}
+
+			cr := cr.DeepCopy()
+			cr.Spec.Username = test.username
+			cr.Annotations = test.annotations
+			a.recordEvent(t.Context(), cr, corev1.EventTypeWarning, string(evaluator.DenialReasonDurationMismatch), "Deny", "Denied request: bad duration")
+
+			assert.Equal(t, test.expEvents, recorder.events)
 		})
 	}
 }
+
+// testRecorder is an events.EventRecorder which records the object each Event
+// is regarding, as well as the Event itself.
+type testRecorder struct {
+	events []string
+}
+
+func (r *testRecorder) Eventf(regarding runtime.Object, _ runtime.Object, eventtype, reason, _, note string, args ...any) {
+	var object string
+	switch o := regarding.(type) {
+	case *cmapi.CertificateRequest:
+		object = fmt.Sprintf("CertificateRequest %s/%s", o.Namespace, o.Name)
+	case *corev1.ObjectReference:
+		object = fmt.Sprintf("%s %s/%s (%s)", o.Kind, o.Namespace, o.Name, o.UID)
+	default:
+		object = fmt.Sprintf("%T", regarding)
+	}
+	r.events = append(r.events, fmt.Sprintf("%s: %s %s %s", object, eventtype, reason, fmt.Sprintf(note, args...)))
+}
+
+func Test_observeDecision(t *testing.T) {
+	fixedTime := time.Date(2021, 01, 01, 01, 0, 0, 0, time.UTC)
+	apiutil.Clock = fakeclock.NewFakeClock(fixedTime)
+
+	count := testutil.CollectAndCount(decisionDuration)
+
+	t.Log("should not observe requests without a creation timestamp")
+	observeDecision(&cmapi.CertificateRequest{}, requestTypeSPIFFE, decisionApproved)
+	assert.Equal(t, count, testutil.CollectAndCount(decisionDuration))
+
+	t.Log("should observe the time from creation to decision")
+	observeDecision(&cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(fixedTime.Add(-time.Second * 3))},
+	}, requestTypeNonSPIFFE, decisionDenied)
+	assert.NoError(t, testutil.CollectAndCompare(decisionDuration, strings.NewReader(`
+# HELP csi_driver_spiffe_approver_decision_duration_seconds Time from a CertificateRequest being created to it being approved or denied.
+# TYPE csi_driver_spiffe_approver_decision_duration_seconds histogram
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="0.01"} 0
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="0.02"} 0
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="0.04"} 0
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="0.08"} 0
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="0.16"} 0
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="0.32"} 0
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="0.64"} 0
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="1.28"} 0
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="2.56"} 0
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="5.12"} 1
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="10.24"} 1
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="20.48"} 1
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="40.96"} 1
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="81.92"} 1
+csi_driver_spiffe_approver_decision_duration_seconds_bucket{decision="denied",type="non_spiffe",le="+Inf"} 1
+csi_driver_spiffe_approver_decision_duration_seconds_sum{decision="denied",type="non_spiffe"} 3
+csi_driver_spiffe_approver_decision_duration_seconds_count{decision="denied",type="non_spiffe"} 1
+`), "csi_driver_spiffe_approver_decision_duration_seconds"))
+}
+
+func Test_runtimeIssuerCollector(t *testing.T) {
+	c := &runtimeIssuerCollector{
+		runtimeConfig: runtimeconfig.NewMemory(t.Context(), runtimeconfig.Config{IssuerRef: cmmeta.IssuerReference{
+			Name:  "spiffe-ca",
+			Kind:  "ClusterIssuer",
+			Group: "cert-manager.io",
+		}}, nil),
+	}
+
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP csi_driver_spiffe_approver_runtime_issuer_info The issuer SPIFFE CertificateRequests are currently expected to target. Always 1.
+# TYPE csi_driver_spiffe_approver_runtime_issuer_info gauge
+csi_driver_spiffe_approver_runtime_issuer_info{group="cert-manager.io",kind="ClusterIssuer",name="spiffe-ca"} 1
+`)))
+}
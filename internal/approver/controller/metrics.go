@@ -0,0 +1,103 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/runtimeconfig"
+)
+
+const (
+	// metricsNamespace and metricsSubsystem are the namespace and subsystem
+	// of all approver metrics.
+	metricsNamespace = "csi_driver_spiffe"
+	metricsSubsystem = "approver"
+
+	// requestTypeSPIFFE and requestTypeNonSPIFFE are the values of the type
+	// label, for whether a CertificateRequest was created by the driver.
+	requestTypeSPIFFE    = "spiffe"
+	requestTypeNonSPIFFE = "non_spiffe"
+
+	// decisionApproved and decisionDenied are the values of the decision
+	// label.
+	decisionApproved = "approved"
+	decisionDenied   = "denied"
+)
+
+var (
+	// approvedRequests is the number of CertificateRequests approved, by
+	// type.
+	approvedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "certificaterequests_approved_total",
+		Help:      "Number of CertificateRequests approved, by type.",
+	}, []string{"type"})
+
+	// deniedRequests is the number of CertificateRequests denied, by type and
+	// reason.
+	deniedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "certificaterequests_denied_total",
+		Help:      "Number of CertificateRequests denied, by type and denial reason.",
+	}, []string{"type", "reason"})
+
+	// decisionDuration is the time from a CertificateRequest being created,
+	// to it being approved or denied.
+	decisionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "decision_duration_seconds",
+		Help:      "Time from a CertificateRequest being created to it being approved or denied.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"type", "decision"})
+
+	runtimeIssuerDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "runtime_issuer_info"),
+		"The issuer SPIFFE CertificateRequests are currently expected to target. Always 1.",
+		[]string{"name", "kind", "group"}, nil,
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(approvedRequests, deniedRequests, decisionDuration)
+}
+
+// runtimeIssuerCollector is a prometheus.Collector which exposes the issuer
+// of the current runtime configuration on each scrape.
+type runtimeIssuerCollector struct {
+	runtimeConfig runtimeconfig.Interface
+}
+
+// Describe implements prometheus.Collector.
+func (r *runtimeIssuerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- runtimeIssuerDesc
+}
+
+// Collect implements prometheus.Collector.
+func (r *runtimeIssuerCollector) Collect(ch chan<- prometheus.Metric) {
+	issuerRef := r.runtimeConfig.Config().IssuerRef
+	if len(issuerRef.Name) == 0 {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(runtimeIssuerDesc, prometheus.GaugeValue, 1,
+		issuerRef.Name, issuerRef.Kind, issuerRef.Group)
+}
@@ -17,12 +17,16 @@ limitations under the License.
 package evaluator
 
 import (
-	"fmt"
+	"context"
 	"time"
 
 	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/cert-manager/cert-manager/pkg/util"
 	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/identity"
+	"github.com/cert-manager/csi-driver-spiffe/internal/keyalgorithm"
 )
 
 var (
@@ -37,7 +41,7 @@ var (
 // Interface is the Evaluator which is used for determining whether a
 // CertificateRequest should be approved or denied.
 type Interface interface {
-	Evaluate(*cmapi.CertificateRequest) error
+	Evaluate(context.Context, *cmapi.CertificateRequest) error
 }
 
 // Options is the options to configure the evaluator.
@@ -46,19 +50,41 @@ type Options struct {
 	// CertificateRequests URI SANs.
 	TrustDomain string
 
-	// CertificateRequestDuration is the duration that users _must_ request for,
-	// else the request will be denied.
-	CertificateRequestDuration time.Duration
+	// MinimumCertificateRequestDuration and MaximumCertificateRequestDuration
+	// are the inclusive range of durations that users _must_ request for, else
+	// the request will be denied.
+	MinimumCertificateRequestDuration time.Duration
+	MaximumCertificateRequestDuration time.Duration
 
 	// UseOwnServiceAccount, when true, changes the identity validation strategy.
 	// Instead of verifying that the SPIFFE identity in the CSR matches the
 	// requesting pod's ServiceAccount, the approver verifies that the requester
-	// is the driver's own ServiceAccount (DriverServiceAccount).
+	// is the driver's own ServiceAccount (DriverServiceAccount), and that the
+	// mounting pod is scheduled on the requesting driver's node and matches the
+	// requested SPIFFE ID.
 	UseOwnServiceAccount bool
 
 	// DriverServiceAccount is the full Kubernetes username of the CSI driver's
 	// ServiceAccount. Only used when UseOwnServiceAccount is true.
 	DriverServiceAccount string
+
+	// SPIFFEIDTemplate is the template used to build the expected SPIFFE ID
+	// of the requesting pod. Must match the template used by the driver.
+	// Defaults to identity.DefaultPathTemplate if nil.
+	SPIFFEIDTemplate *identity.Template
+
+	// ClusterName is the name of the cluster, substituted into
+	// SPIFFEIDTemplate.
+	ClusterName string
+
+	// PodReader is used to look up the requesting pod when SPIFFEIDTemplate
+	// references pod labels, or when UseOwnServiceAccount is true.
+	PodReader client.Reader
+
+	// AllowedKeyAlgorithms maps each allowed private key algorithm to its
+	// minimum key size in bits. Requests for any other algorithm, or a smaller
+	// key, are denied. Defaults to keyalgorithm.DefaultMinimumSizes if nil.
+	AllowedKeyAlgorithms map[keyalgorithm.Algorithm]int
 }
 
 // internal is the internal implementation of the evaluator that should be used
@@ -68,9 +94,11 @@ type internal struct {
 	// CertificateRequests URI SANs.
 	trustDomain string
 
-	// certificateRequestDuration is the duration that users _must_ request for,
-	// else the request will be denied.
-	certificateRequestDuration time.Duration
+	// minimumCertificateRequestDuration and maximumCertificateRequestDuration
+	// are the inclusive range of durations that users _must_ request for, else
+	// the request will be denied.
+	minimumCertificateRequestDuration time.Duration
+	maximumCertificateRequestDuration time.Duration
 
 	// useOwnServiceAccount controls which identity validation strategy is used.
 	useOwnServiceAccount bool
@@ -78,61 +106,109 @@ type internal struct {
 	// driverServiceAccount is the full Kubernetes username of the CSI driver's
 	// ServiceAccount. Only used when useOwnServiceAccount is true.
 	driverServiceAccount string
+
+	// spiffeIDTemplate is the template used to build the expected SPIFFE ID of
+	// the requesting pod.
+	spiffeIDTemplate *identity.Template
+
+	// clusterName is the name of the cluster, substituted into
+	// spiffeIDTemplate.
+	clusterName string
+
+	// podReader is used to look up the requesting pod when spiffeIDTemplate
+	// references pod labels, or when useOwnServiceAccount is true.
+	podReader client.Reader
+
+	// allowedKeyAlgorithms maps each allowed private key algorithm to its
+	// minimum key size in bits.
+	allowedKeyAlgorithms map[keyalgorithm.Algorithm]int
 }
 
 // New constructs a new evaluator.
 func New(opts Options) Interface {
-	return &internal{
-		trustDomain:                opts.TrustDomain,
-		certificateRequestDuration: opts.CertificateRequestDuration,
-		useOwnServiceAccount:       opts.UseOwnServiceAccount,
-		driverServiceAccount:       opts.DriverServiceAccount,
+	i := &internal{
+		trustDomain:                       opts.TrustDomain,
+		minimumCertificateRequestDuration: opts.MinimumCertificateRequestDuration,
+		maximumCertificateRequestDuration: opts.MaximumCertificateRequestDuration,
+		useOwnServiceAccount:              opts.UseOwnServiceAccount,
+		driverServiceAccount:              opts.DriverServiceAccount,
+		spiffeIDTemplate:                  opts.SPIFFEIDTemplate,
+		clusterName:                       opts.ClusterName,
+		podReader:                         opts.PodReader,
+		allowedKeyAlgorithms:              opts.AllowedKeyAlgorithms,
+	}
+
+	if i.spiffeIDTemplate == nil {
+		i.spiffeIDTemplate = identity.Default()
+	}
+
+	if i.allowedKeyAlgorithms == nil {
+		i.allowedKeyAlgorithms = keyalgorithm.DefaultMinimumSizes
 	}
+
+	return i
 }
 
 // Evaluate evaluates whether a CertificateRequest should be approved or
 // denied. A CertificateRequest should be denied if this function returns an
-// error, should be approved otherwise.
-func (i *internal) Evaluate(req *cmapi.CertificateRequest) error {
+// error, should be approved otherwise. Returned errors are a *DenialError,
+// holding the reason of the denial, unless the request could not be
+// evaluated, such as when the mounting pod could not be looked up. Such
+// errors have no reason, and the request should be evaluated again.
+func (i *internal) Evaluate(ctx context.Context, req *cmapi.CertificateRequest) error {
 	csr, err := utilpki.DecodeX509CertificateRequestBytes(req.Spec.Request)
 	if err != nil {
-		return fmt.Errorf("failed to parse request: %w", err)
+		return denyf(DenialReasonInvalidRequest, "failed to parse request: %w", err)
+	}
+
+	if req.Spec.Duration == nil {
+		return denyf(DenialReasonDurationMismatch, "requested certificate duration is missing, required between %q and %q",
+			i.minimumCertificateRequestDuration.String(), i.maximumCertificateRequestDuration.String())
 	}
 
-	if req.Spec.Duration == nil || req.Spec.Duration.Duration != i.certificateRequestDuration {
-		return fmt.Errorf("requested certificate doesn't match required, required=%q got=%v",
-			i.certificateRequestDuration.String(), req.Spec.Duration)
+	if d := req.Spec.Duration.Duration; d < i.minimumCertificateRequestDuration || d > i.maximumCertificateRequestDuration {
+		return denyf(DenialReasonDurationMismatch, "requested certificate duration is out of range, required between %q and %q got=%q",
+			i.minimumCertificateRequestDuration.String(), i.maximumCertificateRequestDuration.String(), d.String())
 	}
 
 	if err := csr.CheckSignature(); err != nil {
-		return fmt.Errorf("signature check failed for csr: %w", err)
+		return denyf(DenialReasonInvalidSignature, "signature check failed for csr: %w", err)
+	}
+
+	if err := i.validateKey(csr); err != nil {
+		return withReason(DenialReasonForbiddenKey, err)
 	}
 
 	// if the csr contains any other options set, error
 	if len(csr.DNSNames) > 0 || len(csr.IPAddresses) > 0 ||
 		len(csr.Subject.CommonName) > 0 || len(csr.EmailAddresses) > 0 {
-		return fmt.Errorf("forbidden extensions, DNS=%q IPs=%q CommonName=%q Emails=%q",
+		return denyf(DenialReasonForbiddenExtension, "forbidden extensions, DNS=%q IPs=%q CommonName=%q Emails=%q",
 			csr.DNSNames, csr.IPAddresses, csr.Subject.CommonName, csr.EmailAddresses)
 	}
 
 	if err := validateCSRExtentions(csr); err != nil {
-		return err
+		return withReason(DenialReasonForbiddenExtension, err)
 	}
 
 	if req.Spec.IsCA {
-		return fmt.Errorf("request contains spec.isCA=true")
+		return denyf(DenialReasonForbiddenCA, "request contains spec.isCA=true")
 	}
 
 	if !util.EqualKeyUsagesUnsorted(req.Spec.Usages, requiredUsages) {
-		return fmt.Errorf("request contains wrong usages, exp=%v got=%v", requiredUsages, req.Spec.Usages)
+		return denyf(DenialReasonWrongUsages, "request contains wrong usages, exp=%v got=%v", requiredUsages, req.Spec.Usages)
 	}
 
+	// Identity validation errors are returned as is, as those without a
+	// reason are failures to look up the mounting pod, rather than denials.
 	if i.useOwnServiceAccount {
 		if err := i.validateDriverServiceAccount(csr, req.Spec.Username); err != nil {
 			return err
 		}
+		if err := i.validateMountingPod(ctx, csr, req); err != nil {
+			return err
+		}
 	} else {
-		if err := i.validateIdentity(csr, req.Spec.Username); err != nil {
+		if err := i.validateIdentity(ctx, csr, req); err != nil {
 			return err
 		}
 	}
@@ -26,6 +26,9 @@ import (
 	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/identity"
+	"github.com/cert-manager/csi-driver-spiffe/internal/keyalgorithm"
 )
 
 func Test_Evaluate(t *testing.T) {
@@ -33,8 +36,9 @@ func Test_Evaluate(t *testing.T) {
 	assert.NoError(t, err)
 
 	tests := map[string]struct {
-		req    func(t *testing.T) *cmapi.CertificateRequest
-		expErr bool
+		req       func(t *testing.T) *cmapi.CertificateRequest
+		expErr    bool
+		expReason DenialReason
 	}{
 		"if request contains a badly encoded PEM, expect error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -44,7 +48,8 @@ func Test_Evaluate(t *testing.T) {
 					Username: "system:serviceaccount:sandbox:sleep",
 				}}
 			},
-			expErr: true,
+			expErr:    true,
+			expReason: DenialReasonInvalidRequest,
 		},
 		"if request duration is nil, expect error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -69,7 +74,8 @@ func Test_Evaluate(t *testing.T) {
 					},
 				}}
 			},
-			expErr: true,
+			expErr:    true,
+			expReason: DenialReasonDurationMismatch,
 		},
 		"if request contains DNS names, expect error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -95,7 +101,8 @@ func Test_Evaluate(t *testing.T) {
 					},
 				}}
 			},
-			expErr: true,
+			expErr:    true,
+			expReason: DenialReasonForbiddenExtension,
 		},
 		"if request contains IPs, expect error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -121,7 +128,8 @@ func Test_Evaluate(t *testing.T) {
 					},
 				}}
 			},
-			expErr: true,
+			expErr:    true,
+			expReason: DenialReasonForbiddenExtension,
 		},
 		"if request contains common name, expect error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -147,7 +155,8 @@ func Test_Evaluate(t *testing.T) {
 					},
 				}}
 			},
-			expErr: true,
+			expErr:    true,
+			expReason: DenialReasonForbiddenExtension,
 		},
 		"if request contains email addresses, expect error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -173,7 +182,8 @@ func Test_Evaluate(t *testing.T) {
 					},
 				}}
 			},
-			expErr: true,
+			expErr:    true,
+			expReason: DenialReasonForbiddenExtension,
 		},
 		"if request is with isCA=true, expect error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -199,7 +209,8 @@ func Test_Evaluate(t *testing.T) {
 					},
 				}}
 			},
-			expErr: true,
+			expErr:    true,
+			expReason: DenialReasonForbiddenCA,
 		},
 		"if request has the wrong usages, expect error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -223,7 +234,8 @@ func Test_Evaluate(t *testing.T) {
 					},
 				}}
 			},
-			expErr: true,
+			expErr:    true,
+			expReason: DenialReasonWrongUsages,
 		},
 		"if request has the wrong usages encoded in the request, expect error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -253,7 +265,8 @@ func Test_Evaluate(t *testing.T) {
 					},
 				}}
 			},
-			expErr: true,
+			expErr:    true,
+			expReason: DenialReasonForbiddenExtension,
 		},
 		"if request has the wrong identity, expect error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -278,7 +291,8 @@ func Test_Evaluate(t *testing.T) {
 					},
 				}}
 			},
-			expErr: true,
+			expErr:    true,
+			expReason: DenialReasonIdentityMismatch,
 		},
 		"if is valid, expect no error": {
 			req: func(t *testing.T) *cmapi.CertificateRequest {
@@ -305,17 +319,125 @@ func Test_Evaluate(t *testing.T) {
 			},
 			expErr: false,
 		},
+		"if request duration is shorter than the minimum, expect error": {
+			req: func(t *testing.T) *cmapi.CertificateRequest {
+				csr, err := utilpki.GenerateCSR(&cmapi.Certificate{
+					Spec: cmapi.CertificateSpec{
+						PrivateKey: &cmapi.CertificatePrivateKey{Algorithm: cmapi.ECDSAKeyAlgorithm},
+						URIs:       []string{"spiffe://foo.bar/ns/sandbox/sa/sleep"},
+					},
+				})
+				assert.NoError(t, err)
+				csrDER, err := utilpki.EncodeCSR(csr, pk)
+				assert.NoError(t, err)
+				csrPEM := bytes.NewBuffer([]byte{})
+				assert.NoError(t, pem.Encode(csrPEM, &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+				return &cmapi.CertificateRequest{Spec: cmapi.CertificateRequestSpec{
+					Request:  csrPEM.Bytes(),
+					Duration: &metav1.Duration{Duration: time.Minute * 5},
+					Username: "system:serviceaccount:sandbox:sleep",
+					Usages: []cmapi.KeyUsage{
+						cmapi.UsageServerAuth, cmapi.UsageClientAuth,
+						cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment,
+					},
+				}}
+			},
+			expErr:    true,
+			expReason: DenialReasonDurationMismatch,
+		},
+		"if request duration is longer than the maximum, expect error": {
+			req: func(t *testing.T) *cmapi.CertificateRequest {
+				csr, err := utilpki.GenerateCSR(&cmapi.Certificate{
+					Spec: cmapi.CertificateSpec{
+						PrivateKey: &cmapi.CertificatePrivateKey{Algorithm: cmapi.ECDSAKeyAlgorithm},
+						URIs:       []string{"spiffe://foo.bar/ns/sandbox/sa/sleep"},
+					},
+				})
+				assert.NoError(t, err)
+				csrDER, err := utilpki.EncodeCSR(csr, pk)
+				assert.NoError(t, err)
+				csrPEM := bytes.NewBuffer([]byte{})
+				assert.NoError(t, pem.Encode(csrPEM, &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+				return &cmapi.CertificateRequest{Spec: cmapi.CertificateRequestSpec{
+					Request:  csrPEM.Bytes(),
+					Duration: &metav1.Duration{Duration: time.Hour * 25},
+					Username: "system:serviceaccount:sandbox:sleep",
+					Usages: []cmapi.KeyUsage{
+						cmapi.UsageServerAuth, cmapi.UsageClientAuth,
+						cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment,
+					},
+				}}
+			},
+			expErr:    true,
+			expReason: DenialReasonDurationMismatch,
+		},
+		"if request duration is the minimum, expect no error": {
+			req: func(t *testing.T) *cmapi.CertificateRequest {
+				csr, err := utilpki.GenerateCSR(&cmapi.Certificate{
+					Spec: cmapi.CertificateSpec{
+						PrivateKey: &cmapi.CertificatePrivateKey{Algorithm: cmapi.ECDSAKeyAlgorithm},
+						URIs:       []string{"spiffe://foo.bar/ns/sandbox/sa/sleep"},
+					},
+				})
+				assert.NoError(t, err)
+				csrDER, err := utilpki.EncodeCSR(csr, pk)
+				assert.NoError(t, err)
+				csrPEM := bytes.NewBuffer([]byte{})
+				assert.NoError(t, pem.Encode(csrPEM, &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+				return &cmapi.CertificateRequest{Spec: cmapi.CertificateRequestSpec{
+					Request:  csrPEM.Bytes(),
+					Duration: &metav1.Duration{Duration: time.Minute * 10},
+					Username: "system:serviceaccount:sandbox:sleep",
+					Usages: []cmapi.KeyUsage{
+						cmapi.UsageServerAuth, cmapi.UsageClientAuth,
+						cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment,
+					},
+				}}
+			},
+			expErr: false,
+		},
+		"if request duration is the maximum, expect no error": {
+			req: func(t *testing.T) *cmapi.CertificateRequest {
+				csr, err := utilpki.GenerateCSR(&cmapi.Certificate{
+					Spec: cmapi.CertificateSpec{
+						PrivateKey: &cmapi.CertificatePrivateKey{Algorithm: cmapi.ECDSAKeyAlgorithm},
+						URIs:       []string{"spiffe://foo.bar/ns/sandbox/sa/sleep"},
+					},
+				})
+				assert.NoError(t, err)
+				csrDER, err := utilpki.EncodeCSR(csr, pk)
+				assert.NoError(t, err)
+				csrPEM := bytes.NewBuffer([]byte{})
+				assert.NoError(t, pem.Encode(csrPEM, &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+				return &cmapi.CertificateRequest{Spec: cmapi.CertificateRequestSpec{
+					Request:  csrPEM.Bytes(),
+					Duration: &metav1.Duration{Duration: time.Hour * 24},
+					Username: "system:serviceaccount:sandbox:sleep",
+					Usages: []cmapi.KeyUsage{
+						cmapi.UsageServerAuth, cmapi.UsageClientAuth,
+						cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment,
+					},
+				}}
+			},
+			expErr: false,
+		},
 	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			i := &internal{
-				trustDomain:                "foo.bar",
-				certificateRequestDuration: time.Hour,
+				trustDomain:                       "foo.bar",
+				minimumCertificateRequestDuration: time.Minute * 10,
+				maximumCertificateRequestDuration: time.Hour * 24,
+				spiffeIDTemplate:                  identity.Default(),
+				allowedKeyAlgorithms:              keyalgorithm.DefaultMinimumSizes,
 			}
 
-			err := i.Evaluate(test.req(t))
+			err := i.Evaluate(t.Context(), test.req(t))
 			assert.Equal(t, test.expErr, err != nil, "%v", err)
+			if test.expErr {
+				assert.Equal(t, test.expReason, Reason(err), "%v", err)
+			}
 		})
 	}
 }
@@ -17,6 +17,8 @@ limitations under the License.
 package fake
 
 import (
+	"context"
+
 	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 
 	"github.com/cert-manager/csi-driver-spiffe/internal/approver/evaluator"
@@ -43,6 +45,6 @@ func (f *FakeEvaluator) WithEvaluate(fn func(_ *cmapi.CertificateRequest) error)
 	return f
 }
 
-func (f *FakeEvaluator) Evaluate(req *cmapi.CertificateRequest) error {
+func (f *FakeEvaluator) Evaluate(_ context.Context, req *cmapi.CertificateRequest) error {
 	return f.funcEvaluate(req)
 }
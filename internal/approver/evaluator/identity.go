@@ -17,11 +17,24 @@ limitations under the License.
 package evaluator
 
 import (
+	"context"
 	"crypto/x509"
 	"fmt"
 	"strings"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/annotations"
+	"github.com/cert-manager/csi-driver-spiffe/internal/identity"
 )
 
+// nodeNameExtraKey is the user info extra key holding the name of the node
+// that the pod of a ServiceAccount token is bound to.
+const nodeNameExtraKey = "authentication.kubernetes.io/node-name"
+
 // validateDriverServiceAccount validates that:
 //   - the CSR contains exactly one URI SAN with the spiffe:// scheme and the
 //     correct trust domain, and
@@ -30,48 +43,161 @@ import (
 // Used when UseOwnServiceAccount is true.
 func (i *internal) validateDriverServiceAccount(csr *x509.CertificateRequest, username string) error {
 	if len(csr.URIs) != 1 {
-		return fmt.Errorf("expected exactly 1 SPIFFE URI present on request, got=%d", len(csr.URIs))
+		return denyf(DenialReasonInvalidSPIFFEID, "expected exactly 1 SPIFFE URI present on request, got=%d", len(csr.URIs))
 	}
 
 	if csr.URIs[0].Scheme != "spiffe" {
-		return fmt.Errorf("URI scheme is not spiffe: %s", csr.URIs[0].Scheme)
+		return denyf(DenialReasonInvalidSPIFFEID, "URI scheme is not spiffe: %s", csr.URIs[0].Scheme)
 	}
 
 	if csr.URIs[0].Host != i.trustDomain {
-		return fmt.Errorf("unexpected trust domain, exp=%q got=%q", i.trustDomain, csr.URIs[0].Host)
+		return denyf(DenialReasonWrongTrustDomain, "unexpected trust domain, exp=%q got=%q", i.trustDomain, csr.URIs[0].Host)
 	}
 
 	if username != i.driverServiceAccount {
-		return fmt.Errorf("request must be made by the csi-driver-spiffe ServiceAccount, exp=%q got=%q",
+		return denyf(DenialReasonIdentityMismatch, "request must be made by the csi-driver-spiffe ServiceAccount, exp=%q got=%q",
 			i.driverServiceAccount, username)
 	}
 
 	return nil
 }
 
+// validateMountingPod validates that the pod the driver requested the
+// certificate for exists, is scheduled on the node of the driver which made
+// the request, and runs as the ServiceAccount encoded in the requested SPIFFE
+// ID. This stops a driver from requesting the identity of any pod not on its
+// node. The node is taken from the requester's user info, which the API
+// server sets from the driver's node-bound ServiceAccount token.
+//
+// Used when UseOwnServiceAccount is true.
+func (i *internal) validateMountingPod(ctx context.Context, csr *x509.CertificateRequest, req *cmapi.CertificateRequest) error {
+	nodeNames := req.Spec.Extra[nodeNameExtraKey]
+	if len(nodeNames) != 1 || len(nodeNames[0]) == 0 {
+		return denyf(DenialReasonPodMismatch, "request is missing the requesting node in user info extra %q, the driver must use a node-bound ServiceAccount token",
+			nodeNameExtraKey)
+	}
+	nodeName := nodeNames[0]
+
+	if annotated := req.Annotations[annotations.NodeNameAnnotationKey]; annotated != nodeName {
+		return denyf(DenialReasonPodMismatch, "request %q annotation does not match the requesting node, exp=%q got=%q",
+			annotations.NodeNameAnnotationKey, nodeName, annotated)
+	}
+
+	podUID := req.Annotations[annotations.PodUIDAnnotationKey]
+	if len(podUID) == 0 {
+		return denyf(DenialReasonPodMismatch, "request is missing the %q annotation", annotations.PodUIDAnnotationKey)
+	}
+
+	pod, err := i.getPod(ctx, req.Namespace, req.Annotations[annotations.PodNameAnnotationKey])
+	if err != nil {
+		return err
+	}
+
+	if string(pod.UID) != podUID {
+		return denyf(DenialReasonPodMismatch, "pod %s/%s has a different UID to the request, exp=%q got=%q",
+			pod.Namespace, pod.Name, podUID, pod.UID)
+	}
+
+	if pod.Spec.NodeName != nodeName {
+		return denyf(DenialReasonPodMismatch, "pod %s/%s is not scheduled on the requesting node, exp=%q got=%q",
+			pod.Namespace, pod.Name, nodeName, pod.Spec.NodeName)
+	}
+
+	expSpiffeID, err := i.spiffeIDTemplate.ID(identity.Params{
+		TrustDomain:    i.trustDomain,
+		Namespace:      pod.Namespace,
+		ServiceAccount: pod.Spec.ServiceAccountName,
+		ClusterName:    i.clusterName,
+		PodLabels:      pod.Labels,
+	})
+	if err != nil {
+		return denyf(DenialReasonIdentityMismatch, "failed to build expected SPIFFE ID: %w", err)
+	}
+
+	if csr.URIs[0].String() != expSpiffeID.String() {
+		return denyf(DenialReasonIdentityMismatch, "unexpected SPIFFE ID requested for pod %s/%s, exp=%q got=%q",
+			pod.Namespace, pod.Name, expSpiffeID, csr.URIs[0].String())
+	}
+
+	return nil
+}
+
 // validateIdentity validates that the SPIFFE ID contained in the X.509
-// certificate request matches that in the username.
+// certificate request matches that built from the request's username.
 // The username should be the Username as it appears on the CertificateRequest.
 // This should be the ServiceAccount of the mounting Pod who has been
 // impersonated to create the request.
-func (i *internal) validateIdentity(csr *x509.CertificateRequest, username string) error {
+func (i *internal) validateIdentity(ctx context.Context, csr *x509.CertificateRequest, req *cmapi.CertificateRequest) error {
+	username := req.Spec.Username
 	split := strings.Split(username, ":")
 	if len(split) != 4 || split[0] != "system" || split[1] != "serviceaccount" {
-		return fmt.Errorf("got non-serviceaccount encoded username: %q", username)
+		return denyf(DenialReasonIdentityMismatch, "got non-serviceaccount encoded username: %q", username)
 	}
 
 	if len(csr.URIs) != 1 {
-		return fmt.Errorf("expected exactly 1 SPIFFE URI present on request, got=%d", len(csr.URIs))
+		return denyf(DenialReasonInvalidSPIFFEID, "expected exactly 1 SPIFFE URI present on request, got=%d", len(csr.URIs))
 	}
 
 	if csr.URIs[0].Scheme != "spiffe" {
-		return fmt.Errorf("URI scheme is not spiffe: %s", csr.URIs[0].Scheme)
+		return denyf(DenialReasonInvalidSPIFFEID, "URI scheme is not spiffe: %s", csr.URIs[0].Scheme)
+	}
+
+	if csr.URIs[0].Host != i.trustDomain {
+		return denyf(DenialReasonWrongTrustDomain, "unexpected trust domain, exp=%q got=%q", i.trustDomain, csr.URIs[0].Host)
+	}
+
+	params := identity.Params{
+		TrustDomain:    i.trustDomain,
+		Namespace:      split[2],
+		ServiceAccount: split[3],
+		ClusterName:    i.clusterName,
+	}
+
+	if i.spiffeIDTemplate.UsesPodLabels() {
+		pod, err := i.getPod(ctx, split[2], req.Annotations[annotations.PodNameAnnotationKey])
+		if err != nil {
+			return err
+		}
+
+		if pod.Spec.ServiceAccountName != split[3] {
+			return denyf(DenialReasonIdentityMismatch, "pod %s/%s does not run as the requesting ServiceAccount, exp=%q got=%q",
+				pod.Namespace, pod.Name, split[3], pod.Spec.ServiceAccountName)
+		}
+
+		params.PodLabels = pod.Labels
 	}
 
-	expSpiffeID := fmt.Sprintf("spiffe://%s/ns/%s/sa/%s", i.trustDomain, split[2], split[3])
-	if csr.URIs[0].String() != expSpiffeID {
-		return fmt.Errorf("unexpected SPIFFE ID requested, exp=%q got=%q", expSpiffeID, csr.URIs[0].String())
+	expSpiffeID, err := i.spiffeIDTemplate.ID(params)
+	if err != nil {
+		return denyf(DenialReasonIdentityMismatch, "failed to build expected SPIFFE ID: %w", err)
+	}
+
+	if csr.URIs[0].String() != expSpiffeID.String() {
+		return denyf(DenialReasonIdentityMismatch, "unexpected SPIFFE ID requested, exp=%q got=%q", expSpiffeID, csr.URIs[0].String())
 	}
 
 	return nil
 }
+
+// getPod returns the named pod, as referenced by a CertificateRequest
+// annotation. Only a pod which does not exist is a denial, any other error
+// is returned without a reason so that the request is retried.
+func (i *internal) getPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
+	if len(name) == 0 {
+		return nil, denyf(DenialReasonPodMismatch, "request is missing the %q annotation", annotations.PodNameAnnotationKey)
+	}
+
+	if i.podReader == nil {
+		return nil, fmt.Errorf("unable to look up pod %s/%s: no pod reader configured", namespace, name)
+	}
+
+	var pod corev1.Pod
+	if err := i.podReader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, denyf(DenialReasonPodNotFound, "failed to get pod %s/%s: %w", namespace, name, err)
+		}
+		return nil, fmt.Errorf("failed to get pod %s/%s: %w", namespace, name, err)
+	}
+
+	return &pod, nil
+}
@@ -17,57 +17,148 @@ limitations under the License.
 package evaluator
 
 import (
+	"context"
 	"crypto/x509"
+	"errors"
+	"maps"
 	"net/url"
 	"testing"
 
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/annotations"
+	"github.com/cert-manager/csi-driver-spiffe/internal/identity"
 )
 
 func Test_validateIdentity(t *testing.T) {
+	labelTemplate, err := identity.Parse("/cluster/{cluster-name}/ns/{namespace}/sa/{service-account}/app/{pod-label:app}", []string{"app"})
+	assert.NoError(t, err)
+
+	sleepPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "sandbox", Name: "sleep-abc", Labels: map[string]string{"app": "sleeper"}},
+		Spec:       corev1.PodSpec{ServiceAccountName: "sleep"},
+	}
+
 	tests := map[string]struct {
-		uris     []string
-		username string
-		expErr   bool
+		template    *identity.Template
+		uris        []string
+		username    string
+		annotations map[string]string
+		pods        []client.Object
+		expErr      bool
+		expReason   DenialReason
 	}{
 		"if username is malformed, expect error": {
-			uris:     []string{"spiffe://foo.bar/ns/sandbox/sa/sleep"},
-			username: "system:serviceaccount:foo",
-			expErr:   true,
+			uris:      []string{"spiffe://foo.bar/ns/sandbox/sa/sleep"},
+			username:  "system:serviceaccount:foo",
+			expErr:    true,
+			expReason: DenialReasonIdentityMismatch,
 		},
 		"if multiple URIs defined, expect error": {
 			uris: []string{
 				"spiffe://foo.bar/ns/sandbox/sa/sleep",
 				"spiffe://foo.bar/ns/sandbox/sa/httpbin",
 			},
-			username: "system:serviceaccount:sandbox:sleep",
-			expErr:   true,
+			username:  "system:serviceaccount:sandbox:sleep",
+			expErr:    true,
+			expReason: DenialReasonInvalidSPIFFEID,
 		},
 		"if URI is not using SPIFFE, expect error": {
-			uris:     []string{"http://foo.bar/ns/sandbox/sa/sleep"},
-			username: "system:serviceaccount:sandbox:sleep",
-			expErr:   true,
+			uris:      []string{"http://foo.bar/ns/sandbox/sa/sleep"},
+			username:  "system:serviceaccount:sandbox:sleep",
+			expErr:    true,
+			expReason: DenialReasonInvalidSPIFFEID,
 		},
 		"if trust domain is wrong, expect error": {
-			uris:     []string{"spiffe://bar.foo/ns/sandbox/sa/sleep"},
-			username: "system:serviceaccount:sandbox:sleep",
-			expErr:   true,
+			uris:      []string{"spiffe://bar.foo/ns/sandbox/sa/sleep"},
+			username:  "system:serviceaccount:sandbox:sleep",
+			expErr:    true,
+			expReason: DenialReasonWrongTrustDomain,
 		},
 		"if SPIFFE ID doesn't match the username, expect error": {
-			uris:     []string{"spiffe://foo.bar/ns/sandbox/sa/httpbin"},
-			username: "system:serviceaccount:sandbox:sleep",
-			expErr:   true,
+			uris:      []string{"spiffe://foo.bar/ns/sandbox/sa/httpbin"},
+			username:  "system:serviceaccount:sandbox:sleep",
+			expErr:    true,
+			expReason: DenialReasonIdentityMismatch,
 		},
 		"if SPIFFE ID matches username, don't expect error": {
 			uris:     []string{"spiffe://foo.bar/ns/sandbox/sa/sleep"},
 			username: "system:serviceaccount:sandbox:sleep",
 			expErr:   false,
 		},
+		"if SPIFFE ID matches custom template, don't expect error": {
+			template:    labelTemplate,
+			uris:        []string{"spiffe://foo.bar/cluster/test-cluster/ns/sandbox/sa/sleep/app/sleeper"},
+			username:    "system:serviceaccount:sandbox:sleep",
+			annotations: map[string]string{annotations.PodNameAnnotationKey: "sleep-abc"},
+			pods:        []client.Object{sleepPod},
+			expErr:      false,
+		},
+		"if SPIFFE ID uses the default form with a custom template, expect error": {
+			template:    labelTemplate,
+			uris:        []string{"spiffe://foo.bar/ns/sandbox/sa/sleep"},
+			username:    "system:serviceaccount:sandbox:sleep",
+			annotations: map[string]string{annotations.PodNameAnnotationKey: "sleep-abc"},
+			pods:        []client.Object{sleepPod},
+			expErr:      true,
+			expReason:   DenialReasonIdentityMismatch,
+		},
+		"if SPIFFE ID contains a different label value than the pod, expect error": {
+			template:    labelTemplate,
+			uris:        []string{"spiffe://foo.bar/cluster/test-cluster/ns/sandbox/sa/sleep/app/admin"},
+			username:    "system:serviceaccount:sandbox:sleep",
+			annotations: map[string]string{annotations.PodNameAnnotationKey: "sleep-abc"},
+			pods:        []client.Object{sleepPod},
+			expErr:      true,
+			expReason:   DenialReasonIdentityMismatch,
+		},
+		"if template uses pod labels and the pod name annotation is missing, expect error": {
+			template:  labelTemplate,
+			uris:      []string{"spiffe://foo.bar/cluster/test-cluster/ns/sandbox/sa/sleep/app/sleeper"},
+			username:  "system:serviceaccount:sandbox:sleep",
+			pods:      []client.Object{sleepPod},
+			expErr:    true,
+			expReason: DenialReasonPodMismatch,
+		},
+		"if template uses pod labels and the pod doesn't exist, expect error": {
+			template:    labelTemplate,
+			uris:        []string{"spiffe://foo.bar/cluster/test-cluster/ns/sandbox/sa/sleep/app/sleeper"},
+			username:    "system:serviceaccount:sandbox:sleep",
+			annotations: map[string]string{annotations.PodNameAnnotationKey: "sleep-abc"},
+			expErr:      true,
+			expReason:   DenialReasonPodNotFound,
+		},
+		"if template uses pod labels and the pod runs as a different ServiceAccount, expect error": {
+			template:    labelTemplate,
+			uris:        []string{"spiffe://foo.bar/cluster/test-cluster/ns/sandbox/sa/httpbin/app/sleeper"},
+			username:    "system:serviceaccount:sandbox:httpbin",
+			annotations: map[string]string{annotations.PodNameAnnotationKey: "sleep-abc"},
+			pods:        []client.Object{sleepPod},
+			expErr:      true,
+			expReason:   DenialReasonIdentityMismatch,
+		},
 	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			i := &internal{trustDomain: "foo.bar"}
+			template := test.template
+			if template == nil {
+				template = identity.Default()
+			}
+
+			i := &internal{
+				trustDomain:      "foo.bar",
+				clusterName:      "test-cluster",
+				spiffeIDTemplate: template,
+				podReader:        fakeclient.NewClientBuilder().WithObjects(test.pods...).Build(),
+			}
 
 			var uris []*url.URL
 			for _, uriStr := range test.uris {
@@ -76,8 +167,161 @@ func Test_validateIdentity(t *testing.T) {
 				uris = append(uris, uri)
 			}
 
-			err := i.validateIdentity(&x509.CertificateRequest{URIs: uris}, test.username)
+			req := &cmapi.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations},
+				Spec:       cmapi.CertificateRequestSpec{Username: test.username},
+			}
+
+			err := i.validateIdentity(t.Context(), &x509.CertificateRequest{URIs: uris}, req)
 			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+			if test.expErr {
+				assert.Equalf(t, test.expReason, Reason(err), "%v", err)
+			}
+		})
+	}
+}
+
+func Test_validateMountingPod(t *testing.T) {
+	sleepPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "sandbox", Name: "sleep-abc", UID: "1234"},
+		Spec:       corev1.PodSpec{ServiceAccountName: "sleep", NodeName: "node-1"},
+	}
+
+	podAnnotations := map[string]string{
+		annotations.PodNameAnnotationKey:  "sleep-abc",
+		annotations.PodUIDAnnotationKey:   "1234",
+		annotations.NodeNameAnnotationKey: "node-1",
+	}
+
+	withAnnotation := func(key, value string) map[string]string {
+		out := maps.Clone(podAnnotations)
+		out[key] = value
+		return out
+	}
+
+	tests := map[string]struct {
+		uri         string
+		annotations map[string]string
+		extra       map[string][]string
+		pods        []client.Object
+		getErr      error
+		expErr      bool
+		expReason   DenialReason
+	}{
+		"if pod is on the requesting node and matches the SPIFFE ID, don't expect error": {
+			uri:         "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			annotations: podAnnotations,
+			extra:       map[string][]string{nodeNameExtraKey: {"node-1"}},
+			pods:        []client.Object{sleepPod},
+			expErr:      false,
+		},
+		"if requesting node is not in the user info, expect error": {
+			uri:         "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			annotations: podAnnotations,
+			pods:        []client.Object{sleepPod},
+			expErr:      true,
+			expReason:   DenialReasonPodMismatch,
+		},
+		"if node annotation doesn't match the requesting node, expect error": {
+			uri:         "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			annotations: withAnnotation(annotations.NodeNameAnnotationKey, "node-2"),
+			extra:       map[string][]string{nodeNameExtraKey: {"node-1"}},
+			pods:        []client.Object{sleepPod},
+			expErr:      true,
+			expReason:   DenialReasonPodMismatch,
+		},
+		"if pod is scheduled on a different node, expect error": {
+			uri:         "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			annotations: withAnnotation(annotations.NodeNameAnnotationKey, "node-2"),
+			extra:       map[string][]string{nodeNameExtraKey: {"node-2"}},
+			pods:        []client.Object{sleepPod},
+			expErr:      true,
+			expReason:   DenialReasonPodMismatch,
+		},
+		"if pod UID annotation is missing, expect error": {
+			uri: "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			annotations: map[string]string{
+				annotations.PodNameAnnotationKey:  "sleep-abc",
+				annotations.NodeNameAnnotationKey: "node-1",
+			},
+			extra:     map[string][]string{nodeNameExtraKey: {"node-1"}},
+			pods:      []client.Object{sleepPod},
+			expErr:    true,
+			expReason: DenialReasonPodMismatch,
+		},
+		"if pod UID doesn't match, expect error": {
+			uri:         "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			annotations: withAnnotation(annotations.PodUIDAnnotationKey, "5678"),
+			extra:       map[string][]string{nodeNameExtraKey: {"node-1"}},
+			pods:        []client.Object{sleepPod},
+			expErr:      true,
+			expReason:   DenialReasonPodMismatch,
+		},
+		"if pod doesn't exist, expect error": {
+			uri:         "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			annotations: podAnnotations,
+			extra:       map[string][]string{nodeNameExtraKey: {"node-1"}},
+			expErr:      true,
+			expReason:   DenialReasonPodNotFound,
+		},
+		"if pod can't be looked up, expect error without a reason": {
+			uri:         "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			annotations: podAnnotations,
+			extra:       map[string][]string{nodeNameExtraKey: {"node-1"}},
+			pods:        []client.Object{sleepPod},
+			getErr:      apierrors.NewForbidden(corev1.Resource("pods"), "sleep-abc", errors.New("forbidden")),
+			expErr:      true,
+			expReason:   DenialReasonUnknown,
+		},
+		"if SPIFFE ID is for a different ServiceAccount than the pod runs as, expect error": {
+			uri:         "spiffe://foo.bar/ns/sandbox/sa/admin",
+			annotations: podAnnotations,
+			extra:       map[string][]string{nodeNameExtraKey: {"node-1"}},
+			pods:        []client.Object{sleepPod},
+			expErr:      true,
+			expReason:   DenialReasonIdentityMismatch,
+		},
+		"if SPIFFE ID is for a different namespace than the pod, expect error": {
+			uri:         "spiffe://foo.bar/ns/kube-system/sa/sleep",
+			annotations: podAnnotations,
+			extra:       map[string][]string{nodeNameExtraKey: {"node-1"}},
+			pods:        []client.Object{sleepPod},
+			expErr:      true,
+			expReason:   DenialReasonIdentityMismatch,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			i := &internal{
+				trustDomain:      "foo.bar",
+				spiffeIDTemplate: identity.Default(),
+				podReader: fakeclient.NewClientBuilder().WithObjects(test.pods...).WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						if test.getErr != nil {
+							return test.getErr
+						}
+						return c.Get(ctx, key, obj, opts...)
+					},
+				}).Build(),
+			}
+
+			uri, err := url.Parse(test.uri)
+			assert.NoError(t, err)
+
+			req := &cmapi.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "sandbox", Annotations: test.annotations},
+				Spec: cmapi.CertificateRequestSpec{
+					Username: "system:serviceaccount:cert-manager:csi-driver-spiffe",
+					Extra:    test.extra,
+				},
+			}
+
+			err = i.validateMountingPod(t.Context(), &x509.CertificateRequest{URIs: []*url.URL{uri}}, req)
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+			if test.expErr {
+				assert.Equalf(t, test.expReason, Reason(err), "%v", err)
+			}
 		})
 	}
 }
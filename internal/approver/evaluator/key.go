@@ -0,0 +1,44 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package evaluator
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/keyalgorithm"
+)
+
+// validateKey validates that the public key of the request uses an allowed
+// algorithm, and is at least the minimum size for that algorithm.
+func (i *internal) validateKey(csr *x509.CertificateRequest) error {
+	alg, size, err := keyalgorithm.Of(csr.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	minSize, ok := i.allowedKeyAlgorithms[alg]
+	if !ok {
+		return fmt.Errorf("key algorithm %s is not allowed", alg)
+	}
+
+	if size < minSize {
+		return fmt.Errorf("key size %d is smaller than the minimum allowed for %s, min=%d", size, alg, minSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package evaluator
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/keyalgorithm"
+)
+
+func Test_validateKey(t *testing.T) {
+	mustPublic := func(key crypto.Signer, err error) crypto.PublicKey {
+		require.NoError(t, err)
+		return key.Public()
+	}
+
+	p256 := mustPublic(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	p384 := mustPublic(ecdsa.GenerateKey(elliptic.P384(), rand.Reader))
+	rsa2048 := mustPublic(rsa.GenerateKey(rand.Reader, 2048))
+	rsa3072 := mustPublic(rsa.GenerateKey(rand.Reader, 3072))
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		allowed   map[keyalgorithm.Algorithm]int
+		publicKey crypto.PublicKey
+		expErr    bool
+	}{
+		"default allowlist should allow ECDSA P-256": {
+			allowed:   keyalgorithm.DefaultMinimumSizes,
+			publicKey: p256,
+		},
+		"default allowlist should allow RSA 2048": {
+			allowed:   keyalgorithm.DefaultMinimumSizes,
+			publicKey: rsa2048,
+		},
+		"default allowlist should allow Ed25519": {
+			allowed:   keyalgorithm.DefaultMinimumSizes,
+			publicKey: edPub,
+		},
+		"algorithm not in allowlist should error": {
+			allowed:   map[keyalgorithm.Algorithm]int{keyalgorithm.ECDSA: 256},
+			publicKey: rsa3072,
+			expErr:    true,
+		},
+		"RSA key smaller than the minimum should error": {
+			allowed:   map[keyalgorithm.Algorithm]int{keyalgorithm.RSA: 3072},
+			publicKey: rsa2048,
+			expErr:    true,
+		},
+		"RSA key at the minimum should be allowed": {
+			allowed:   map[keyalgorithm.Algorithm]int{keyalgorithm.RSA: 3072},
+			publicKey: rsa3072,
+		},
+		"ECDSA key smaller than the minimum should error": {
+			allowed:   map[keyalgorithm.Algorithm]int{keyalgorithm.ECDSA: 384},
+			publicKey: p256,
+			expErr:    true,
+		},
+		"ECDSA key larger than the minimum should be allowed": {
+			allowed:   map[keyalgorithm.Algorithm]int{keyalgorithm.ECDSA: 256},
+			publicKey: p384,
+		},
+		"empty allowlist should error": {
+			allowed:   map[keyalgorithm.Algorithm]int{},
+			publicKey: p256,
+			expErr:    true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			i := &internal{allowedKeyAlgorithms: test.allowed}
+			err := i.validateKey(&x509.CertificateRequest{PublicKey: test.publicKey})
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DenialReason is a stable, machine readable reason of why a
+// CertificateRequest was denied. Reasons are CamelCase so that they can be
+// used as a Kubernetes condition or Event reason.
+type DenialReason string
+
+const (
+	// DenialReasonInvalidRequest is used when the request could not be parsed.
+	DenialReasonInvalidRequest DenialReason = "InvalidRequest"
+
+	// DenialReasonDurationMismatch is used when the requested duration is
+	// missing or out of the allowed range.
+	DenialReasonDurationMismatch DenialReason = "DurationMismatch"
+
+	// DenialReasonInvalidSignature is used when the signature of the request
+	// does not verify.
+	DenialReasonInvalidSignature DenialReason = "InvalidSignature"
+
+	// DenialReasonForbiddenKey is used when the key algorithm or size of the
+	// request is not allowed.
+	DenialReasonForbiddenKey DenialReason = "ForbiddenKey"
+
+	// DenialReasonForbiddenExtension is used when the request contains
+	// attributes or extensions which are not allowed.
+	DenialReasonForbiddenExtension DenialReason = "ForbiddenExtension"
+
+	// DenialReasonForbiddenCA is used when the request is for a CA.
+	DenialReasonForbiddenCA DenialReason = "ForbiddenCA"
+
+	// DenialReasonWrongUsages is used when the request does not have exactly
+	// the required key usages.
+	DenialReasonWrongUsages DenialReason = "WrongUsages"
+
+	// DenialReasonInvalidSPIFFEID is used when the request does not contain
+	// exactly one SPIFFE ID URI SAN.
+	DenialReasonInvalidSPIFFEID DenialReason = "InvalidSPIFFEID"
+
+	// DenialReasonWrongTrustDomain is used when the requested SPIFFE ID is
+	// not in the trust domain.
+	DenialReasonWrongTrustDomain DenialReason = "WrongTrustDomain"
+
+	// DenialReasonIdentityMismatch is used when the requested SPIFFE ID, or
+	// the requester, does not match the expected identity.
+	DenialReasonIdentityMismatch DenialReason = "IdentityMismatch"
+
+	// DenialReasonPodMismatch is used when the request does not match the
+	// mounting pod, or the node it is scheduled on.
+	DenialReasonPodMismatch DenialReason = "PodMismatch"
+
+	// DenialReasonPodNotFound is used when the mounting pod does not exist.
+	DenialReasonPodNotFound DenialReason = "PodNotFound"
+
+	// DenialReasonUnknown is used when an error has no denial reason. These
+	// are failures to evaluate the request, rather than denials.
+	DenialReasonUnknown DenialReason = "Unknown"
+)
+
+// DenialError is the error returned by Evaluate when a CertificateRequest
+// should be denied.
+type DenialError struct {
+	// Reason is the stable reason the request was denied.
+	Reason DenialReason
+
+	// Details is a human readable description of why the request was
+	// denied.
+	Details string
+
+	// err is the underlying error, if any.
+	err error
+}
+
+// denyf returns a DenialError with the given reason, and details formatted
+// according to the format specifier. Errors wrapped with %w are unwrapped by
+// the returned error.
+func denyf(reason DenialReason, format string, a ...any) error {
+	err := fmt.Errorf(format, a...)
+	return &DenialError{Reason: reason, Details: err.Error(), err: errors.Unwrap(err)}
+}
+
+// withReason returns err as a DenialError with the given reason, unless it
+// already is one.
+func withReason(reason DenialReason, err error) error {
+	var d *DenialError
+	if errors.As(err, &d) {
+		return err
+	}
+	return &DenialError{Reason: reason, Details: err.Error(), err: err}
+}
+
+func (d *DenialError) Error() string {
+	return d.Details
+}
+
+func (d *DenialError) Unwrap() error {
+	return d.err
+}
+
+// Reason returns the reason of an error returned by Evaluate. Returns
+// DenialReasonUnknown if the error is not a DenialError.
+func Reason(err error) DenialReason {
+	var d *DenialError
+	if errors.As(err, &d) {
+		return d.Reason
+	}
+	return DenialReasonUnknown
+}
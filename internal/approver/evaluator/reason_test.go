@@ -0,0 +1,71 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Reason(t *testing.T) {
+	underlying := errors.New("underlying error")
+
+	tests := map[string]struct {
+		err        error
+		expReason  DenialReason
+		expMessage string
+	}{
+		"if error is not a DenialError, expect Unknown": {
+			err:        underlying,
+			expReason:  DenialReasonUnknown,
+			expMessage: "underlying error",
+		},
+		"if error is a DenialError, expect its reason": {
+			err:        denyf(DenialReasonWrongUsages, "wrong usages: %v", []string{"a"}),
+			expReason:  DenialReasonWrongUsages,
+			expMessage: "wrong usages: [a]",
+		},
+		"if DenialError is wrapped, expect its reason": {
+			err:        fmt.Errorf("wrapped: %w", denyf(DenialReasonPodNotFound, "failed to get pod")),
+			expReason:  DenialReasonPodNotFound,
+			expMessage: "wrapped: failed to get pod",
+		},
+		"if withReason is given an error, expect the reason": {
+			err:        withReason(DenialReasonInvalidRequest, underlying),
+			expReason:  DenialReasonInvalidRequest,
+			expMessage: "underlying error",
+		},
+		"if withReason is given a DenialError, expect the original reason": {
+			err:        withReason(DenialReasonInvalidRequest, denyf(DenialReasonForbiddenKey, "forbidden key")),
+			expReason:  DenialReasonForbiddenKey,
+			expMessage: "forbidden key",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expReason, Reason(test.err))
+			assert.EqualError(t, test.err, test.expMessage)
+		})
+	}
+
+	t.Log("should unwrap the error wrapped by denyf")
+	assert.ErrorIs(t, denyf(DenialReasonPodNotFound, "failed to get pod: %w", underlying), underlying)
+}
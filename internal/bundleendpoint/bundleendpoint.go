@@ -0,0 +1,381 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bundleendpoint serves the trust bundle of the local trust domain as
+// a SPIFFE bundle endpoint, so that other trust domains can federate with it.
+package bundleendpoint
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/go-logr/logr"
+	"github.com/spiffe/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
+)
+
+// Options are options for the bundle endpoint Server.
+type Options struct {
+	// TrustDomain is the trust domain whose bundle is served.
+	TrustDomain string
+
+	// RootCAs provides the X.509 authorities of the trust domain bundle.
+	RootCAs rootca.Interface
+
+	// JWTAuthorities optionally provides the JWT authorities of the trust
+	// domain bundle, so that JWT-SVIDs can be verified with the served
+	// bundle.
+	JWTAuthorities JWTAuthorities
+
+	// Address is the TCP address the endpoint listens on.
+	Address string
+
+	// Profile is the bundle endpoint profile the endpoint is served with.
+	// Defaults to rootca.ProfileHTTPSWeb if empty.
+	Profile rootca.Profile
+
+	// CertificateFile, KeyFile are the file paths of the PEM encoded serving
+	// certificate chain and private key. The files are reloaded when they
+	// change. With rootca.ProfileHTTPSSPIFFE, the certificate must be an
+	// X509-SVID of the trust domain, issued by the served bundle.
+	CertificateFile, KeyFile string
+
+	// RefreshHint is the refresh hint set on the served bundle.
+	// Defaults to 5 minutes if zero.
+	RefreshHint time.Duration
+}
+
+// JWTAuthorities provides the public keys JWT-SVIDs of the trust domain are
+// signed with.
+type JWTAuthorities interface {
+	// Bundle returns the current JWT bundle of the trust domain.
+	Bundle() *jwtbundle.Bundle
+
+	// Subscribe returns a channel which will receive messages when the JWT
+	// bundle changes.
+	Subscribe() <-chan struct{}
+}
+
+// Server serves the trust domain bundle in SPIFFE bundle format over HTTPS.
+// The bundle sequence number is increased every time the bundle changes.
+type Server struct {
+	// log is the Server logger.
+	log logr.Logger
+
+	// trustDomain is the trust domain whose bundle is served.
+	trustDomain spiffeid.TrustDomain
+
+	// rootCAs provides the X.509 authorities of the served bundle.
+	rootCAs rootca.Interface
+
+	// jwtAuthorities provides the JWT authorities of the served bundle, if
+	// any.
+	jwtAuthorities JWTAuthorities
+
+	// address is the TCP address the endpoint listens on.
+	address string
+
+	// profile is the bundle endpoint profile the endpoint is served with.
+	profile rootca.Profile
+
+	// certificateFile, keyFile are the file paths of the serving certificate
+	// and key.
+	certificateFile, keyFile string
+
+	// refreshHint is the refresh hint set on the served bundle.
+	refreshHint time.Duration
+
+	// lock guards access to the fields below.
+	lock sync.RWMutex
+
+	// bundle is the current bundle, and bundleJSON its encoding.
+	bundle     *spiffebundle.Bundle
+	bundleJSON []byte
+
+	// sequenceNumber is the sequence number of the current bundle.
+	sequenceNumber uint64
+
+	// certificate is the loaded serving certificate, and certificateModTime
+	// the modification times of the certificate and key files it was loaded
+	// from.
+	certificate        *tls.Certificate
+	certificateModTime [2]time.Time
+}
+
+// New constructs a new bundle endpoint Server. The current bundle of rootCAs
+// is loaded straight away.
+func New(log logr.Logger, opts Options) (*Server, error) {
+	td, err := spiffeid.TrustDomainFromString(opts.TrustDomain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust domain %q: %w", opts.TrustDomain, err)
+	}
+
+	if opts.RootCAs == nil {
+		return nil, errors.New("root CAs are required to serve a bundle endpoint")
+	}
+
+	if len(opts.CertificateFile) == 0 || len(opts.KeyFile) == 0 {
+		return nil, errors.New("a serving certificate and key file are required to serve a bundle endpoint")
+	}
+
+	s := &Server{
+		log:             log.WithName("bundle-endpoint"),
+		trustDomain:     td,
+		rootCAs:         opts.RootCAs,
+		jwtAuthorities:  opts.JWTAuthorities,
+		address:         opts.Address,
+		profile:         opts.Profile,
+		certificateFile: opts.CertificateFile,
+		keyFile:         opts.KeyFile,
+		refreshHint:     opts.RefreshHint,
+	}
+
+	switch s.profile {
+	case "":
+		s.profile = rootca.ProfileHTTPSWeb
+	case rootca.ProfileHTTPSWeb, rootca.ProfileHTTPSSPIFFE:
+	default:
+		return nil, fmt.Errorf("unsupported bundle endpoint profile %q, must be %s or %s",
+			s.profile, rootca.ProfileHTTPSWeb, rootca.ProfileHTTPSSPIFFE)
+	}
+
+	if s.refreshHint == 0 {
+		s.refreshHint = time.Minute * 5
+	}
+
+	if err := s.updateBundle(); err != nil {
+		// The bundle may not be available yet; it is loaded again when it
+		// changes.
+		s.log.Error(err, "failed to load trust domain bundle")
+	}
+
+	return s, nil
+}
+
+// Start serves the bundle endpoint until the context is cancelled. Blocking
+// function.
+func (s *Server) Start(ctx context.Context) error {
+	watcher := s.rootCAs.Subscribe()
+
+	// A nil channel is never received from, so JWT authorities are only
+	// watched when configured.
+	var jwtWatcher <-chan struct{}
+	if s.jwtAuthorities != nil {
+		jwtWatcher = s.jwtAuthorities.Subscribe()
+	}
+
+	listener, err := tls.Listen("tcp", s.address, s.tlsConfig())
+	if err != nil {
+		return fmt.Errorf("failed to listen on %q: %w", s.address, err)
+	}
+
+	server := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: time.Second * 10,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+				defer cancel()
+				_ = server.Shutdown(shutdownCtx)
+				return
+
+			case <-watcher:
+				if err := s.updateBundle(); err != nil {
+					s.log.Error(err, "failed to update trust domain bundle")
+				}
+			case <-jwtWatcher:
+				if err := s.updateBundle(); err != nil {
+					s.log.Error(err, "failed to update trust domain bundle")
+				}
+			}
+		}
+	}()
+
+	s.log.Info("serving SPIFFE bundle endpoint", "address", listener.Addr().String(), "profile", s.profile)
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// NeedLeaderElection returns true so that only the leader serves the bundle
+// endpoint. Each replica numbers its bundles itself, so serving from more
+// than one could hand out sequence numbers which go backwards.
+func (s *Server) NeedLeaderElection() bool {
+	return true
+}
+
+// ServeHTTP serves the current bundle.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.lock.RLock()
+	bundleJSON := s.bundleJSON
+	s.lock.RUnlock()
+
+	if bundleJSON == nil {
+		http.Error(w, "trust domain bundle not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(bundleJSON)
+}
+
+// updateBundle rebuilds the served bundle from the current root CAs and JWT
+// authorities. The sequence number is increased if the authorities changed.
+// Sequence numbers are based on the current time so that they keep
+// increasing across restarts and leader changes. No bundle is served until
+// the root CA sources have valid certificates.
+func (s *Server) updateBundle() error {
+	certificatesPEM := s.rootCAs.CertificatesPEM()
+	if len(certificatesPEM) == 0 {
+		s.log.Info("no root CA certificates available yet, not serving trust domain bundle")
+		return nil
+	}
+
+	authorities, err := pki.DecodeX509CertificateSetBytes(certificatesPEM)
+	if err != nil {
+		return fmt.Errorf("failed to decode root CA certificates: %w", err)
+	}
+
+	bundle := spiffebundle.FromX509Authorities(s.trustDomain, authorities)
+
+	if s.jwtAuthorities != nil {
+		for keyID, key := range s.jwtAuthorities.Bundle().JWTAuthorities() {
+			if err := bundle.AddJWTAuthority(keyID, key); err != nil {
+				return fmt.Errorf("failed to add JWT authority %q: %w", keyID, err)
+			}
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.bundle != nil && s.bundle.X509Bundle().Equal(bundle.X509Bundle()) &&
+		s.bundle.JWTBundle().Equal(bundle.JWTBundle()) {
+		return nil
+	}
+
+	s.sequenceNumber = max(s.sequenceNumber+1, uint64(time.Now().Unix()))
+
+	bundle.SetRefreshHint(s.refreshHint)
+	bundle.SetSequenceNumber(s.sequenceNumber)
+
+	bundleJSON, err := bundle.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle: %w", err)
+	}
+
+	s.bundle, s.bundleJSON = bundle, bundleJSON
+
+	s.log.Info("serving updated trust domain bundle", "sequence_number", s.sequenceNumber)
+
+	return nil
+}
+
+// tlsConfig returns the TLS configuration the endpoint is served with.
+func (s *Server) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.servingCertificate()
+		},
+	}
+}
+
+// servingCertificate returns the serving certificate, reloading it from file
+// if either file changed since it was last loaded.
+func (s *Server) servingCertificate() (*tls.Certificate, error) {
+	var modTime [2]time.Time
+	for i, path := range []string{s.certificateFile, s.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat serving certificate file: %w", err)
+		}
+		modTime[i] = info.ModTime()
+	}
+
+	s.lock.RLock()
+	certificate, loadedModTime := s.certificate, s.certificateModTime
+	s.lock.RUnlock()
+
+	if certificate != nil && loadedModTime == modTime {
+		return certificate, nil
+	}
+
+	loaded, err := tls.LoadX509KeyPair(s.certificateFile, s.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load serving certificate: %w", err)
+	}
+
+	if s.profile == rootca.ProfileHTTPSSPIFFE {
+		if err := s.verifySVID(&loaded); err != nil {
+			return nil, err
+		}
+	}
+
+	s.lock.Lock()
+	s.certificate, s.certificateModTime = &loaded, modTime
+	s.lock.Unlock()
+
+	return &loaded, nil
+}
+
+// verifySVID returns an error if the serving certificate is not an X509-SVID
+// of the trust domain, issued by the current bundle.
+func (s *Server) verifySVID(certificate *tls.Certificate) error {
+	s.lock.RLock()
+	bundle := s.bundle
+	s.lock.RUnlock()
+
+	if bundle == nil {
+		return errors.New("cannot verify serving X509-SVID, trust domain bundle not available")
+	}
+
+	id, _, err := x509svid.ParseAndVerify(certificate.Certificate, bundle)
+	if err != nil {
+		return fmt.Errorf("serving certificate is not a valid X509-SVID: %w", err)
+	}
+
+	if !id.MemberOf(s.trustDomain) {
+		return fmt.Errorf("serving X509-SVID %q is not a member of trust domain %q", id, s.trustDomain)
+	}
+
+	return nil
+}
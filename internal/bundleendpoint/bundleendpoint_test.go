@@ -0,0 +1,345 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundleendpoint
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/federation"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/jwtsvid"
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
+)
+
+func testCA(t *testing.T, commonName string) (*x509.Certificate, crypto.Signer) {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: cmapi.CertificateSpec{CommonName: commonName, IsCA: true}})
+	require.NoError(t, err)
+
+	_, ca, err := utilpki.SignCertificate(tmpl, tmpl, pk.Public(), pk)
+	require.NoError(t, err)
+
+	return ca, pk
+}
+
+// writeServingCertificate signs a serving certificate for the given spec with
+// the CA, and writes it and its key to the returned files.
+func writeServingCertificate(t *testing.T, spec cmapi.CertificateSpec, ca *x509.Certificate, caKey crypto.Signer) (string, string) {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: spec})
+	require.NoError(t, err)
+
+	certPEM, _, err := utilpki.SignCertificate(tmpl, ca, pk.Public(), caKey)
+	require.NoError(t, err)
+
+	keyPEM, err := utilpki.EncodePKCS8PrivateKey(pk)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+
+	return certFile, keyFile
+}
+
+func testCertificatesPEM(t *testing.T, certs ...*x509.Certificate) []byte {
+	var out []byte
+	for _, cert := range certs {
+		certPEM, err := utilpki.EncodeX509(cert)
+		require.NoError(t, err)
+		out = append(out, certPEM...)
+	}
+	return out
+}
+
+// startServer serves the Server's handler with its TLS configuration on a
+// local listener, and returns the endpoint URL.
+func startServer(t *testing.T, s *Server) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig())
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: s, ReadHeaderTimeout: time.Second}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return "https://" + listener.Addr().String()
+}
+
+func Test_Server_httpsSPIFFE(t *testing.T) {
+	ca1, ca1Key := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+	td := spiffeid.RequireTrustDomainFromString("foo.bar")
+	endpointID := spiffeid.RequireFromString("spiffe://foo.bar/bundle-endpoint")
+
+	certFile, keyFile := writeServingCertificate(t, cmapi.CertificateSpec{URIs: []string{endpointID.String()}}, ca1, ca1Key)
+
+	rootCAsChan := make(chan []byte)
+	rootCAs := rootca.NewMemory(t.Context(), rootCAsChan)
+	rootCAsChan <- testCertificatesPEM(t, ca1)
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, testCertificatesPEM(t, ca1), rootCAs.CertificatesPEM())
+	}, time.Second*5, time.Millisecond*10)
+
+	s, err := New(ktesting.NewLogger(t, ktesting.DefaultConfig), Options{
+		TrustDomain:     "foo.bar",
+		RootCAs:         rootCAs,
+		Profile:         rootca.ProfileHTTPSSPIFFE,
+		CertificateFile: certFile,
+		KeyFile:         keyFile,
+		RefreshHint:     time.Minute,
+	})
+	require.NoError(t, err)
+	url := startServer(t, s)
+
+	t.Log("should serve the bundle with a refresh hint and sequence number, authenticated with an X509-SVID")
+	bundle, err := federation.FetchBundle(t.Context(), td, url,
+		federation.WithSPIFFEAuth(x509bundle.FromX509Authorities(td, []*x509.Certificate{ca1}), endpointID))
+	require.NoError(t, err)
+
+	assert.Equal(t, []*x509.Certificate{ca1}, bundle.X509Authorities())
+	refreshHint, ok := bundle.RefreshHint()
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, refreshHint)
+	sequenceNumber, ok := bundle.SequenceNumber()
+	assert.True(t, ok)
+
+	t.Log("should not increase the sequence number if the bundle is unchanged")
+	require.NoError(t, s.updateBundle())
+	assert.Equal(t, sequenceNumber, s.sequenceNumber)
+
+	t.Log("should increase the sequence number when the bundle changes")
+	rootCAsChan <- testCertificatesPEM(t, ca1, ca2)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.NoError(c, s.updateBundle())
+		bundle, err := federation.FetchBundle(t.Context(), td, url,
+			federation.WithSPIFFEAuth(x509bundle.FromX509Authorities(td, []*x509.Certificate{ca1}), endpointID))
+		if !assert.NoError(c, err) {
+			return
+		}
+		assert.Equal(c, []*x509.Certificate{ca1, ca2}, bundle.X509Authorities())
+		newSequenceNumber, _ := bundle.SequenceNumber()
+		assert.Greater(c, newSequenceNumber, sequenceNumber)
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func Test_Server_httpsSPIFFE_invalidSVID(t *testing.T) {
+	ca, caKey := testCA(t, "ca")
+	otherCA, otherCAKey := testCA(t, "other-ca")
+
+	tests := map[string]struct {
+		spec           cmapi.CertificateSpec
+		signer         *x509.Certificate
+		signerKey      crypto.Signer
+		expCertificate bool
+	}{
+		"X509-SVID of the trust domain should be served": {
+			spec:   cmapi.CertificateSpec{URIs: []string{"spiffe://foo.bar/bundle-endpoint"}},
+			signer: ca, signerKey: caKey,
+			expCertificate: true,
+		},
+		"X509-SVID of another trust domain should not be served": {
+			spec:   cmapi.CertificateSpec{URIs: []string{"spiffe://bar.foo/bundle-endpoint"}},
+			signer: ca, signerKey: caKey,
+		},
+		"X509-SVID not issued by the bundle should not be served": {
+			spec:   cmapi.CertificateSpec{URIs: []string{"spiffe://foo.bar/bundle-endpoint"}},
+			signer: otherCA, signerKey: otherCAKey,
+		},
+		"certificate without a SPIFFE ID should not be served": {
+			spec:   cmapi.CertificateSpec{DNSNames: []string{"bundle.foo.bar"}},
+			signer: ca, signerKey: caKey,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			certFile, keyFile := writeServingCertificate(t, test.spec, test.signer, test.signerKey)
+
+			rootCAsChan := make(chan []byte)
+			rootCAs := rootca.NewMemory(t.Context(), rootCAsChan)
+			rootCAsChan <- testCertificatesPEM(t, ca)
+			assert.EventuallyWithT(t, func(c *assert.CollectT) {
+				assert.NotEmpty(c, rootCAs.CertificatesPEM())
+			}, time.Second*5, time.Millisecond*10)
+
+			s, err := New(ktesting.NewLogger(t, ktesting.DefaultConfig), Options{
+				TrustDomain:     "foo.bar",
+				RootCAs:         rootCAs,
+				Profile:         rootca.ProfileHTTPSSPIFFE,
+				CertificateFile: certFile,
+				KeyFile:         keyFile,
+			})
+			require.NoError(t, err)
+
+			certificate, err := s.servingCertificate()
+			assert.Equalf(t, test.expCertificate, err == nil, "%v", err)
+			assert.Equal(t, test.expCertificate, certificate != nil)
+		})
+	}
+}
+
+func Test_Server_httpsWeb(t *testing.T) {
+	ca, caKey := testCA(t, "ca")
+	certFile, keyFile := writeServingCertificate(t, cmapi.CertificateSpec{DNSNames: []string{"example.com"}, IPAddresses: []string{"127.0.0.1"}}, ca, caKey)
+
+	rootCAsChan := make(chan []byte)
+	rootCAs := rootca.NewMemory(t.Context(), rootCAsChan)
+
+	s, err := New(ktesting.NewLogger(t, ktesting.DefaultConfig), Options{
+		TrustDomain:     "foo.bar",
+		RootCAs:         rootCAs,
+		CertificateFile: certFile,
+		KeyFile:         keyFile,
+	})
+	require.NoError(t, err)
+	assert.True(t, s.NeedLeaderElection(), "expected only the leader to serve the bundle endpoint")
+	url := startServer(t, s)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	td := spiffeid.RequireTrustDomainFromString("foo.bar")
+
+	t.Log("should fail to serve the bundle before it is available")
+	_, err = federation.FetchBundle(t.Context(), td, url, federation.WithWebPKIRoots(roots))
+	assert.Error(t, err)
+
+	t.Log("should serve the bundle once available, authenticated with Web PKI")
+	rootCAsChan <- testCertificatesPEM(t, ca)
+	require.Eventually(t, func() bool { return len(rootCAs.CertificatesPEM()) > 0 }, time.Second*5, time.Millisecond*10)
+	require.NoError(t, s.updateBundle())
+
+	bundle, err := federation.FetchBundle(t.Context(), td, url, federation.WithWebPKIRoots(roots))
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{ca}, bundle.X509Authorities())
+}
+
+func Test_Server_jwtAuthorities(t *testing.T) {
+	ca, caKey := testCA(t, "ca")
+	certFile, keyFile := writeServingCertificate(t, cmapi.CertificateSpec{DNSNames: []string{"example.com"}, IPAddresses: []string{"127.0.0.1"}}, ca, caKey)
+
+	rootCAsChan := make(chan []byte)
+	rootCAs := rootca.NewMemory(t.Context(), rootCAsChan)
+	rootCAsChan <- testCertificatesPEM(t, ca)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.NotEmpty(c, rootCAs.CertificatesPEM())
+	}, time.Second*5, time.Millisecond*10)
+
+	issuer, err := jwtsvid.NewIssuer("foo.bar")
+	require.NoError(t, err)
+	require.NoError(t, issuer.SetKey(testKeyPEM(t)))
+
+	s, err := New(ktesting.NewLogger(t, ktesting.DefaultConfig), Options{
+		TrustDomain:     "foo.bar",
+		RootCAs:         rootCAs,
+		JWTAuthorities:  issuer,
+		CertificateFile: certFile,
+		KeyFile:         keyFile,
+	})
+	require.NoError(t, err)
+	url := startServer(t, s)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	td := spiffeid.RequireTrustDomainFromString("foo.bar")
+
+	t.Log("should serve the JWT authorities alongside the X.509 authorities")
+	bundle, err := federation.FetchBundle(t.Context(), td, url, federation.WithWebPKIRoots(roots))
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{ca}, bundle.X509Authorities())
+	assert.Equal(t, issuer.Bundle().JWTAuthorities(), bundle.JWTAuthorities())
+	sequenceNumber, _ := bundle.SequenceNumber()
+
+	t.Log("should increase the sequence number when the JWT signing key is rotated")
+	require.NoError(t, issuer.SetKey(testKeyPEM(t)))
+	require.NoError(t, s.updateBundle())
+	bundle, err = federation.FetchBundle(t.Context(), td, url, federation.WithWebPKIRoots(roots))
+	require.NoError(t, err)
+	assert.Len(t, bundle.JWTAuthorities(), 2)
+	assert.Equal(t, issuer.Bundle().JWTAuthorities(), bundle.JWTAuthorities())
+	newSequenceNumber, _ := bundle.SequenceNumber()
+	assert.Greater(t, newSequenceNumber, sequenceNumber)
+}
+
+// testKeyPEM returns a new PEM encoded ECDSA private key.
+func testKeyPEM(t *testing.T) []byte {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	keyPEM, err := utilpki.EncodePKCS8PrivateKey(pk)
+	require.NoError(t, err)
+
+	return keyPEM
+}
+
+func Test_New_validation(t *testing.T) {
+	rootCAs := rootca.NewMemory(t.Context(), make(chan []byte))
+
+	tests := map[string]struct {
+		opts   Options
+		expErr bool
+	}{
+		"valid options should not error": {
+			opts: Options{TrustDomain: "foo.bar", RootCAs: rootCAs, CertificateFile: "tls.crt", KeyFile: "tls.key"},
+		},
+		"invalid trust domain should error": {
+			opts:   Options{TrustDomain: "Foo Bar", RootCAs: rootCAs, CertificateFile: "tls.crt", KeyFile: "tls.key"},
+			expErr: true,
+		},
+		"missing root CAs should error": {
+			opts:   Options{TrustDomain: "foo.bar", CertificateFile: "tls.crt", KeyFile: "tls.key"},
+			expErr: true,
+		},
+		"missing serving certificate should error": {
+			opts:   Options{TrustDomain: "foo.bar", RootCAs: rootCAs},
+			expErr: true,
+		},
+		"unknown profile should error": {
+			opts:   Options{TrustDomain: "foo.bar", RootCAs: rootCAs, CertificateFile: "tls.crt", KeyFile: "tls.key", Profile: "https_other"},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(ktesting.NewLogger(t, ktesting.DefaultConfig), test.opts)
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+		})
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package clustertrustbundle publishes the trust bundle of the local trust
+// domain as a Kubernetes ClusterTrustBundle, so that Kubernetes-native
+// consumers can project the same root CAs which are written to volumes.
+package clustertrustbundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/go-logr/logr"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
+)
+
+const (
+	// managedByLabelKey is the label set on published ClusterTrustBundles,
+	// with the driver name as value.
+	managedByLabelKey = "app.kubernetes.io/managed-by"
+)
+
+// Options are options for the ClusterTrustBundle Publisher.
+type Options struct {
+	// DriverName is the name of the driver, used as the domain of the
+	// signer name.
+	DriverName string
+
+	// TrustDomain is the trust domain whose bundle is published.
+	TrustDomain string
+
+	// RootCAs provides the root CA certificates of the trust domain.
+	RootCAs rootca.Interface
+
+	// Client is used to read and write the ClusterTrustBundle.
+	Client client.Client
+
+	// ResyncPeriod is how often the ClusterTrustBundle is re-synced, reverting
+	// any changes which were made to it. Defaults to 5 minutes if zero.
+	ResyncPeriod time.Duration
+}
+
+// Publisher keeps a ClusterTrustBundle in sync with the root CAs. The
+// ClusterTrustBundle uses the signer name "<driver name>/<trust domain>", and
+// is named after it.
+type Publisher struct {
+	// log is the Publisher logger.
+	log logr.Logger
+
+	// rootCAs provides the root CA certificates which are published.
+	rootCAs rootca.Interface
+
+	// client is used to read and write the ClusterTrustBundle.
+	client client.Client
+
+	// driverName is the value of the managed-by label.
+	driverName string
+
+	// signerName and name are the signer name and name of the
+	// ClusterTrustBundle.
+	signerName, name string
+
+	// resyncPeriod is how often the ClusterTrustBundle is re-synced.
+	resyncPeriod time.Duration
+}
+
+// New constructs a new ClusterTrustBundle Publisher.
+func New(log logr.Logger, opts Options) (*Publisher, error) {
+	td, err := spiffeid.TrustDomainFromString(opts.TrustDomain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust domain %q: %w", opts.TrustDomain, err)
+	}
+
+	if opts.RootCAs == nil {
+		return nil, errors.New("root CAs are required to publish a ClusterTrustBundle")
+	}
+
+	if len(opts.DriverName) == 0 {
+		return nil, errors.New("driver name is required to publish a ClusterTrustBundle")
+	}
+
+	signerName := SignerName(opts.DriverName, td.Name())
+
+	p := &Publisher{
+		rootCAs:      opts.RootCAs,
+		client:       opts.Client,
+		driverName:   opts.DriverName,
+		signerName:   signerName,
+		name:         strings.ReplaceAll(signerName, "/", ":") + ":bundle",
+		resyncPeriod: opts.ResyncPeriod,
+	}
+
+	if p.resyncPeriod == 0 {
+		p.resyncPeriod = time.Minute * 5
+	}
+
+	p.log = log.WithName("cluster-trust-bundle").WithValues("name", p.name, "signer_name", p.signerName)
+
+	return p, nil
+}
+
+// SignerName returns the signer name of the ClusterTrustBundle published for
+// the trust domain.
+func SignerName(driverName, trustDomain string) string {
+	return driverName + "/" + trustDomain
+}
+
+// NeedLeaderElection returns true so that only the leader writes the
+// ClusterTrustBundle.
+func (p *Publisher) NeedLeaderElection() bool {
+	return true
+}
+
+// Start keeps the ClusterTrustBundle in sync with the root CAs until ctx is
+// cancelled. The ClusterTrustBundle is synced when the root CAs change, and
+// every resync period.
+func (p *Publisher) Start(ctx context.Context) error {
+	rootCAsChanged := p.rootCAs.Subscribe()
+
+	ticker := time.NewTicker(p.resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		if err := p.sync(ctx); err != nil {
+			p.log.Error(err, "failed to sync ClusterTrustBundle, will retry")
+		}
+
+		select {
+		case <-ctx.Done():
+			p.log.Info("closing ClusterTrustBundle publisher")
+			return nil
+		case <-rootCAsChanged:
+			p.log.Info("root CAs changed, syncing ClusterTrustBundle")
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync creates or updates the ClusterTrustBundle so that it contains the
+// current root CAs. Nothing is published while there are no valid root CAs,
+// since ClusterTrustBundles must contain at least one certificate.
+func (p *Publisher) sync(ctx context.Context) error {
+	certificatesPEM := p.rootCAs.CertificatesPEM()
+	if len(certificatesPEM) == 0 {
+		p.log.V(2).Info("no root CAs available yet, not publishing ClusterTrustBundle")
+		return nil
+	}
+
+	certs, err := pki.DecodeX509CertificateSetBytes(certificatesPEM)
+	if err != nil {
+		return fmt.Errorf("failed to decode root CAs: %w", err)
+	}
+
+	// The API server rejects PEM block headers and inter-block data, so
+	// re-encode the certificates.
+	var trustBundle []byte
+	for _, cert := range certs {
+		certPEM, err := pki.EncodeX509(cert)
+		if err != nil {
+			return fmt.Errorf("failed to encode root CA: %w", err)
+		}
+		trustBundle = append(trustBundle, certPEM...)
+	}
+
+	var ctb certificatesv1beta1.ClusterTrustBundle
+	err = p.client.Get(ctx, client.ObjectKey{Name: p.name}, &ctb)
+	if apierrors.IsNotFound(err) {
+		ctb = certificatesv1beta1.ClusterTrustBundle{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   p.name,
+				Labels: map[string]string{managedByLabelKey: p.driverName},
+			},
+			Spec: certificatesv1beta1.ClusterTrustBundleSpec{
+				SignerName:  p.signerName,
+				TrustBundle: string(trustBundle),
+			},
+		}
+		if err := p.client.Create(ctx, &ctb); err != nil {
+			return fmt.Errorf("failed to create ClusterTrustBundle: %w", err)
+		}
+		p.log.Info("created ClusterTrustBundle")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get ClusterTrustBundle: %w", err)
+	}
+
+	if ctb.Spec.TrustBundle == string(trustBundle) && ctb.Labels[managedByLabelKey] == p.driverName {
+		return nil
+	}
+
+	if ctb.Labels == nil {
+		ctb.Labels = make(map[string]string)
+	}
+	ctb.Labels[managedByLabelKey] = p.driverName
+	ctb.Spec.TrustBundle = string(trustBundle)
+
+	if err := p.client.Update(ctx, &ctb); err != nil {
+		return fmt.Errorf("failed to update ClusterTrustBundle: %w", err)
+	}
+	p.log.Info("updated ClusterTrustBundle")
+
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustertrustbundle
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	"k8s.io/klog/v2/ktesting"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
+)
+
+func testCAPEM(t *testing.T, commonName string) []byte {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: cmapi.CertificateSpec{CommonName: commonName, IsCA: true}})
+	require.NoError(t, err)
+
+	caPEM, _, err := utilpki.SignCertificate(tmpl, tmpl, pk.Public(), pk)
+	require.NoError(t, err)
+
+	return caPEM
+}
+
+func Test_Publisher(t *testing.T) {
+	ca1PEM := testCAPEM(t, "ca-1")
+	ca2PEM := testCAPEM(t, "ca-2")
+
+	rootCAsChan := make(chan []byte)
+	rootCAs := rootca.NewMemory(t.Context(), rootCAsChan)
+	k8sClient := fake.NewClientBuilder().Build()
+
+	p, err := New(ktesting.NewLogger(t, ktesting.DefaultConfig), Options{
+		DriverName:  "spiffe.csi.cert-manager.io",
+		TrustDomain: "foo.bar",
+		RootCAs:     rootCAs,
+		Client:      k8sClient,
+	})
+	require.NoError(t, err)
+	assert.True(t, p.NeedLeaderElection())
+
+	go func() { assert.NoError(t, p.Start(t.Context())) }()
+
+	getCTB := func(c *assert.CollectT) *certificatesv1beta1.ClusterTrustBundle {
+		var ctb certificatesv1beta1.ClusterTrustBundle
+		if !assert.NoError(c, k8sClient.Get(t.Context(), client.ObjectKey{Name: "spiffe.csi.cert-manager.io:foo.bar:bundle"}, &ctb)) {
+			return nil
+		}
+		return &ctb
+	}
+
+	t.Log("should publish the root CAs once they are available")
+	rootCAsChan <- ca1PEM
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		ctb := getCTB(c)
+		if ctb == nil {
+			return
+		}
+		assert.Equal(c, "spiffe.csi.cert-manager.io/foo.bar", ctb.Spec.SignerName)
+		assert.Equal(c, string(ca1PEM), ctb.Spec.TrustBundle)
+		assert.Equal(c, "spiffe.csi.cert-manager.io", ctb.Labels[managedByLabelKey])
+	}, time.Second*5, time.Millisecond*10)
+
+	t.Log("should update the ClusterTrustBundle when the root CAs change")
+	rootCAsChan <- append(append([]byte{}, ca1PEM...), ca2PEM...)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		ctb := getCTB(c)
+		if ctb == nil {
+			return
+		}
+		assert.Equal(c, string(ca1PEM)+string(ca2PEM), ctb.Spec.TrustBundle)
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func Test_New(t *testing.T) {
+	rootCAs := rootca.NewMemory(t.Context(), nil)
+
+	tests := map[string]struct {
+		opts   Options
+		expErr bool
+	}{
+		"valid options should not error": {
+			opts: Options{DriverName: "spiffe.csi.cert-manager.io", TrustDomain: "foo.bar", RootCAs: rootCAs},
+		},
+		"invalid trust domain should error": {
+			opts:   Options{DriverName: "spiffe.csi.cert-manager.io", TrustDomain: "Foo Bar", RootCAs: rootCAs},
+			expErr: true,
+		},
+		"missing root CAs should error": {
+			opts:   Options{DriverName: "spiffe.csi.cert-manager.io", TrustDomain: "foo.bar"},
+			expErr: true,
+		},
+		"missing driver name should error": {
+			opts:   Options{TrustDomain: "foo.bar", RootCAs: rootCAs},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(ktesting.NewLogger(t, ktesting.DefaultConfig), test.opts)
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+		})
+	}
+}
@@ -19,15 +19,21 @@ package app
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/app/options"
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/driver"
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/jwtsvid"
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/runtimeconfig"
+	"github.com/cert-manager/csi-driver-spiffe/internal/identity"
 	"github.com/cert-manager/csi-driver-spiffe/internal/version"
 )
 
@@ -51,25 +57,40 @@ func NewCommand(ctx context.Context) *cobra.Command {
 			log := opts.Logr.WithName("main")
 			log.Info("Starting driver", "version", version.VersionInfo())
 
-			var rootCA rootca.Interface
-			if len(opts.Volume.SourceCABundleFile) > 0 {
-				log.Info("using CA root bundle", "filepath", opts.Volume.SourceCABundleFile)
+			spiffeIDTemplate, err := identity.Parse(opts.CertManager.SPIFFEIDPathTemplate, opts.CertManager.SPIFFEIDPodLabels)
+			if err != nil {
+				return err
+			}
 
-				var err error
-				rootCA, err = rootca.NewFile(ctx, opts.Logr, opts.Volume.SourceCABundleFile)
+			sanitize := rootca.SanitizeOptions{DropExpired: opts.Volume.DropExpiredRootCAs}
+
+			var federatedCAs rootca.FederatedInterface
+			if len(opts.Volume.FederationConfigFile) > 0 {
+				log.Info("federating trust domains", "filepath", opts.Volume.FederationConfigFile)
+
+				endpoints, err := rootca.ReadFederationConfig(opts.Volume.FederationConfigFile)
 				if err != nil {
-					return fmt.Errorf("failed to build root CA: %w", err)
+					return err
+				}
+
+				federatedCAs, err = rootca.NewFederation(ctx, opts.Logr, rootca.FederationOptions{
+					Endpoints: endpoints,
+					Sanitize:  sanitize,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to build federation: %w", err)
 				}
-			} else {
-				log.Info("propagating root CA bundle disabled")
 			}
 
 			// Store the logger in the context so that packages using
-			// logr.FromContext can retrieve it.
+			// logr.FromContext can retrieve it, and use it for
+			// controller-runtime, which serves metrics.
 			ctx = logr.NewContext(ctx, opts.Logr)
+			ctrllog.SetLogger(opts.Logr)
 
 			var k8sClient client.WithWatch
-			if opts.CertManager.IssuanceConfigMapName != "" {
+			if opts.CertManager.IssuanceConfigMapName != "" || opts.JWT.SigningKeySecretName != "" || opts.Volume.SourceCABundleName != "" ||
+				opts.Volume.SourceClusterTrustBundleSignerName != "" || opts.Volume.SourceClusterTrustBundleSelector != "" {
 				var err error
 				k8sClient, err = client.NewWithWatch(opts.RestConfig, client.Options{})
 				if err != nil {
@@ -77,6 +98,81 @@ func NewCommand(ctx context.Context) *cobra.Command {
 				}
 			}
 
+			var rootCAs []rootca.Interface
+			for _, caBundleFile := range opts.Volume.SourceCABundleFiles {
+				log.Info("using CA root bundle", "filepath", caBundleFile)
+
+				rootCA, err := rootca.NewFile(ctx, opts.Logr, caBundleFile, sanitize)
+				if err != nil {
+					return fmt.Errorf("failed to build root CA: %w", err)
+				}
+				rootCAs = append(rootCAs, rootCA)
+			}
+
+			if len(opts.Volume.SourceCABundleName) > 0 {
+				log.Info("using CA root bundle", "kind", opts.Volume.SourceCABundleKind,
+					"name", opts.Volume.SourceCABundleName, "namespace", opts.Volume.SourceCABundleNamespace)
+
+				rootCA, err := rootca.NewObject(ctx, k8sClient, rootca.ObjectKind(opts.Volume.SourceCABundleKind), types.NamespacedName{
+					Name:      opts.Volume.SourceCABundleName,
+					Namespace: opts.Volume.SourceCABundleNamespace,
+				}, opts.Volume.SourceCABundleKey, sanitize)
+				if err != nil {
+					return fmt.Errorf("failed to build root CA: %w", err)
+				}
+				rootCAs = append(rootCAs, rootCA)
+			}
+
+			if len(opts.Volume.SourceClusterTrustBundleSignerName) > 0 || len(opts.Volume.SourceClusterTrustBundleSelector) > 0 {
+				log.Info("using CA root bundle from ClusterTrustBundles", "signer_name", opts.Volume.SourceClusterTrustBundleSignerName,
+					"selector", opts.Volume.SourceClusterTrustBundleSelector)
+
+				selector, err := labels.Parse(opts.Volume.SourceClusterTrustBundleSelector)
+				if err != nil {
+					return fmt.Errorf("invalid --source-cluster-trust-bundle-selector: %w", err)
+				}
+
+				rootCA, err := rootca.NewClusterTrustBundles(ctx, k8sClient, opts.Volume.SourceClusterTrustBundleSignerName, selector, sanitize)
+				if err != nil {
+					return fmt.Errorf("failed to build root CA: %w", err)
+				}
+				rootCAs = append(rootCAs, rootCA)
+			}
+
+			var rootCA rootca.Interface
+			switch len(rootCAs) {
+			case 0:
+				log.Info("propagating root CA bundle disabled")
+
+			case 1:
+				rootCA = rootCAs[0]
+
+			default:
+				log.Info("merging CA root bundles", "sources", len(rootCAs))
+
+				rootCA, err = rootca.NewMerge(ctx, opts.Logr, rootCAs, sanitize)
+				if err != nil {
+					return fmt.Errorf("failed to build root CA: %w", err)
+				}
+			}
+
+			if rootCA != nil && opts.Volume.RootCARetentionPeriod > 0 {
+				stateFile := opts.Volume.RootCARetentionStateFile
+				if len(stateFile) == 0 {
+					stateFile = filepath.Join(opts.Driver.DataRoot, "ca-retention.json")
+				}
+
+				log.Info("retaining removed root CAs", "period", opts.Volume.RootCARetentionPeriod, "state_file", stateFile)
+
+				rootCA, err = rootca.NewRetain(ctx, opts.Logr, rootCA, rootca.RetainOptions{
+					Period:    opts.Volume.RootCARetentionPeriod,
+					StateFile: stateFile,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to build root CA: %w", err)
+				}
+			}
+
 			rtConfig, err := runtimeconfig.New(ctx, k8sClient, runtimeconfig.Options{
 				StaticConfig: runtimeconfig.Config{IssuerRef: opts.CertManager.IssuerRef},
 				DynamicConfig: runtimeconfig.DynamicConfig{
@@ -88,24 +184,54 @@ func NewCommand(ctx context.Context) *cobra.Command {
 				return err
 			}
 
+			var jwtIssuer *jwtsvid.Issuer
+			if opts.JWT.SigningKeySecretName != "" {
+				log.Info("issuing JWT-SVIDs", "secret-name", opts.JWT.SigningKeySecretName,
+					"secret-namespace", opts.JWT.SigningKeySecretNamespace)
+
+				jwtIssuer, err = jwtsvid.NewFromSecret(ctx, k8sClient, opts.CertManager.TrustDomain, types.NamespacedName{
+					Name:      opts.JWT.SigningKeySecretName,
+					Namespace: opts.JWT.SigningKeySecretNamespace,
+				}, opts.JWT.SigningKeySecretKey)
+				if err != nil {
+					return fmt.Errorf("failed to build JWT-SVID issuer: %w", err)
+				}
+			}
+
 			driver, err := driver.New(ctx, opts.Logr, driver.Options{
 				DriverName: opts.DriverName,
 				NodeID:     opts.Driver.NodeID,
 				Endpoint:   opts.Driver.Endpoint,
 				DataRoot:   opts.Driver.DataRoot,
 
+				MetricsBindAddress: opts.Driver.MetricsAddress,
+
 				RestConfig:                    opts.RestConfig,
 				TrustDomain:                   opts.CertManager.TrustDomain,
+				SPIFFEIDTemplate:              spiffeIDTemplate,
+				ClusterName:                   opts.CertManager.ClusterName,
 				CertificateRequestAnnotations: opts.CertManager.CertificateRequestAnnotations,
 				CertificateRequestDuration:    opts.CertManager.CertificateRequestDuration,
+				RenewBefore:                   opts.CertManager.RenewBefore,
+				RenewalJitter:                 opts.CertManager.RenewalJitter,
 
 				CertificateFileName: opts.Volume.CertificateFileName,
 				KeyFileName:         opts.Volume.KeyFileName,
 
-				CAFileName:           opts.Volume.CAFileName,
-				RootCAs:              rootCA,
-				RuntimeConfig:        rtConfig,
-				UseOwnServiceAccount: opts.Driver.UseOwnServiceAccount,
+				CAFileName:                opts.Volume.CAFileName,
+				RootCAs:                   rootCA,
+				SPIFFEBundleFileName:      opts.Volume.SPIFFEBundleFileName,
+				SPIFFEBundleRefreshHint:   opts.Volume.SPIFFEBundleRefreshHint,
+				FederatedCAs:              federatedCAs,
+				FederatedCAFileNamePrefix: opts.Volume.FederatedCAFileNamePrefix,
+				RuntimeConfig:             rtConfig,
+				UseOwnServiceAccount:      opts.Driver.UseOwnServiceAccount,
+				WorkloadAPISocketName:     opts.Volume.WorkloadAPISocketName,
+
+				JWTIssuer:         jwtIssuer,
+				JWTSVIDDuration:   opts.JWT.SVIDDuration,
+				JWTSVIDFileName:   opts.JWT.SVIDFileName,
+				JWTBundleFileName: opts.JWT.BundleFileName,
 			})
 			if err != nil {
 				return err
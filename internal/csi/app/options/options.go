@@ -23,6 +23,7 @@ import (
 	"github.com/spf13/pflag"
 
 	"github.com/cert-manager/csi-driver-spiffe/internal/flags"
+	"github.com/cert-manager/csi-driver-spiffe/internal/identity"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
@@ -40,6 +41,9 @@ type Options struct {
 
 	// Volume are options specific to mounted volumes.
 	Volume OptionsVolume
+
+	// JWT are options specific to JWT-SVID issuance.
+	JWT OptionsJWT
 }
 
 // OptionsDriver are options specific to the CSI driver itself.
@@ -53,6 +57,11 @@ type OptionsDriver struct {
 	// Endpoint is the endpoint which is used to listen for gRPC requests.
 	Endpoint string
 
+	// MetricsAddress is the TCP address for exposing HTTP Prometheus metrics
+	// which will be served on the HTTP path '/metrics'. The value "0" will
+	// disable exposing metrics.
+	MetricsAddress string
+
 	// UseOwnServiceAccount, when true, causes the driver to create
 	// CertificateRequests using its own ServiceAccount credentials rather than
 	// impersonating the mounting pod's ServiceAccount. When enabled, the
@@ -73,6 +82,18 @@ type OptionsCertManager struct {
 	// appear in signed certificate's URI SANs.
 	TrustDomain string
 
+	// SPIFFEIDPathTemplate is the template used to build the path of the
+	// SPIFFE ID requested for mounting pods.
+	SPIFFEIDPathTemplate string
+
+	// SPIFFEIDPodLabels are the pod label keys which may be referenced in
+	// SPIFFEIDPathTemplate.
+	SPIFFEIDPodLabels []string
+
+	// ClusterName is the name of the cluster which may be referenced in
+	// SPIFFEIDPathTemplate.
+	ClusterName string
+
 	// CertificateRequestAnnotations are annotations that are to be added to certificate requests created by the driver
 	CertificateRequestAnnotations map[string]string
 
@@ -80,6 +101,14 @@ type OptionsCertManager struct {
 	// requested with.
 	CertificateRequestDuration time.Duration
 
+	// RenewBefore is how long before expiry certificates are renewed, either
+	// as a duration or a fraction of the certificate's lifetime.
+	RenewBefore string
+
+	// RenewalJitter is the maximum fraction of the certificate's lifetime
+	// that renewals are randomly moved earlier by.
+	RenewalJitter float64
+
 	// IssuerRef is the IssuerRef used when creating CertificateRequests.
 	IssuerRef cmmeta.IssuerReference
 }
@@ -96,14 +125,92 @@ type OptionsVolume struct {
 	KeyFileName string
 
 	// FileName is the name of the file that the root CA certificates will be
-	// written to inside the Pod's volume. Ignored if SourceCABundleFile is not
+	// written to inside the Pod's volume. Ignored if no root CA source is
 	// defined.
 	CAFileName string
 
-	// SourceCABundleFile is the file path location containing a bundle of PEM
-	// encoded X.509 root CA certificates that will be written to managed volumes
-	// at the CSICAFileName path. No CAs will be written if this is empty.
-	SourceCABundleFile string
+	// SourceCABundleFiles are the file path locations containing bundles of
+	// PEM encoded X.509 root CA certificates that will be written to managed
+	// volumes at the CSICAFileName path. The root CAs of all sources are
+	// merged. No CAs will be written if no source is defined.
+	SourceCABundleFiles []string
+
+	// SourceCABundleKind is the kind of object, ConfigMap or Secret, which is
+	// watched through the API for root CA certificates.
+	SourceCABundleKind string
+
+	// SourceCABundleName, SourceCABundleNamespace, SourceCABundleKey are the
+	// name, namespace and data key of the object containing the root CA
+	// certificates. No object is watched if SourceCABundleName is empty.
+	SourceCABundleName, SourceCABundleNamespace, SourceCABundleKey string
+
+	// SourceClusterTrustBundleSignerName and SourceClusterTrustBundleSelector
+	// select the ClusterTrustBundles which are watched through the API for
+	// root CA certificates. No ClusterTrustBundles are watched if both are
+	// empty.
+	SourceClusterTrustBundleSignerName, SourceClusterTrustBundleSelector string
+
+	// DropExpiredRootCAs drops expired certificates from the root CAs before
+	// they are written to volumes.
+	DropExpiredRootCAs bool
+
+	// RootCARetentionPeriod is how long root CA certificates continue to be
+	// written to volumes after they are removed from the root CA sources.
+	// Removed root CAs are not retained if zero.
+	RootCARetentionPeriod time.Duration
+
+	// RootCARetentionStateFile is the file path the retained root CAs are
+	// persisted to. Defaults to "ca-retention.json" in the data root if empty.
+	RootCARetentionStateFile string
+
+	// WorkloadAPISocketName is the file name of a SPIFFE Workload API socket
+	// served inside each Pod's volume. The Workload API is not served if
+	// empty. Requires a root CA source.
+	WorkloadAPISocketName string
+
+	// SPIFFEBundleFileName is the name of the file that the root CA
+	// certificates will be written to inside the Pod's volume in SPIFFE
+	// bundle format. No SPIFFE bundle is written if empty.
+	SPIFFEBundleFileName string
+
+	// SPIFFEBundleRefreshHint is the refresh hint set on the SPIFFE bundle
+	// written to volumes.
+	SPIFFEBundleRefreshHint time.Duration
+
+	// FederationConfigFile is the file path location of a config file listing
+	// the SPIFFE bundle endpoints of federated trust domains. The bundles of
+	// federated trust domains are not written if empty.
+	FederationConfigFile string
+
+	// FederatedCAFileNamePrefix is the prefix of the file names that the
+	// bundles of federated trust domains will be written to inside the Pod's
+	// volume.
+	FederatedCAFileNamePrefix string
+}
+
+// OptionsJWT is options specific to JWT-SVID issuance.
+type OptionsJWT struct {
+	// SigningKeySecretName is the name of a Secret containing the trust domain
+	// JWT signing key. JWT-SVIDs are not issued if empty.
+	SigningKeySecretName string
+
+	// SigningKeySecretNamespace is the namespace of the signing key Secret.
+	SigningKeySecretNamespace string
+
+	// SigningKeySecretKey is the key in the Secret data holding the PEM
+	// encoded private key.
+	SigningKeySecretKey string
+
+	// SVIDDuration is the lifetime of issued JWT-SVIDs.
+	SVIDDuration time.Duration
+
+	// SVIDFileName is the name of the file that JWT-SVIDs will be written to
+	// inside the Pod's volume.
+	SVIDFileName string
+
+	// BundleFileName is the name of the file that the trust domain JWT bundle
+	// will be written to inside the Pod's volume.
+	BundleFileName string
 }
 
 func New() *Options {
@@ -111,7 +218,8 @@ func New() *Options {
 	o.Flags = flags.New().
 		Add("Driver", o.addDriverFlags).
 		Add("cert-manager", o.addCertManagerFlags).
-		Add("Volume", o.addVolumeFlags)
+		Add("Volume", o.addVolumeFlags).
+		Add("JWT-SVID", o.addJWTFlags)
 
 	return o
 }
@@ -123,6 +231,9 @@ func (o *Options) addDriverFlags(fs *pflag.FlagSet) {
 		"Path to the in-memory data directory used to store data.")
 	fs.StringVar(&o.Driver.Endpoint, "endpoint", "",
 		"Path to the unix socket used to listen for gRPC requests.")
+	fs.StringVar(&o.Driver.MetricsAddress, "metrics-bind-address", "0",
+		"TCP address for exposing HTTP Prometheus metrics which will be served on the "+
+			"HTTP path '/metrics'. The value \"0\" will disable exposing metrics.")
 	fs.BoolVar(&o.Driver.UseOwnServiceAccount, "use-own-service-account", false,
 		"When true, the driver creates CertificateRequests using its own "+
 			"ServiceAccount credentials rather than impersonating the mounting pod's "+
@@ -137,8 +248,27 @@ func (o *Options) addCertManagerFlags(fs *pflag.FlagSet) {
 
 	fs.StringVar(&o.CertManager.TrustDomain, "trust-domain", "cluster.local",
 		"The trust domain that will be requested for on created CertificateRequests.")
+	fs.StringVar(&o.CertManager.SPIFFEIDPathTemplate, "spiffe-id-path-template", identity.DefaultPathTemplate,
+		"Template of the SPIFFE ID path that will be requested for on created CertificateRequests. "+
+			"Must match the template configured on the approver. Supports the placeholders "+
+			"{trust-domain}, {namespace}, {service-account}, {cluster-name} and {pod-label:<key>}.")
+	fs.StringSliceVar(&o.CertManager.SPIFFEIDPodLabels, "spiffe-id-pod-labels", nil,
+		"List of pod label keys which may be referenced in --spiffe-id-path-template. "+
+			"Only list labels which cannot be set by untrusted users of a ServiceAccount.")
+	fs.StringVar(&o.CertManager.ClusterName, "cluster-name", "",
+		"Name of the cluster, substituted for {cluster-name} in --spiffe-id-path-template.")
 	fs.DurationVar(&o.CertManager.CertificateRequestDuration, "certificate-request-duration", time.Hour,
-		"The duration that created CertificateRequests will use.")
+		"The duration that created CertificateRequests will use. Can be overridden per volume with the "+
+			"\"spiffe.csi.cert-manager.io/certificate-duration\" attribute, within the range allowed by the approver.")
+
+	fs.StringVar(&o.CertManager.RenewBefore, "renew-before", "",
+		"How long before expiry certificates are renewed, either as a duration (e.g. '20m') "+
+			"or a fraction of the certificate's lifetime (e.g. '0.25'). Can be overridden per volume "+
+			"with the \"spiffe.csi.cert-manager.io/renew-before\" attribute. If undefined, "+
+			"certificates are renewed 2/3rds of the way through their lifetime.")
+	fs.Float64Var(&o.CertManager.RenewalJitter, "renewal-jitter", 0,
+		"Maximum fraction of the certificate's lifetime that renewals are randomly moved earlier by, "+
+			"to spread out renewals of certificates issued at the same time. Must be at least 0 and less than 1.")
 
 	fs.StringToStringVar(&o.CertManager.CertificateRequestAnnotations, "extra-certificate-request-annotations", map[string]string{},
 		"Comma-separated list of extra annotations to add to certificate requests e.g '--extra-certificate-request-annotations=hello=world,test=annotation'")
@@ -159,8 +289,81 @@ func (o *Options) addVolumeFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Volume.CAFileName, "file-name-ca", "ca.crt",
 		"The file name that the certificate's private key will be written to within the pod's volume directory.")
 
-	fs.StringVar(&o.Volume.SourceCABundleFile, "source-ca-bundle", "",
-		"File path that is read by the driver which will be written to all managed "+
-			"volumes to the file location inside volumes defined in --file-name-ca. If "+
-			"undefined, no CA file is written to volumes.")
+	fs.StringSliceVar(&o.Volume.SourceCABundleFiles, "source-ca-bundle", nil,
+		"File paths that are read by the driver which will be written to all managed "+
+			"volumes to the file location inside volumes defined in --file-name-ca. May be "+
+			"given multiple times, in which case the certificates of all root CA sources are "+
+			"merged in order with duplicates removed. A directory path reads all \"*.pem\" files "+
+			"in it as one bundle. If no root CA source is defined, no CA file is written to volumes.")
+
+	fs.StringVar(&o.Volume.SourceCABundleKind, "source-ca-bundle-kind", "ConfigMap",
+		"Kind of the object, ConfigMap or Secret, named by --source-ca-bundle-name.")
+	fs.StringVar(&o.Volume.SourceCABundleName, "source-ca-bundle-name", "",
+		"Name of a ConfigMap or Secret which is watched through the API for the root CA "+
+			"certificates that will be written to all managed volumes, merged with any other "+
+			"root CA sources. The last valid certificates are kept if the object is deleted or invalid.")
+	fs.StringVar(&o.Volume.SourceCABundleNamespace, "source-ca-bundle-namespace", "",
+		"Namespace of the object named by --source-ca-bundle-name.")
+	fs.StringVar(&o.Volume.SourceCABundleKey, "source-ca-bundle-key", "ca.crt",
+		"Key in the data of the object named by --source-ca-bundle-name which contains the "+
+			"PEM encoded root CA certificates.")
+	fs.StringVar(&o.Volume.SourceClusterTrustBundleSignerName, "source-cluster-trust-bundle-signer-name", "",
+		"Signer name of the ClusterTrustBundles which are watched through the API for the root CA "+
+			"certificates that will be written to all managed volumes, merged with any other "+
+			"root CA sources. The certificates of all matching ClusterTrustBundles are deduplicated and concatenated.")
+	fs.StringVar(&o.Volume.SourceClusterTrustBundleSelector, "source-cluster-trust-bundle-selector", "",
+		"Label selector of the ClusterTrustBundles which are watched through the API for the root CA "+
+			"certificates, optionally combined with --source-cluster-trust-bundle-signer-name.")
+
+	fs.BoolVar(&o.Volume.DropExpiredRootCAs, "drop-expired-root-cas", false,
+		"Drop expired certificates from the root CA certificates before they are written to volumes. "+
+			"Non-certificate PEM blocks and duplicate certificates are always dropped.")
+
+	fs.DurationVar(&o.Volume.RootCARetentionPeriod, "root-ca-retention-period", 0,
+		"How long a root CA certificate continues to be written to volumes after it is removed "+
+			"from the root CA sources, or until it expires, so that peers holding certificates "+
+			"issued by it have time to renew. If zero, removed root CAs are dropped straight away.")
+	fs.StringVar(&o.Volume.RootCARetentionStateFile, "root-ca-retention-state-file", "",
+		"File path the retained root CA certificates are persisted to, so that they survive "+
+			"restarts. Defaults to \"ca-retention.json\" in --data-root.")
+
+	fs.StringVar(&o.Volume.WorkloadAPISocketName, "workload-api-socket-name", "",
+		"The file name of a unix socket serving the SPIFFE Workload API within the pod's "+
+			"volume directory. If undefined, the Workload API is not served. Requires "+
+			"--source-ca-bundle.")
+
+	fs.StringVar(&o.Volume.SPIFFEBundleFileName, "file-name-spiffe-bundle", "",
+		"The file name that the root CA certificates will be written to within the pod's volume "+
+			"directory in SPIFFE trust bundle JSON format, e.g. 'bundle.spiffe.json'. If undefined, "+
+			"no SPIFFE bundle is written. Requires --source-ca-bundle.")
+	fs.DurationVar(&o.Volume.SPIFFEBundleRefreshHint, "spiffe-bundle-refresh-hint", time.Minute*5,
+		"The refresh hint set on the SPIFFE trust bundle written to volumes.")
+
+	fs.StringVar(&o.Volume.FederationConfigFile, "federation-config-file", "",
+		"File path of a YAML config file listing the SPIFFE bundle endpoints of federated "+
+			"trust domains, with the keys trustDomain, url, profile (https_web or https_spiffe), "+
+			"endpointSPIFFEID and bundleFile. Bundles are polled from each endpoint and written "+
+			"to all managed volumes. If undefined, no federated bundles are written.")
+	fs.StringVar(&o.Volume.FederatedCAFileNamePrefix, "file-name-federated-ca-prefix", "federated-",
+		"The prefix of the file names that federated trust domain bundles will be written to "+
+			"within the pod's volume directory, as '<prefix><trust-domain>.crt'.")
+}
+
+func (o *Options) addJWTFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.JWT.SigningKeySecretName, "jwt-signing-key-secret-name", "",
+		"Name of a Secret containing the PEM encoded private key used to sign JWT-SVIDs. "+
+			"The Secret is watched for key rotations. If undefined, JWT-SVIDs are not issued. "+
+			"The public keys are written to volumes, and are served to other verifiers by the "+
+			"approver's bundle endpoint with --bundle-endpoint-jwt-signing-key-secret-name.")
+	fs.StringVar(&o.JWT.SigningKeySecretNamespace, "jwt-signing-key-secret-namespace", "",
+		"Namespace of the Secret containing the JWT-SVID signing key.")
+	fs.StringVar(&o.JWT.SigningKeySecretKey, "jwt-signing-key-secret-key", "tls.key",
+		"Key in the data of the Secret which contains the JWT-SVID signing key.")
+	fs.DurationVar(&o.JWT.SVIDDuration, "jwt-svid-duration", time.Minute*5,
+		"The lifetime of issued JWT-SVIDs. JWT-SVIDs are refreshed 2/3rds of the way through their lifetime.")
+	fs.StringVar(&o.JWT.SVIDFileName, "file-name-jwt-svid", "jwt-svid.token",
+		"The file name that JWT-SVIDs will be written to within the pod's volume directory. "+
+			"Only written for volumes which set the \"spiffe.csi.cert-manager.io/jwt-audiences\" attribute.")
+	fs.StringVar(&o.JWT.BundleFileName, "file-name-jwt-bundle", "jwt-bundle.json",
+		"The file name that the trust domain JWT bundle will be written to within the pod's volume directory.")
 }
@@ -19,7 +19,14 @@ package driver
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cert-manager/csi-lib/storage"
@@ -29,6 +36,16 @@ import (
 	"github.com/cert-manager/csi-driver-spiffe/internal/version"
 )
 
+const (
+	// defaultCAUpdateWorkers is the default number of volumes which are
+	// updated concurrently.
+	defaultCAUpdateWorkers = 8
+
+	// maxCAUpdateRetryPeriod is the longest period failed volume updates are
+	// retried with.
+	maxCAUpdateRetryPeriod = time.Minute * 5
+)
+
 // camanager is a process responsible for distributing trust bundles to
 // mounting pods.
 type camanager struct {
@@ -39,55 +56,148 @@ type camanager struct {
 	// system in order to read volumes back from mounted pods.
 	store *storage.Filesystem
 
+	// volumeLocks coordinates writes to volumes with the other writers, so
+	// that a renewed keypair is never overwritten by a CA file update.
+	volumeLocks *volumeLocks
+
 	// rootCAs exposes the current trust bundle to be propagated, and signals
 	// when a new trust bundle is available.
 	rootCAs rootca.Interface
 
+	// federatedCAs exposes the current trust bundles of federated trust
+	// domains to be propagated, and signals when any of them change.
+	federatedCAs rootca.FederatedInterface
+
+	// federatedCAFileNamePrefix is the prefix of the file names used when
+	// writing federated trust domain bundles to volumes. Each bundle is
+	// written to "<prefix><trust-domain>.crt".
+	federatedCAFileNamePrefix string
+
+	// spiffeBundle encodes the root CA certificates as a SPIFFE bundle file.
+	// No SPIFFE bundle file is written if nil.
+	spiffeBundle *spiffeBundleFile
+
 	// certFileName, keyFileName, caFileName are the names used when writing file
 	// to volumes.
 	certFileName, keyFileName, caFileName string
 
-	// updateRootCAFiles is a func to update all managed volumes with the current
+	// preserveFileNames are the names of any other files written to volumes,
+	// which must be preserved when updating the CA file.
+	preserveFileNames []string
+
+	// federatedCAFileNames are the names of all federated trust domain bundle
+	// files returned by federatedCAFiles, so that volumes still holding the
+	// bundle of a trust domain which is no longer federated are rewritten.
+	federatedCAFileNames     map[string]struct{}
+	federatedCAFileNamesLock sync.Mutex
+
+	// updateWorkers is the number of volumes which are updated concurrently.
+	// Defaults to defaultCAUpdateWorkers if zero.
+	updateWorkers int
+
+	// updateRootCAFiles is a func to update managed volumes with the current
 	// root CA certificates PEM. Used for testing.
-	updateRootCAFilesFn func() error
+	updateRootCAFilesFn func(volumeIDs []string) ([]string, error)
+
+	// lastSuccess is when the CA files of all managed volumes were last
+	// updated successfully, or when run started. Nil if not running.
+	lastSuccess atomic.Pointer[time.Time]
+
+	// trustBundleFingerprint is the fingerprint of the trust bundle last
+	// exposed as a metric. Only accessed by run.
+	trustBundleFingerprint string
 }
 
 // newCAManager constructs a new camanager which distributes new trust bundles
 // to mounted pods, as they are changed.
 func newCAManager(log logr.Logger,
 	store *storage.Filesystem,
+	volumeLocks *volumeLocks,
 	rootCAs rootca.Interface,
-	certFileName, keyFileName, caFileName string,
+	federatedCAs rootca.FederatedInterface,
+	spiffeBundle *spiffeBundleFile,
+	certFileName, keyFileName, caFileName, federatedCAFileNamePrefix string,
+	preserveFileNames []string,
 ) *camanager {
 	c := &camanager{
-		log:          log.WithName("ca-manager"),
-		store:        store,
-		rootCAs:      rootCAs,
-		certFileName: certFileName,
-		keyFileName:  keyFileName,
-		caFileName:   caFileName,
+		log:                       log.WithName("ca-manager"),
+		store:                     store,
+		volumeLocks:               volumeLocks,
+		rootCAs:                   rootCAs,
+		federatedCAs:              federatedCAs,
+		spiffeBundle:              spiffeBundle,
+		certFileName:              certFileName,
+		keyFileName:               keyFileName,
+		caFileName:                caFileName,
+		federatedCAFileNamePrefix: federatedCAFileNamePrefix,
+		preserveFileNames:         preserveFileNames,
 	}
 	c.updateRootCAFilesFn = c.updateRootCAFiles
 	return c
 }
 
-// run subscribes to events from the Root CAs provider, and updates all managed
-// volumes CA files accordingly. Exits early if rootCAs is not configured.
-// Blocking function.
+// run subscribes to events from the Root CAs and federated CAs providers, and
+// updates all managed volumes CA files accordingly. Volumes which fail to be
+// updated are retried with exponential backoff, starting at
+// updateRetryPeriod, without rewriting volumes which were updated. Exits
+// early if neither is configured. Blocking function.
 func (c *camanager) run(ctx context.Context, updateRetryPeriod time.Duration) {
 	// Exit straight away if root CAs haven't been configured.
-	if c.rootCAs == nil {
+	if c.rootCAs == nil && c.federatedCAs == nil {
 		c.log.Info("not running CA file manager, root CA certificates not configured")
 		return
 	}
 
-	watcher := c.rootCAs.Subscribe()
+	// A nil channel is never ready, so unconfigured providers never fire.
+	var watcher, federatedWatcher <-chan struct{}
+	if c.rootCAs != nil {
+		watcher = c.rootCAs.Subscribe()
+	}
+	if c.federatedCAs != nil {
+		federatedWatcher = c.federatedCAs.Subscribe()
+	}
 
 	c.log.Info("starting root CA file manager")
 
-	// updateChan is used to trigger an update of CA certificates of file of all
-	// managed volumes. Trigged by both RootCAs events, as well as retrying updates on errors.
-	updateChan := make(chan struct{}, 1)
+	now := time.Now()
+	c.lastSuccess.Store(&now)
+	c.observeTrustBundle()
+
+	var (
+		// retry fires when the failed volumes should be retried. Nil when
+		// there is nothing to retry.
+		retry <-chan time.Time
+
+		// retryVolumeIDs are the volumes to retry, or nil to retry all.
+		retryVolumeIDs []string
+
+		// backoff is how long to wait before the next retry.
+		backoff = updateRetryPeriod
+	)
+
+	update := func(volumeIDs []string) {
+		failed, err := c.updateRootCAFilesFn(volumeIDs)
+		switch {
+		case err != nil:
+			c.log.Error(err, "failed to update root CA files on managed volumes")
+			retryVolumeIDs = nil
+
+		case len(failed) > 0:
+			c.log.Error(errors.New("failed to update root CA files on some managed volumes"), "will retry failed volumes", "volumes", failed)
+			retryVolumeIDs = failed
+
+		default:
+			c.log.Info("updated root CA files on managed volumes")
+			now := time.Now()
+			c.lastSuccess.Store(&now)
+			retry, retryVolumeIDs, backoff = nil, nil, updateRetryPeriod
+			return
+		}
+
+		c.log.Info("retrying CA file update", "backoff", backoff)
+		retry = time.After(backoff)
+		backoff = min(backoff*2, maxCAUpdateRetryPeriod)
+	}
 
 	for {
 		select {
@@ -96,84 +206,256 @@ func (c *camanager) run(ctx context.Context, updateRetryPeriod time.Duration) {
 			return
 
 		case <-watcher:
-			updateChan <- struct{}{}
-
-		case <-updateChan:
 			c.log.Info("root CA file event received, updating managed volumes")
+			c.observeTrustBundle()
+			backoff = updateRetryPeriod
+			update(nil)
 
-			if err := c.updateRootCAFilesFn(); err != nil {
-				c.log.Error(err, "failed to update root CA files on managed volumes")
-
-				// Retry updating the root CA files.
-				go func() {
-					select {
-					// Wait before retrying.
-					case <-time.After(updateRetryPeriod):
-					case <-ctx.Done():
-						return
-					}
-					c.log.Error(err, "retrying CA file update...")
-					updateChan <- struct{}{}
-				}()
-
-				continue
-			}
+		case <-federatedWatcher:
+			c.log.Info("federated CA file event received, updating managed volumes")
+			backoff = updateRetryPeriod
+			update(nil)
 
-			c.log.Info("updated root CA files on managed volumes")
+		case <-retry:
+			update(retryVolumeIDs)
 		}
 	}
 }
 
-// updateRootCAFiles will update all managed volumes with the CA certificates
-// data returned from rootCAs.
-func (c *camanager) updateRootCAFiles() error {
+// lastSuccessTime returns when the CA files of all managed volumes were last
+// updated successfully, or when run started if they have not been yet. Zero
+// if run has not started.
+func (c *camanager) lastSuccessTime() time.Time {
+	if lastSuccess := c.lastSuccess.Load(); lastSuccess != nil {
+		return *lastSuccess
+	}
+	return time.Time{}
+}
+
+// observeTrustBundle exposes the fingerprint of the current root CAs as a
+// metric, incrementing the trust bundle version if it changed.
+func (c *camanager) observeTrustBundle() {
 	if c.rootCAs == nil {
-		// Exit early if rootCAs is not configured.
-		return nil
+		return
+	}
+
+	certificatesPEM := c.rootCAs.CertificatesPEM()
+	if len(certificatesPEM) == 0 {
+		return
+	}
+
+	sum := sha256.Sum256(certificatesPEM)
+	fingerprint := hex.EncodeToString(sum[:])
+	if fingerprint == c.trustBundleFingerprint {
+		return
+	}
+
+	c.trustBundleFingerprint = fingerprint
+	trustBundleInfo.Reset()
+	trustBundleInfo.WithLabelValues(fingerprint).Set(1)
+	trustBundleVersion.Inc()
+}
+
+// updateRootCAFiles will update managed volumes with the CA certificates data
+// returned from rootCAs and federatedCAs. If volumeIDs is nil all volumes are
+// updated, otherwise only those given which are still managed. Volumes are
+// updated concurrently, and a failure of one volume does not stop the others
+// from being updated. Returns the volumes which failed to be updated, and an
+// error if the volumes could not be listed.
+func (c *camanager) updateRootCAFiles(volumeIDs []string) ([]string, error) {
+	if c.rootCAs == nil && c.federatedCAs == nil {
+		// Exit early if no CAs are configured.
+		return nil, nil
 	}
 
 	log := c.log.WithName("ca-updater")
 	log.Info("Version", "info", version.VersionInfo())
 
-	volumeIDs, err := c.store.ListVolumes()
+	allVolumeIDs, err := c.store.ListVolumes()
 	if err != nil {
-		return fmt.Errorf("failed to list managed volumes: %w", err)
+		return nil, fmt.Errorf("failed to list managed volumes: %w", err)
 	}
 
+	if volumeIDs == nil {
+		volumeIDs = allVolumeIDs
+	} else {
+		// Volumes which have since been unpublished no longer need updating.
+		volumeIDs = slices.DeleteFunc(slices.Clone(volumeIDs), func(volumeID string) bool {
+			return !slices.Contains(allVolumeIDs, volumeID)
+		})
+	}
+
+	workers := c.updateWorkers
+	if workers <= 0 {
+		workers = defaultCAUpdateWorkers
+	}
+
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		failed []string
+		sem    = make(chan struct{}, workers)
+	)
+
 	for _, volumeID := range volumeIDs {
-		meta, err := c.store.ReadMetadata(volumeID)
-		if err != nil {
-			return fmt.Errorf("%q: failed to read metadata from volume: %w", volumeID, err)
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			if err := c.updateVolumeCAFiles(log, volumeID); err != nil {
+				log.Error(err, "failed to update CA files on volume", "volume", volumeID)
+				lock.Lock()
+				failed = append(failed, volumeID)
+				lock.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	slices.Sort(failed)
+
+	caManagerVolumes.WithLabelValues("current").Set(float64(len(allVolumeIDs) - len(failed)))
+	caManagerVolumes.WithLabelValues("failed").Set(float64(len(failed)))
+
+	return failed, nil
+}
+
+// updateVolumeCAFiles writes the current CA files to the volume, if they are
+// not already up to date. The volume's lock is held across reading back and
+// writing its files, so the certificate and key are always written back
+// exactly as they are on the volume, and never replaced by a stale keypair
+// if it is renewed concurrently. The CA files are built while holding the
+// lock, so they are never older than those written by a concurrent renewal.
+func (c *camanager) updateVolumeCAFiles(log logr.Logger, volumeID string) error {
+	unlock := c.volumeLocks.lockVolume(volumeID)
+	defer unlock()
+
+	meta, err := c.store.ReadMetadata(volumeID)
+	if err != nil {
+		return fmt.Errorf("%q: failed to read metadata from volume: %w", volumeID, err)
+	}
+
+	certData, err := c.store.ReadFile(volumeID, c.certFileName)
+	if err != nil {
+		return fmt.Errorf("%q: failed to read certificate file from volume to perform write: %w",
+			volumeID, err)
+	}
+	keyData, err := c.store.ReadFile(volumeID, c.keyFileName)
+	if err != nil {
+		return fmt.Errorf("%q: failed to read key file from volume to perform write: %w",
+			volumeID, err)
+	}
+
+	caFiles, err := c.caFiles()
+	if err != nil {
+		return err
+	}
+
+	// No need to re-write CA data again if it hasn't changed on file.
+	if c.caFilesUpToDate(volumeID, caFiles) {
+		return nil
+	}
+
+	data := map[string][]byte{
+		c.certFileName: certData,
+		c.keyFileName:  keyData,
+	}
+	maps.Copy(data, caFiles)
+	for _, name := range c.preserveFileNames {
+		if preserveData, err := c.store.ReadFile(volumeID, name); err == nil {
+			data[name] = preserveData
 		}
+	}
+
+	// Rebuild any requested truststores from the new CA data.
+	keystores, err := keystoreFiles(meta, keyData, certData, data[c.caFileName])
+	if err != nil {
+		return fmt.Errorf("%q: failed to build keystores: %w", volumeID, err)
+	}
+	maps.Copy(data, keystores)
+
+	if err := c.store.WriteFiles(meta, data); err != nil {
+		return fmt.Errorf("%q: failed to write new ca data to volume: %w",
+			volumeID, err)
+	}
+
+	log.Info("updated CA file on volume", "volume", volumeID)
 
-		certData, err := c.store.ReadFile(volumeID, c.certFileName)
+	return nil
+}
+
+// caFiles returns the current CA files to be written to volumes, keyed by
+// file name. The root CA files are not returned until the root CA sources
+// have valid certificates.
+func (c *camanager) caFiles() (map[string][]byte, error) {
+	files := c.federatedCAFiles()
+	if c.rootCAs == nil {
+		return files, nil
+	}
+
+	certificatesPEM := c.rootCAs.CertificatesPEM()
+	if len(certificatesPEM) == 0 {
+		return files, nil
+	}
+
+	files[c.caFileName] = certificatesPEM
+
+	if c.spiffeBundle != nil {
+		bundleFiles, err := c.spiffeBundle.files(files[c.caFileName])
 		if err != nil {
-			return fmt.Errorf("%q: failed to read certificate file from volume to perform write: %w",
-				volumeID, err)
+			return nil, err
 		}
-		keyData, err := c.store.ReadFile(volumeID, c.keyFileName)
-		if err != nil {
-			return fmt.Errorf("%q: failed to read key file from volume to perform write: %w",
-				volumeID, err)
+		maps.Copy(files, bundleFiles)
+	}
+
+	return files, nil
+}
+
+// federatedCAFiles returns the current bundle of each federated trust domain,
+// keyed by the file name it is written to in volumes. Returns an empty map if
+// federatedCAs is not configured.
+func (c *camanager) federatedCAFiles() map[string][]byte {
+	files := make(map[string][]byte)
+	if c.federatedCAs == nil {
+		return files
+	}
+
+	c.federatedCAFileNamesLock.Lock()
+	defer c.federatedCAFileNamesLock.Unlock()
+	if c.federatedCAFileNames == nil {
+		c.federatedCAFileNames = make(map[string]struct{})
+	}
+
+	for trustDomain, certificatesPEM := range c.federatedCAs.TrustDomainCertificatesPEM() {
+		name := c.federatedCAFileNamePrefix + trustDomain + ".crt"
+		files[name] = certificatesPEM
+		c.federatedCAFileNames[name] = struct{}{}
+	}
+
+	return files
+}
+
+// caFilesUpToDate returns true if every CA file in the volume matches the
+// given data, and the volume holds no bundle of a trust domain which is no
+// longer federated.
+func (c *camanager) caFilesUpToDate(volumeID string, caFiles map[string][]byte) bool {
+	for name, expData := range caFiles {
+		data, err := c.store.ReadFile(volumeID, name)
+		if err != nil || !bytes.Equal(data, expData) {
+			return false
 		}
+	}
 
-		// No need to re-write CA data again if it hasn't changed on file.
-		caData, err := c.store.ReadFile(volumeID, c.caFileName)
-		if err == nil && bytes.Equal(caData, c.rootCAs.CertificatesPEM()) {
+	c.federatedCAFileNamesLock.Lock()
+	defer c.federatedCAFileNamesLock.Unlock()
+	for name := range c.federatedCAFileNames {
+		if _, ok := caFiles[name]; ok {
 			continue
 		}
-
-		if err := c.store.WriteFiles(meta, map[string][]byte{
-			c.certFileName: certData,
-			c.keyFileName:  keyData,
-			c.caFileName:   c.rootCAs.CertificatesPEM(),
-		}); err != nil {
-			return fmt.Errorf("%q: failed to write new ca data to volume: %w",
-				volumeID, err)
+		if _, err := c.store.ReadFile(volumeID, name); err == nil {
+			return false
 		}
-
-		log.Info("updated CA file on volume", "volume", volumeID)
 	}
 
-	return nil
+	return true
 }
@@ -19,10 +19,14 @@ package driver
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/cert-manager/csi-lib/metadata"
+	"github.com/cert-manager/csi-lib/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"k8s.io/klog/v2/ktesting"
 
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
@@ -41,10 +45,10 @@ func Test_manageCAFiles(t *testing.T) {
 
 	t.Log("if root CAs update happens, expect updateRootCAFilesFn() to be called")
 	calledCtx, calledCancel := context.WithCancel(t.Context())
-	c.updateRootCAFilesFn = func() error {
+	c.updateRootCAFilesFn = func([]string) ([]string, error) {
 		t.Log("updateRootCAFilesFn() called")
 		calledCancel()
-		return nil
+		return nil, nil
 	}
 
 	t.Log("sending event to rootCAsChan")
@@ -57,18 +61,19 @@ func Test_manageCAFiles(t *testing.T) {
 		assert.Fail(t, "updateRootCAFilesFn() was not called in time")
 	}
 
-	t.Log("should call updateRootCAFilesFn() again if it fails")
+	t.Log("should call updateRootCAFilesFn() again for all volumes if it fails")
 	var i int
 	calledTwiceChan := make(chan struct{})
-	c.updateRootCAFilesFn = func() error {
+	c.updateRootCAFilesFn = func(volumeIDs []string) ([]string, error) {
 		if i == 0 {
 			i++
 			t.Log("returning error from updateRootCAFilesFn()")
-			return errors.New("this is an error")
+			return nil, errors.New("this is an error")
 		}
 		t.Log("returning nil from updateRootCAFilesFn()")
+		assert.Nil(t, volumeIDs, "expected all volumes to be retried")
 		close(calledTwiceChan)
-		return nil
+		return nil, nil
 	}
 
 	t.Log("sending another root CAs update")
@@ -80,4 +85,177 @@ func Test_manageCAFiles(t *testing.T) {
 	case <-time.After(time.Second * 5):
 		assert.Fail(t, "updateRootCAFilesFn() was not called twice in time")
 	}
+
+	t.Log("should retry only the volumes which failed, with backoff")
+	var calls [][]string
+	var callTimes []time.Time
+	calledThriceChan := make(chan struct{})
+	c.updateRootCAFilesFn = func(volumeIDs []string) ([]string, error) {
+		calls = append(calls, volumeIDs)
+		callTimes = append(callTimes, time.Now())
+		switch len(calls) {
+		case 1:
+			return []string{"vol-1", "vol-2"}, nil
+		case 2:
+			return []string{"vol-2"}, nil
+		default:
+			close(calledThriceChan)
+			return nil, nil
+		}
+	}
+
+	rootCAsChan <- []byte("yet another root cas")
+	select {
+	case <-calledThriceChan:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "updateRootCAFilesFn() was not called three times in time")
+	}
+	assert.Equal(t, [][]string{nil, {"vol-1", "vol-2"}, {"vol-2"}}, calls)
+	assert.GreaterOrEqual(t, callTimes[2].Sub(callTimes[1]), time.Millisecond*10, "expected the retry period to back off")
+}
+
+func Test_updateRootCAFiles(t *testing.T) {
+	rootCAsChan := make(chan []byte)
+	rootCAs := rootca.NewMemory(t.Context(), rootCAsChan)
+	rootCAsChan <- []byte("root cas")
+	assert.Eventually(t, func() bool { return len(rootCAs.CertificatesPEM()) > 0 }, time.Second, time.Millisecond*10)
+
+	store := storage.NewMemoryFS()
+	c := newCAManager(ktesting.NewLogger(t, ktesting.DefaultConfig), store, newVolumeLocks(), rootCAs, nil, nil,
+		"tls.crt", "tls.key", "ca.crt", "", nil)
+	c.updateWorkers = 2
+
+	var volumeIDs []string
+	for i := range 10 {
+		meta := metadata.Metadata{VolumeID: fmt.Sprintf("vol-%d", i)}
+		_, err := store.RegisterMetadata(meta)
+		require.NoError(t, err)
+		volumeIDs = append(volumeIDs, meta.VolumeID)
+
+		// vol-3 and vol-7 are broken, with no key file.
+		files := map[string][]byte{"tls.crt": []byte("crt")}
+		if i != 3 && i != 7 {
+			files["tls.key"] = []byte("key")
+		}
+		require.NoError(t, store.WriteFiles(meta, files))
+	}
+
+	t.Log("should update all volumes, and return those which failed")
+	failed, err := c.updateRootCAFiles(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vol-3", "vol-7"}, failed)
+
+	for _, volumeID := range volumeIDs {
+		caData, err := store.ReadFile(volumeID, "ca.crt")
+		if volumeID == "vol-3" || volumeID == "vol-7" {
+			assert.Error(t, err, "expected broken volume to not be updated")
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, []byte("root cas"), caData)
+	}
+
+	t.Log("should only update the given volumes which are still managed")
+	require.NoError(t, store.WriteFiles(metadata.Metadata{VolumeID: "vol-3"}, map[string][]byte{"tls.crt": []byte("crt"), "tls.key": []byte("key")}))
+	require.NoError(t, store.RemoveVolume("vol-7"))
+	failed, err = c.updateRootCAFiles([]string{"vol-3", "vol-7"})
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+
+	caData, err := store.ReadFile("vol-3", "ca.crt")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("root cas"), caData)
+}
+
+func Test_updateRootCAFiles_federated(t *testing.T) {
+	federatedCAs := &fakeFederatedCAs{
+		certificatesPEM: map[string][]byte{"foo.bar": []byte("foo.bar cas"), "bar.foo": []byte("bar.foo cas")},
+	}
+
+	store := storage.NewMemoryFS()
+	c := newCAManager(ktesting.NewLogger(t, ktesting.DefaultConfig), store, newVolumeLocks(), nil, federatedCAs, nil,
+		"tls.crt", "tls.key", "ca.crt", "federated-", nil)
+
+	meta := metadata.Metadata{VolumeID: "vol-1"}
+	_, err := store.RegisterMetadata(meta)
+	require.NoError(t, err)
+	require.NoError(t, store.WriteFiles(meta, map[string][]byte{"tls.crt": []byte("crt"), "tls.key": []byte("key")}))
+
+	t.Log("should write the bundle of each federated trust domain")
+	failed, err := c.updateRootCAFiles(nil)
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+
+	files, err := store.ReadFiles("vol-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"tls.crt":               []byte("crt"),
+		"tls.key":               []byte("key"),
+		"federated-foo.bar.crt": []byte("foo.bar cas"),
+		"federated-bar.foo.crt": []byte("bar.foo cas"),
+	}, files)
+
+	t.Log("should remove the bundle of a trust domain which is no longer federated")
+	federatedCAs.certificatesPEM = map[string][]byte{"foo.bar": []byte("foo.bar cas")}
+	failed, err = c.updateRootCAFiles(nil)
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+
+	files, err = store.ReadFiles("vol-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"tls.crt":               []byte("crt"),
+		"tls.key":               []byte("key"),
+		"federated-foo.bar.crt": []byte("foo.bar cas"),
+	}, files)
+}
+
+// fakeFederatedCAs is a rootca.FederatedInterface which fires an event for
+// each bundle map sent to it.
+type fakeFederatedCAs struct {
+	certificatesPEM map[string][]byte
+	events          chan struct{}
+}
+
+func (f *fakeFederatedCAs) TrustDomainCertificatesPEM() map[string][]byte {
+	return f.certificatesPEM
+}
+
+func (f *fakeFederatedCAs) Subscribe() <-chan struct{} {
+	return f.events
+}
+
+func Test_manageCAFiles_federated(t *testing.T) {
+	federatedCAs := &fakeFederatedCAs{
+		certificatesPEM: map[string][]byte{"foo.bar": []byte("foo.bar cas"), "bar.foo": []byte("bar.foo cas")},
+		events:          make(chan struct{}),
+	}
+
+	c := &camanager{
+		log:                       ktesting.NewLogger(t, ktesting.DefaultConfig),
+		federatedCAs:              federatedCAs,
+		federatedCAFileNamePrefix: "federated-",
+	}
+
+	t.Log("should key federated bundles by file name")
+	assert.Equal(t, map[string][]byte{
+		"federated-foo.bar.crt": []byte("foo.bar cas"),
+		"federated-bar.foo.crt": []byte("bar.foo cas"),
+	}, c.federatedCAFiles())
+
+	calledCtx, calledCancel := context.WithCancel(t.Context())
+	c.updateRootCAFilesFn = func([]string) ([]string, error) {
+		calledCancel()
+		return nil, nil
+	}
+
+	t.Log("should run without root CAs, and call updateRootCAFilesFn() on a federated event")
+	go c.run(t.Context(), time.Millisecond*5)
+	federatedCAs.events <- struct{}{}
+
+	select {
+	case <-calledCtx.Done():
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "updateRootCAFilesFn() was not called in time")
+	}
 }
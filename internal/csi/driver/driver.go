@@ -37,17 +37,47 @@ import (
 	"github.com/cert-manager/csi-lib/metadata"
 	"github.com/cert-manager/csi-lib/storage"
 	jose "github.com/go-jose/go-jose/v4"
-	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	"github.com/cert-manager/csi-driver-spiffe/internal/annotations"
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/jwtsvid"
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/runtimeconfig"
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/workloadapi"
+	"github.com/cert-manager/csi-driver-spiffe/internal/identity"
 	"github.com/cert-manager/csi-driver-spiffe/internal/version"
 )
 
+const (
+	// volumeContextPodName is the volume context key set by the kubelet to
+	// the name of the mounting pod.
+	volumeContextPodName = "csi.storage.k8s.io/pod.name"
+
+	// volumeContextKeyAlgorithm is the volume attribute selecting the private
+	// key algorithm; one of ECDSA, RSA or Ed25519. Defaults to ECDSA.
+	volumeContextKeyAlgorithm = "spiffe.csi.cert-manager.io/key-algorithm"
+
+	// volumeContextKeySize is the volume attribute selecting the private key
+	// size in bits. Defaults to the algorithm's smallest supported size.
+	volumeContextKeySize = "spiffe.csi.cert-manager.io/key-size"
+
+	// volumeContextRenewBefore is the volume attribute overriding how long
+	// before expiry the certificate is renewed, as a duration or a fraction of
+	// the certificate's lifetime.
+	volumeContextRenewBefore = "spiffe.csi.cert-manager.io/renew-before"
+
+	// volumeContextCertificateDuration is the volume attribute overriding the
+	// duration requested for the volume's certificate. The approver must allow
+	// the requested duration.
+	volumeContextCertificateDuration = "spiffe.csi.cert-manager.io/certificate-duration"
+)
+
 // Options holds the Options needed for the CSI driver.
 type Options struct {
 	// DriverName is the driver name as installed in Kubernetes.
@@ -62,18 +92,44 @@ type Options struct {
 	// Endpoint is the endpoint which is used to listen for gRPC requests.
 	Endpoint string
 
+	// MetricsBindAddress is the TCP address for exposing HTTP Prometheus
+	// metrics which will be served on the HTTP path '/metrics'. The value "0"
+	// or empty disables exposing metrics.
+	MetricsBindAddress string
+
 	// TrustDomain is the trust domain of this SPIFFE PKI. The TrustDomain will
 	// appear in signed certificate's URI SANs.
 	TrustDomain string
 
+	// SPIFFEIDTemplate is the template used to build the SPIFFE ID of mounting
+	// pods. Defaults to identity.DefaultPathTemplate if nil.
+	SPIFFEIDTemplate *identity.Template
+
+	// ClusterName is the name of the cluster, substituted into
+	// SPIFFEIDTemplate.
+	ClusterName string
+
 	// CertificateRequestAnnotations are annotations that are to be added to certificate requests created by the driver
 	CertificateRequestAnnotations map[string]string
 
 	// CertificateRequestDuration is the duration CertificateRequests will be
-	// requested with.
+	// requested with. May be overridden per volume with the
+	// "spiffe.csi.cert-manager.io/certificate-duration" volume attribute.
 	// Defaults to 1 hour if empty.
 	CertificateRequestDuration time.Duration
 
+	// RenewBefore is how long before expiry certificates are renewed, either
+	// as a duration (e.g. "20m") or a fraction of the certificate's lifetime
+	// (e.g. "0.25"). May be overridden per volume with the
+	// "spiffe.csi.cert-manager.io/renew-before" volume attribute.
+	// Defaults to 1/3rd of the certificate's lifetime if empty.
+	RenewBefore string
+
+	// RenewalJitter is the maximum fraction of the certificate's lifetime
+	// that renewals are randomly moved earlier by, to spread out renewals of
+	// certificates issued at the same time. No jitter is applied if zero.
+	RenewalJitter float64
+
 	// CertificateFileName is the name of the file that the signed certificate
 	// will be written to inside the Pod's volume.
 	// Default to `tls.crt` if empty.
@@ -98,13 +154,64 @@ type Options struct {
 	// file will be updated.
 	RootCAs rootca.Interface
 
+	// FederatedCAs is optionally used to write the root CA certificates of
+	// federated trust domains to Pod's volumes, next to the CAFileName file.
+	// Each trust domain's bundle is written to the file
+	// "<FederatedCAFileNamePrefix><trust-domain>.crt". If the bundle of any
+	// federated trust domain changes, all managed volume's files will be
+	// updated.
+	FederatedCAs rootca.FederatedInterface
+
+	// FederatedCAFileNamePrefix is the prefix of the file names federated
+	// trust domain bundles are written to inside the Pod's volume.
+	// Defaults to `federated-` if empty.
+	FederatedCAFileNamePrefix string
+
+	// SPIFFEBundleFileName is optionally the name of the file that the root CA
+	// certificates will be written to inside the Pod's volume, in SPIFFE
+	// trust bundle format. The bundle sequence number is increased every time
+	// the root CA certificates change. Ignored if RootCAs is nil. If empty, no
+	// SPIFFE bundle file is written.
+	SPIFFEBundleFileName string
+
+	// SPIFFEBundleRefreshHint is the refresh hint set on the SPIFFE bundle
+	// file. Defaults to 5 minutes if empty.
+	SPIFFEBundleRefreshHint time.Duration
+
 	// RuntimeConfig provides the current runtime configuration, including the
 	// issuer reference to use when creating CertificateRequests.
 	RuntimeConfig runtimeconfig.Interface
 
+	// WorkloadAPISocketName is the file name of a SPIFFE Workload API socket
+	// served inside each Pod's volume. If empty, the Workload API is not
+	// served. Requires RootCAs.
+	WorkloadAPISocketName string
+
+	// JWTIssuer is optionally used to write JWT-SVIDs to Pod's volumes which
+	// request audiences using the "spiffe.csi.cert-manager.io/jwt-audiences"
+	// volume attribute. If defined, the trust domain JWT bundle is written to
+	// all volumes at JWTBundleFileName. If nil, no JWT files are written.
+	JWTIssuer *jwtsvid.Issuer
+
+	// JWTSVIDDuration is the lifetime of JWT-SVIDs written to volumes.
+	// Defaults to 5 minutes if empty.
+	JWTSVIDDuration time.Duration
+
+	// JWTSVIDFileName is the name of the file that the JWT-SVID will be
+	// written to inside the Pod's volume.
+	// Defaults to `jwt-svid.token` if empty.
+	JWTSVIDFileName string
+
+	// JWTBundleFileName is the name of the file that the trust domain JWT
+	// bundle will be written to inside the Pod's volume.
+	// Defaults to `jwt-bundle.json` if empty.
+	JWTBundleFileName string
+
 	// UseOwnServiceAccount, when true, causes the driver to create
 	// CertificateRequests using its own ServiceAccount credentials rather than
-	// impersonating the mounting pod's ServiceAccount.
+	// impersonating the mounting pod's ServiceAccount. Mounting pods' tokens
+	// are then verified with a TokenReview, and must match the pod info in the
+	// volume context.
 	UseOwnServiceAccount bool
 }
 
@@ -118,6 +225,26 @@ type Driver struct {
 	// trustDomain is the trust domain that will form pod identities.
 	trustDomain string
 
+	// spiffeIDTemplate is the template used to build pod identities.
+	spiffeIDTemplate *identity.Template
+
+	// clusterName is the name of the cluster, substituted into
+	// spiffeIDTemplate.
+	clusterName string
+
+	// nodeName is the name of the node the driver is running on.
+	nodeName string
+
+	// kubeClient is used to look up mounting pods when spiffeIDTemplate
+	// references pod labels, and to review tokens when useOwnServiceAccount
+	// is true. Nil otherwise.
+	kubeClient kubernetes.Interface
+
+	// useOwnServiceAccount is true when CertificateRequests are created with
+	// the driver's own ServiceAccount, so mounting pods' tokens must be
+	// verified by the driver.
+	useOwnServiceAccount bool
+
 	// certificateRequestAnnotations are annotations that are to be added to certificate requests created by the driver
 	certificateRequestAnnotations map[string]string
 
@@ -125,14 +252,39 @@ type Driver struct {
 	// created CertificateRequests.
 	certificateRequestDuration time.Duration
 
+	// renewBefore is the default of how long before expiry certificates are
+	// renewed.
+	renewBefore renewBefore
+
+	// renewalJitter is the maximum fraction of the certificate's lifetime
+	// that renewals are randomly moved earlier by.
+	renewalJitter float64
+
 	// certFileName, keyFileName, caFileName are the names used when writing file
 	// to volumes.
 	certFileName, keyFileName, caFileName string
 
+	// reservedFileNames are the names of every file, other than keystores,
+	// written to volumes. Keystores may not be written with these names.
+	reservedFileNames []string
+
+	// federatedCAFileNamePrefix is the prefix of the names of federated CA
+	// files written to volumes. Empty if federated CAs are not configured.
+	federatedCAFileNamePrefix string
+
 	// rootCAs provides the root CA certificates to write to file. No CA file is
 	// written if this is nil.
 	rootCAs rootca.Interface
 
+	// federatedCAs provides the root CA certificates of federated trust
+	// domains to write to file. No federated CA files are written if this is
+	// nil.
+	federatedCAs rootca.FederatedInterface
+
+	// spiffeBundle encodes the root CA certificates as a SPIFFE bundle file.
+	// No SPIFFE bundle file is written if nil.
+	spiffeBundle *spiffeBundleFile
+
 	// runtimeConfig provides the current runtime configuration.
 	runtimeConfig runtimeconfig.Interface
 
@@ -142,9 +294,31 @@ type Driver struct {
 	// store is the csi-lib implementation of a cert-manager CSI storage manager.
 	store storage.Interface
 
+	// volumeLocks coordinates writes to volumes between keypair renewals and
+	// the CA and JWT managers.
+	volumeLocks *volumeLocks
+
 	// camanager is used to update all managed volumes with the current root CA
 	// certificates PEM.
 	camanager *camanager
+
+	// workloadAPI serves the SPIFFE Workload API inside managed volumes. Nil
+	// if not enabled.
+	workloadAPI *workloadapi.Manager
+
+	// jwtmanager writes and refreshes JWT-SVIDs in managed volumes. Nil if
+	// not enabled.
+	jwtmanager *jwtmanager
+
+	// issuance records metrics of certificate issuances.
+	issuance *issuanceTracker
+
+	// volumeMetrics exposes metrics of the managed volumes. Registered for
+	// as long as the driver runs.
+	volumeMetrics *volumeCollector
+
+	// metricsServer serves Prometheus metrics. Nil if not enabled.
+	metricsServer server.Server
 }
 
 // New constructs a new Driver instance.
@@ -156,17 +330,27 @@ func New(ctx context.Context, log logr.Logger, opts Options) (*Driver, error) {
 	}
 
 	d := &Driver{
-		log:          log.WithName("csi"),
-		trustDomain:  opts.TrustDomain,
+		log:                  log.WithName("csi"),
+		trustDomain:          opts.TrustDomain,
+		spiffeIDTemplate:     opts.SPIFFEIDTemplate,
+		clusterName:          opts.ClusterName,
+		nodeName:             opts.NodeID,
+		useOwnServiceAccount: opts.UseOwnServiceAccount,
+
 		certFileName: opts.CertificateFileName,
 		keyFileName:  opts.KeyFileName,
 
-		rootCAs: opts.RootCAs,
+		rootCAs:      opts.RootCAs,
+		federatedCAs: opts.FederatedCAs,
 
 		certificateRequestDuration:    opts.CertificateRequestDuration,
 		certificateRequestAnnotations: sanitizedAnnotations,
+		renewalJitter:                 opts.RenewalJitter,
 
 		runtimeConfig: opts.RuntimeConfig,
+
+		volumeLocks: newVolumeLocks(),
+		issuance:    newIssuanceTracker(),
 	}
 
 	if len(d.certFileName) == 0 {
@@ -181,10 +365,42 @@ func New(ctx context.Context, log logr.Logger, opts Options) (*Driver, error) {
 		d.caFileName = "ca.crt"
 	}
 
+	federatedCAFileNamePrefix := opts.FederatedCAFileNamePrefix
+	if len(federatedCAFileNamePrefix) == 0 {
+		federatedCAFileNamePrefix = "federated-"
+	}
+
 	if d.certificateRequestDuration == 0 {
 		d.certificateRequestDuration = time.Hour
 	}
 
+	d.renewBefore, err = parseRenewBefore(opts.RenewBefore)
+	if err != nil {
+		return nil, fmt.Errorf("invalid renew before: %w", err)
+	}
+
+	if d.renewalJitter < 0 || d.renewalJitter >= 1 {
+		return nil, fmt.Errorf("renewal jitter must be at least 0 and less than 1: %v", d.renewalJitter)
+	}
+
+	if opts.RootCAs != nil && len(opts.SPIFFEBundleFileName) > 0 {
+		d.spiffeBundle, err = newSPIFFEBundleFile(opts.TrustDomain, opts.SPIFFEBundleFileName, opts.SPIFFEBundleRefreshHint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if d.spiffeIDTemplate == nil {
+		d.spiffeIDTemplate = identity.Default()
+	}
+
+	if d.spiffeIDTemplate.UsesPodLabels() || d.useOwnServiceAccount {
+		d.kubeClient, err = kubernetes.NewForConfig(opts.RestConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build kubernetes client: %w", err)
+		}
+	}
+
 	store, err := storage.NewFilesystem(d.log, opts.DataRoot)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup filesystem: %w", err)
@@ -195,8 +411,52 @@ func New(ctx context.Context, log logr.Logger, opts Options) (*Driver, error) {
 	store.FSGroupVolumeAttributeKey = "spiffe.csi.cert-manager.io/fs-group"
 
 	d.store = store
-	d.camanager = newCAManager(log, store, opts.RootCAs,
-		opts.CertificateFileName, opts.KeyFileName, opts.CAFileName)
+
+	// Files written by other managers must be preserved when the CA file is
+	// updated.
+	var preserveFileNames []string
+	if opts.JWTIssuer != nil {
+		d.jwtmanager = newJWTManager(log, store, d.volumeLocks, opts.JWTIssuer, opts.JWTSVIDDuration,
+			d.certFileName, d.keyFileName, d.caFileName, opts.JWTSVIDFileName, opts.JWTBundleFileName)
+		preserveFileNames = append(preserveFileNames, d.jwtmanager.tokenFileName, d.jwtmanager.bundleFileName)
+	}
+
+	d.camanager = newCAManager(log, store, d.volumeLocks, opts.RootCAs, opts.FederatedCAs, d.spiffeBundle,
+		opts.CertificateFileName, opts.KeyFileName, opts.CAFileName, federatedCAFileNamePrefix, preserveFileNames)
+
+	// The JWT manager must write the current CA files when refreshing
+	// JWT-SVIDs.
+	if d.jwtmanager != nil {
+		d.jwtmanager.caFilesFn = d.camanager.caFiles
+	}
+
+	d.reservedFileNames = []string{d.certFileName, d.keyFileName, d.caFileName}
+	if d.spiffeBundle != nil {
+		d.reservedFileNames = append(d.reservedFileNames, d.spiffeBundle.fileName)
+	}
+	if d.jwtmanager != nil {
+		d.reservedFileNames = append(d.reservedFileNames, d.jwtmanager.tokenFileName, d.jwtmanager.bundleFileName)
+	}
+	if len(opts.WorkloadAPISocketName) > 0 {
+		d.reservedFileNames = append(d.reservedFileNames, opts.WorkloadAPISocketName)
+	}
+	if d.federatedCAs != nil {
+		d.federatedCAFileNamePrefix = federatedCAFileNamePrefix
+	}
+
+	if len(opts.WorkloadAPISocketName) > 0 {
+		d.workloadAPI, err = workloadapi.New(d.log, workloadapi.Options{
+			Store:               store,
+			RootCAs:             opts.RootCAs,
+			TrustDomain:         opts.TrustDomain,
+			SocketName:          opts.WorkloadAPISocketName,
+			CertificateFileName: d.certFileName,
+			KeyFileName:         d.keyFileName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup workload API: %w", err)
+		}
+	}
 
 	cmclient, err := cmversioned.NewForConfig(opts.RestConfig)
 	if err != nil {
@@ -225,21 +485,61 @@ func New(ctx context.Context, log logr.Logger, opts Options) (*Driver, error) {
 			Clock:                clock.RealClock{},
 			Log:                  &mngrLog,
 			NodeID:               opts.NodeID,
-			GeneratePrivateKey:   generatePrivateKey,
-			GenerateRequest:      d.generateRequest,
-			SignRequest:          signRequest,
-			WriteKeypair:         d.writeKeypair,
+			GeneratePrivateKey: func(meta metadata.Metadata) (crypto.PrivateKey, error) {
+				// Generating the private key is the first step of every
+				// issuance.
+				d.issuance.start(meta.VolumeID)
+				key, err := generatePrivateKey(meta)
+				return key, d.issuance.fail(meta.VolumeID, issuanceFailureGeneratePrivateKey, err)
+			},
+			GenerateRequest: func(meta metadata.Metadata) (*manager.CertificateRequestBundle, error) {
+				bundle, err := d.generateRequest(meta)
+				return bundle, d.issuance.fail(meta.VolumeID, issuanceFailureGenerateRequest, err)
+			},
+			SignRequest: func(meta metadata.Metadata, key crypto.PrivateKey, request *x509.CertificateRequest) ([]byte, error) {
+				csr, err := signRequest(meta, key, request)
+				return csr, d.issuance.fail(meta.VolumeID, issuanceFailureSignRequest, err)
+			},
+			WriteKeypair: func(meta metadata.Metadata, key crypto.PrivateKey, chain []byte, ca []byte) error {
+				if err := d.writeKeypair(meta, key, chain, ca); err != nil {
+					return d.issuance.fail(meta.VolumeID, issuanceFailureWriteKeypair, err)
+				}
+				d.issuance.succeed(meta.VolumeID)
+				return nil
+			},
 		}),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup csi driver: %w", err)
 	}
 
+	d.volumeMetrics = &volumeCollector{
+		log:          d.log.WithName("metrics"),
+		store:        store,
+		certFileName: d.certFileName,
+		camanager:    d.camanager,
+		issuance:     d.issuance,
+	}
+
+	if len(opts.MetricsBindAddress) > 0 {
+		d.metricsServer, err = server.NewServer(server.Options{BindAddress: opts.MetricsBindAddress}, opts.RestConfig, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup metrics server: %w", err)
+		}
+	}
+
 	return d, nil
 }
 
 // Run is a blocking func that runs the CSI driver.
 func (d *Driver) Run(ctx context.Context) error {
+	// Register the volume metrics only while running, so that they are never
+	// registered twice.
+	if err := metrics.Registry.Register(d.volumeMetrics); err != nil {
+		return fmt.Errorf("failed to register volume metrics: %w", err)
+	}
+	defer metrics.Registry.Unregister(d.volumeMetrics)
+
 	var wg sync.WaitGroup
 
 	go func() {
@@ -252,6 +552,28 @@ func (d *Driver) Run(ctx context.Context) error {
 		d.camanager.run(ctx, updateRetryPeriod)
 	})
 
+	if d.workloadAPI != nil {
+		wg.Go(func() {
+			resyncPeriod := time.Second * 30
+			d.workloadAPI.Run(ctx, resyncPeriod)
+		})
+	}
+
+	if d.jwtmanager != nil {
+		wg.Go(func() {
+			refreshPeriod := time.Second * 10
+			d.jwtmanager.run(ctx, refreshPeriod)
+		})
+	}
+
+	if d.metricsServer != nil {
+		wg.Go(func() {
+			if err := d.metricsServer.Start(ctx); err != nil {
+				d.log.Error(err, "failed to run metrics server")
+			}
+		})
+	}
+
 	wg.Add(1)
 	var err error
 	go func() {
@@ -283,64 +605,79 @@ var validSigningAlgs = []jose.SignatureAlgorithm{
 // generateRequest will generate a SPIFFE manager.CertificateRequestBundle
 // based upon the identity contained in the metadata service account token.
 func (d *Driver) generateRequest(meta metadata.Metadata) (*manager.CertificateRequestBundle, error) {
-	cfg := d.runtimeConfig.Config()
-	if cfg.IssuerRef.Name == "" {
-		return nil, fmt.Errorf("no issuerRef is currently active for csi-driver-spiffe; configure one using runtime configuration")
+	// Validate the volume's renewal settings before requesting a certificate.
+	if _, err := d.renewBeforeForVolume(meta); err != nil {
+		return nil, err
 	}
 
-	// Extract the service account token from the volume metadata in order to
-	// derive the service account, and thus identity of the pod.
-	token, err := util.EmptyAudienceTokenFromMetadata(meta)
+	duration, err := d.certificateDurationForVolume(meta)
 	if err != nil {
 		return nil, err
 	}
 
-	// see comment for validSigningAlgs for more details on how the algorithms were chosen
-	jwttoken, err := jwt.ParseSigned(token, validSigningAlgs)
+	// Keystores must not overwrite any other file written to the volume.
+	if err := validateKeystoreFileNames(meta, d.reservedFileNames, d.federatedCAFileNamePrefix); err != nil {
+		return nil, err
+	}
+
+	if d.rootCAs == nil && truststoreRequested(meta) {
+		return nil, errors.New("truststore requested but no root CA certificates are configured")
+	}
+
+	cfg := d.runtimeConfig.Config()
+	if cfg.IssuerRef.Name == "" {
+		return nil, fmt.Errorf("no issuerRef is currently active for csi-driver-spiffe; configure one using runtime configuration")
+	}
+
+	id, err := d.identityFromToken(meta)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token request token: %w", err)
+		return nil, err
 	}
+	saName, saNamespace := id.serviceAccount, id.namespace
 
-	claims := struct {
-		KubernetesIO struct {
-			Namespace      string `json:"namespace"`
-			ServiceAccount struct {
-				Name string `json:"name"`
-			} `json:"serviceaccount"`
-		} `json:"kubernetes.io"`
-	}{}
+	crAnnotations := make(map[string]string)
 
-	// We don't need to verify the token since we will be using it against the
-	// API server anyway which is the source of trust for auth by definition.
-	if err := jwttoken.UnsafeClaimsWithoutVerification(&claims); err != nil {
-		return nil, fmt.Errorf("failed to decode token request token: %w", err)
+	params := identity.Params{
+		TrustDomain:    d.trustDomain,
+		Namespace:      saNamespace,
+		ServiceAccount: saName,
+		ClusterName:    d.clusterName,
 	}
 
-	saName := claims.KubernetesIO.ServiceAccount.Name
-	saNamespace := claims.KubernetesIO.Namespace
-	if len(saName) == 0 || len(saNamespace) == 0 {
-		return nil, fmt.Errorf("missing namespace or serviceaccount name in request token: %v", claims)
+	if d.spiffeIDTemplate.UsesPodLabels() {
+		podName := meta.VolumeContext[volumeContextPodName]
+		params.PodLabels, err = d.podLabels(saNamespace, podName, saName)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	spiffeID := fmt.Sprintf("spiffe://%s/ns/%s/sa/%s", d.trustDomain, saNamespace, saName)
-	uri, err := url.Parse(spiffeID)
+	spiffeID, err := d.spiffeIDTemplate.ID(params)
 	if err != nil {
-		return nil, fmt.Errorf("internal error crafting X.509 URI, this is a bug, please report on GitHub: %w", err)
+		return nil, fmt.Errorf("failed to build SPIFFE ID for pod: %w", err)
 	}
 
-	crAnnotations := map[string]string{
-		annotations.SPIFFEIdentityAnnnotationKey: spiffeID,
+	crAnnotations[annotations.SPIFFEIdentityAnnnotationKey] = spiffeID.String()
+
+	// Identify the mounting pod and this node, so that the approver can verify
+	// the pod when the driver uses its own ServiceAccount.
+	crAnnotations[annotations.NodeNameAnnotationKey] = d.nodeName
+	if podName := meta.VolumeContext[volumeContextPodName]; len(podName) > 0 {
+		crAnnotations[annotations.PodNameAnnotationKey] = podName
+	}
+	if podUID := meta.VolumeContext[volumeContextPodUID]; len(podUID) > 0 {
+		crAnnotations[annotations.PodUIDAnnotationKey] = podUID
 	}
 
 	maps.Copy(crAnnotations, d.certificateRequestAnnotations)
 
 	return &manager.CertificateRequestBundle{
 		Request: &x509.CertificateRequest{
-			URIs: []*url.URL{uri},
+			URIs: []*url.URL{spiffeID.URL()},
 		},
 		IsCA:      false,
 		Namespace: saNamespace,
-		Duration:  d.certificateRequestDuration,
+		Duration:  duration,
 		Usages: []cmapi.KeyUsage{
 			cmapi.UsageDigitalSignature,
 			cmapi.UsageKeyEncipherment,
@@ -352,12 +689,35 @@ func (d *Driver) generateRequest(meta metadata.Metadata) (*manager.CertificateRe
 	}, nil
 }
 
+// podLabels returns the labels of the named mounting pod, after checking it
+// runs as the expected ServiceAccount.
+func (d *Driver) podLabels(namespace, name, serviceAccount string) (map[string]string, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("missing %q in volume context, pod info on mount must be enabled", volumeContextPodName)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	pod, err := d.kubeClient.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mounting pod %s/%s: %w", namespace, name, err)
+	}
+
+	if pod.Spec.ServiceAccountName != serviceAccount {
+		return nil, fmt.Errorf("mounting pod %s/%s does not run as the token's ServiceAccount, exp=%q got=%q",
+			namespace, name, serviceAccount, pod.Spec.ServiceAccountName)
+	}
+
+	return pod.Labels, nil
+}
+
 // writeKeypair writes the private key and certificate chain to file that will
 // be mounted into the pod.
 func (d *Driver) writeKeypair(meta metadata.Metadata, key crypto.PrivateKey, chain []byte, _ []byte) error {
 	pemBytes, err := x509.MarshalPKCS8PrivateKey(key)
 	if err != nil {
-		return fmt.Errorf("failed to marshal ECDSA private key for PEM encoding: %w", err)
+		return fmt.Errorf("failed to marshal private key for PEM encoding: %w", err)
 	}
 
 	keyPEM := pem.EncodeToMemory(
@@ -369,18 +729,62 @@ func (d *Driver) writeKeypair(meta metadata.Metadata, key crypto.PrivateKey, cha
 
 	// Calculate the next issuance time before we write any data to file, so in
 	// the cases where this errors, we are not left in a bad state.
-	nextIssuanceTime, err := calculateNextIssuanceTime(chain)
+	volumeRenewBefore, err := d.renewBeforeForVolume(meta)
+	if err != nil {
+		return err
+	}
+
+	nextIssuanceTime, err := calculateNextIssuanceTime(chain, volumeRenewBefore, d.renewalJitter)
 	if err != nil {
 		return fmt.Errorf("failed to calculate next issuance time: %w", err)
 	}
 
+	// Hold the volume's lock while the files are built and written, so that
+	// the CA and JWT managers cannot write back the previous keypair, or
+	// stale CA files, over the renewed one.
+	unlock := d.volumeLocks.lockVolume(meta.VolumeID)
+	defer unlock()
+
 	data := map[string][]byte{
 		d.certFileName: chain,
 		d.keyFileName:  keyPEM,
 	}
-	// If configured, write the CA certificates as defined in RootCAs.
+	// If configured, write the CA certificates as defined in RootCAs. Nothing
+	// is written until the root CA sources have valid certificates.
+	var caPEM []byte
 	if d.rootCAs != nil {
-		data[d.caFileName] = d.rootCAs.CertificatesPEM()
+		caPEM = d.rootCAs.CertificatesPEM()
+	}
+	if len(caPEM) > 0 {
+		data[d.caFileName] = caPEM
+
+		// If configured, also write them as a SPIFFE bundle.
+		if d.spiffeBundle != nil {
+			bundleFiles, err := d.spiffeBundle.files(data[d.caFileName])
+			if err != nil {
+				return err
+			}
+			maps.Copy(data, bundleFiles)
+		}
+	}
+	// If configured, write the CA certificates of federated trust domains.
+	if d.federatedCAs != nil {
+		maps.Copy(data, d.camanager.federatedCAFiles())
+	}
+	// If requested by the volume, write keystores and truststores.
+	keystores, err := keystoreFiles(meta, keyPEM, chain, data[d.caFileName])
+	if err != nil {
+		return err
+	}
+	maps.Copy(data, keystores)
+
+	// If configured, write the JWT bundle and the volume's JWT-SVID.
+	if d.jwtmanager != nil {
+		jwtFiles, err := d.jwtmanager.files(meta, chain)
+		if err != nil {
+			return err
+		}
+		maps.Copy(data, jwtFiles)
 	}
 
 	// Write data to the actual volume that gets mounted.
@@ -393,9 +797,52 @@ func (d *Driver) writeKeypair(meta metadata.Metadata, key crypto.PrivateKey, cha
 		return fmt.Errorf("writing metadata: %w", err)
 	}
 
+	// Push the new SVID to workloads using the Workload API.
+	if d.workloadAPI != nil {
+		if err := d.workloadAPI.Update(meta.VolumeID, key, chain); err != nil {
+			return fmt.Errorf("updating workload API: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// renewBeforeForVolume returns how long before expiry the volume's
+// certificate should be renewed, taking the volume attribute override into
+// account.
+func (d *Driver) renewBeforeForVolume(meta metadata.Metadata) (renewBefore, error) {
+	value, ok := meta.VolumeContext[volumeContextRenewBefore]
+	if !ok {
+		return d.renewBefore, nil
+	}
+
+	r, err := parseRenewBefore(value)
+	if err != nil {
+		return renewBefore{}, fmt.Errorf("invalid %q volume attribute: %w", volumeContextRenewBefore, err)
+	}
+
+	return r, nil
+}
+
+// certificateDurationForVolume returns the duration to request for the
+// volume's certificate, taking the volume attribute override into account.
+func (d *Driver) certificateDurationForVolume(meta metadata.Metadata) (time.Duration, error) {
+	value, ok := meta.VolumeContext[volumeContextCertificateDuration]
+	if !ok {
+		return d.certificateRequestDuration, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %q volume attribute: %w", volumeContextCertificateDuration, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("invalid %q volume attribute: duration must be positive: %q", volumeContextCertificateDuration, value)
+	}
+
+	return duration, nil
+}
+
 func sanitizeAnnotations(in map[string]string) (map[string]string, error) {
 	out := map[string]string{}
 
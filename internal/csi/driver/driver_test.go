@@ -24,16 +24,23 @@ import (
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/pem"
+	"fmt"
+	"maps"
 	"net/url"
 	"reflect"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
 	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
 	"github.com/cert-manager/csi-lib/metadata"
 	"github.com/cert-manager/csi-lib/storage"
 	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
 
 	"github.com/cert-manager/csi-driver-spiffe/internal/annotations"
 	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
@@ -74,6 +81,7 @@ func Test_writeKeyPair(t *testing.T) {
 		caFileName:   "ca.pem",
 		rootCAs:      rootCAs,
 		store:        store,
+		volumeLocks:  newVolumeLocks(),
 	}
 
 	meta := metadata.Metadata{VolumeID: "vol-id"}
@@ -91,6 +99,150 @@ func Test_writeKeyPair(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// Ensure no CA files are written, and issuance doesn't fail, until the root CA
+// sources have valid certificates.
+func Test_writeKeypair_noRootCAsYet(t *testing.T) {
+	capk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: cmapi.CertificateSpec{CommonName: "my-ca"}})
+	require.NoError(t, err)
+	caPEM, ca, err := utilpki.SignCertificate(caTmpl, caTmpl, capk.Public(), capk)
+	require.NoError(t, err)
+
+	leafpk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTmpl, err := utilpki.CertificateTemplateFromCertificate(
+		&cmapi.Certificate{
+			Spec: cmapi.CertificateSpec{URIs: []string{"spiffe://cert-manager.io/ns/sandbox/sa/default"}},
+		},
+	)
+	require.NoError(t, err)
+	leafPEM, _, err := utilpki.SignCertificate(leafTmpl, ca, leafpk.Public(), capk)
+	require.NoError(t, err)
+
+	ch := make(chan []byte)
+	rootCAs := rootca.NewMemory(t.Context(), ch)
+
+	spiffeBundle, err := newSPIFFEBundleFile("cert-manager.io", "bundle.spiffe", 0)
+	require.NoError(t, err)
+
+	store := storage.NewMemoryFS()
+	d := &Driver{
+		certFileName: "crt.pem",
+		keyFileName:  "key.pem",
+		caFileName:   "ca.pem",
+		rootCAs:      rootCAs,
+		spiffeBundle: spiffeBundle,
+		store:        store,
+		volumeLocks:  newVolumeLocks(),
+	}
+	d.camanager = newCAManager(ktesting.NewLogger(t, ktesting.DefaultConfig), store, d.volumeLocks, rootCAs, nil, spiffeBundle,
+		d.certFileName, d.keyFileName, d.caFileName, "", nil)
+
+	meta := metadata.Metadata{
+		VolumeID:      "vol-id",
+		VolumeContext: map[string]string{volumeContextTruststorePKCS12File: "truststore.p12"},
+	}
+	_, err = store.RegisterMetadata(meta)
+	require.NoError(t, err)
+
+	t.Log("should write only the keypair while there are no root CAs")
+	require.NoError(t, d.writeKeypair(meta, leafpk, leafPEM, nil))
+
+	files, err := store.ReadFiles("vol-id")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"crt.pem", "key.pem"}, slices.Collect(maps.Keys(files)))
+
+	t.Log("should write the CA files once the root CAs are available")
+	ch <- caPEM
+	require.Eventually(t, func() bool { return len(rootCAs.CertificatesPEM()) > 0 }, time.Second, time.Millisecond*10)
+
+	failed, err := d.camanager.updateRootCAFiles(nil)
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+
+	files, err = store.ReadFiles("vol-id")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"crt.pem", "key.pem", "ca.pem", "bundle.spiffe", "truststore.p12"}, slices.Collect(maps.Keys(files)))
+	assert.Equal(t, caPEM, files["ca.pem"])
+}
+
+// Ensure CA file updates running concurrently with renewals never overwrite
+// the renewed keypair, nor leave CA files older than the current root CAs.
+func Test_writeKeypair_concurrentCAUpdate(t *testing.T) {
+	capk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: cmapi.CertificateSpec{CommonName: "my-ca"}})
+	require.NoError(t, err)
+	_, ca, err := utilpki.SignCertificate(caTmpl, caTmpl, capk.Public(), capk)
+	require.NoError(t, err)
+
+	newKeypair := func() (*ecdsa.PrivateKey, []byte, []byte) {
+		leafpk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		leafTmpl, err := utilpki.CertificateTemplateFromCertificate(
+			&cmapi.Certificate{
+				Spec: cmapi.CertificateSpec{URIs: []string{"spiffe://cert-manager.io/ns/sandbox/sa/default"}},
+			},
+		)
+		require.NoError(t, err)
+		leafPEM, _, err := utilpki.SignCertificate(leafTmpl, ca, leafpk.Public(), capk)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalPKCS8PrivateKey(leafpk)
+		require.NoError(t, err)
+		return leafpk, leafPEM, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	}
+
+	ch := make(chan []byte)
+	rootCAs := rootca.NewMemory(t.Context(), ch)
+	ch <- []byte("root cas 0")
+	require.Eventually(t, func() bool { return len(rootCAs.CertificatesPEM()) > 0 }, time.Second, time.Millisecond*10)
+
+	store := storage.NewMemoryFS()
+	d := &Driver{
+		certFileName: "crt.pem",
+		keyFileName:  "key.pem",
+		caFileName:   "ca.pem",
+		rootCAs:      rootCAs,
+		store:        store,
+		volumeLocks:  newVolumeLocks(),
+	}
+	d.camanager = newCAManager(ktesting.NewLogger(t, ktesting.DefaultConfig), store, d.volumeLocks, rootCAs, nil, nil,
+		d.certFileName, d.keyFileName, d.caFileName, "", nil)
+
+	meta := metadata.Metadata{VolumeID: "vol-id"}
+	_, err = store.RegisterMetadata(meta)
+	require.NoError(t, err)
+
+	key, chain, _ := newKeypair()
+	require.NoError(t, d.writeKeypair(meta, key, chain, nil))
+
+	for i := range 20 {
+		key, chain, keyPEM := newKeypair()
+		rootCAsPEM := fmt.Sprintf("root cas %d", i+1)
+
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			assert.NoError(t, d.writeKeypair(meta, key, chain, nil))
+		})
+		wg.Go(func() {
+			ch <- []byte(rootCAsPEM)
+			assert.Eventually(t, func() bool { return string(rootCAs.CertificatesPEM()) == rootCAsPEM }, time.Second, time.Millisecond)
+
+			failed, err := d.camanager.updateRootCAFiles(nil)
+			assert.NoError(t, err)
+			assert.Empty(t, failed)
+		})
+		wg.Wait()
+
+		files, err := store.ReadFiles("vol-id")
+		require.NoError(t, err)
+		require.Equal(t, string(chain), string(files["crt.pem"]), "expected the renewed certificate to not be overwritten")
+		require.Equal(t, string(keyPEM), string(files["key.pem"]), "expected the renewed key to not be overwritten")
+		require.Equal(t, rootCAsPEM, string(files["ca.pem"]), "expected the CA file to be the current root CAs")
+	}
+}
+
 func Test_DriverAnnotationSanitization(t *testing.T) {
 	badAnnotation := annotations.Prefix + "/customannotation"
 
@@ -195,3 +347,38 @@ func Test_signRequest_SAN_criticality(t *testing.T) {
 		})
 	}
 }
+
+func Test_certificateDurationForVolume(t *testing.T) {
+	d := &Driver{certificateRequestDuration: time.Hour}
+
+	tests := map[string]struct {
+		volumeContext map[string]string
+		expDuration   time.Duration
+		expErr        bool
+	}{
+		"no attribute should use the driver's duration": {
+			volumeContext: map[string]string{},
+			expDuration:   time.Hour,
+		},
+		"attribute should override the driver's duration": {
+			volumeContext: map[string]string{volumeContextCertificateDuration: "10m"},
+			expDuration:   time.Minute * 10,
+		},
+		"invalid duration should error": {
+			volumeContext: map[string]string{volumeContextCertificateDuration: "a while"},
+			expErr:        true,
+		},
+		"zero duration should error": {
+			volumeContext: map[string]string{volumeContextCertificateDuration: "0s"},
+			expErr:        true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			duration, err := d.certificateDurationForVolume(metadata.Metadata{VolumeContext: test.volumeContext})
+			require.Equalf(t, test.expErr, err != nil, "%v", err)
+			require.Equal(t, test.expDuration, duration)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/cert-manager/csi-lib/metadata"
+	"github.com/cert-manager/csi-lib/storage"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/go-logr/logr"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/jwtsvid"
+)
+
+const (
+	// volumeContextJWTAudiences is the volume attribute holding a comma
+	// separated list of audiences to request JWT-SVIDs for. No JWT-SVID is
+	// written to the volume if unset.
+	volumeContextJWTAudiences = "spiffe.csi.cert-manager.io/jwt-audiences"
+)
+
+// jwtmanager is a process responsible for writing JWT-SVIDs and the trust
+// domain JWT bundle to managed volumes, and refreshing JWT-SVIDs before they
+// expire.
+type jwtmanager struct {
+	// log is the logger for jwtmanager.
+	log logr.Logger
+
+	// store is the csi-lib file system storage implementation.
+	store *storage.Filesystem
+
+	// volumeLocks coordinates writes to volumes with the other writers, so
+	// that a renewed keypair is never overwritten by a JWT-SVID refresh.
+	volumeLocks *volumeLocks
+
+	// issuer signs JWT-SVIDs and provides the JWT bundle.
+	issuer *jwtsvid.Issuer
+
+	// duration is the lifetime of signed JWT-SVIDs.
+	duration time.Duration
+
+	// certFileName, keyFileName, caFileName are the names of the other files
+	// written to volumes, which must be preserved when refreshing JWT-SVIDs.
+	certFileName, keyFileName, caFileName string
+
+	// tokenFileName, bundleFileName are the names used when writing the
+	// JWT-SVID and JWT bundle to volumes.
+	tokenFileName, bundleFileName string
+
+	// caFilesFn returns the current CA files which must be written alongside
+	// refreshed JWT-SVIDs. If nil, only the CA file is preserved.
+	caFilesFn func() (map[string][]byte, error)
+}
+
+// newJWTManager constructs a new jwtmanager. Defaults are applied to empty
+// duration and JWT file names.
+func newJWTManager(log logr.Logger,
+	store *storage.Filesystem,
+	volumeLocks *volumeLocks,
+	issuer *jwtsvid.Issuer,
+	duration time.Duration,
+	certFileName, keyFileName, caFileName, tokenFileName, bundleFileName string,
+) *jwtmanager {
+	if duration == 0 {
+		duration = time.Minute * 5
+	}
+
+	if len(tokenFileName) == 0 {
+		tokenFileName = "jwt-svid.token"
+	}
+
+	if len(bundleFileName) == 0 {
+		bundleFileName = "jwt-bundle.json"
+	}
+
+	return &jwtmanager{
+		log:            log.WithName("jwt-manager"),
+		store:          store,
+		volumeLocks:    volumeLocks,
+		issuer:         issuer,
+		duration:       duration,
+		certFileName:   certFileName,
+		keyFileName:    keyFileName,
+		caFileName:     caFileName,
+		tokenFileName:  tokenFileName,
+		bundleFileName: bundleFileName,
+	}
+}
+
+// run refreshes JWT-SVIDs of managed volumes which are due for renewal every
+// refreshPeriod. All volumes are rewritten when the signing key changes.
+// Blocking function.
+func (j *jwtmanager) run(ctx context.Context, refreshPeriod time.Duration) {
+	watcher := j.issuer.Subscribe()
+
+	j.log.Info("starting JWT-SVID manager")
+
+	ticker := time.NewTicker(refreshPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			j.log.Info("closing JWT-SVID manager")
+			return
+
+		case <-watcher:
+			j.log.Info("JWT signing key changed, updating managed volumes")
+			if err := j.updateVolumes(true); err != nil {
+				j.log.Error(err, "failed to update JWT files on managed volumes")
+			}
+
+		case <-ticker.C:
+			if err := j.updateVolumes(false); err != nil {
+				j.log.Error(err, "failed to refresh JWT-SVIDs on managed volumes")
+			}
+		}
+	}
+}
+
+// files returns the JWT bundle, and JWT-SVID if the volume requests one, to
+// be written to the volume. The JWT-SVID is issued for the SPIFFE ID of the
+// leaf certificate in chain.
+func (j *jwtmanager) files(meta metadata.Metadata, chain []byte) (map[string][]byte, error) {
+	bundle, err := j.issuer.BundleJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JWT bundle: %w", err)
+	}
+
+	files := map[string][]byte{j.bundleFileName: bundle}
+
+	audiences := jwtAudiences(meta)
+	if len(audiences) == 0 {
+		return files, nil
+	}
+
+	id, err := spiffeIDFromChain(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	token, _, err := j.issuer.Sign(id, audiences, j.duration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign JWT-SVID: %w", err)
+	}
+
+	files[j.tokenFileName] = []byte(token)
+
+	return files, nil
+}
+
+// updateVolumes rewrites the JWT files of managed volumes. If force is false,
+// only volumes whose JWT-SVID is due for renewal are updated.
+func (j *jwtmanager) updateVolumes(force bool) error {
+	volumeIDs, err := j.store.ListVolumes()
+	if err != nil {
+		return fmt.Errorf("failed to list managed volumes: %w", err)
+	}
+
+	var errs []error
+	for _, volumeID := range volumeIDs {
+		if err := j.updateVolume(volumeID, force); err != nil {
+			errs = append(errs, fmt.Errorf("%q: %w", volumeID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// updateVolume rewrites the JWT files of the volume, preserving all other
+// files. The volume's lock is held across reading back and writing its files.
+func (j *jwtmanager) updateVolume(volumeID string, force bool) error {
+	unlock := j.volumeLocks.lockVolume(volumeID)
+	defer unlock()
+
+	meta, err := j.store.ReadMetadata(volumeID)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata from volume: %w", err)
+	}
+
+	if !force && !j.needsRefresh(volumeID, meta) {
+		return nil
+	}
+
+	certData, err := j.store.ReadFile(volumeID, j.certFileName)
+	if err != nil {
+		// The volume has not been issued a certificate yet. The JWT files will
+		// be written alongside it.
+		j.log.V(2).Info("not refreshing JWT-SVID of volume", "volume", volumeID, "reason", err.Error())
+		return nil
+	}
+	keyData, err := j.store.ReadFile(volumeID, j.keyFileName)
+	if err != nil {
+		return fmt.Errorf("failed to read key file from volume to perform write: %w", err)
+	}
+
+	data := map[string][]byte{
+		j.certFileName: certData,
+		j.keyFileName:  keyData,
+	}
+	if caData, err := j.store.ReadFile(volumeID, j.caFileName); err == nil {
+		data[j.caFileName] = caData
+	}
+	if j.caFilesFn != nil {
+		caFiles, err := j.caFilesFn()
+		if err != nil {
+			return err
+		}
+		maps.Copy(data, caFiles)
+	}
+
+	keystores, err := keystoreFiles(meta, keyData, certData, data[j.caFileName])
+	if err != nil {
+		return fmt.Errorf("failed to build keystores: %w", err)
+	}
+	maps.Copy(data, keystores)
+
+	jwtFiles, err := j.files(meta, certData)
+	if err != nil {
+		return err
+	}
+	maps.Copy(data, jwtFiles)
+
+	if err := j.store.WriteFiles(meta, data); err != nil {
+		return fmt.Errorf("failed to write JWT files to volume: %w", err)
+	}
+
+	j.log.V(2).Info("updated JWT files on volume", "volume", volumeID)
+
+	return nil
+}
+
+// needsRefresh returns true if the volume requests a JWT-SVID, and the
+// current one is missing or 2/3rds of the way through its lifetime.
+func (j *jwtmanager) needsRefresh(volumeID string, meta metadata.Metadata) bool {
+	if len(jwtAudiences(meta)) == 0 {
+		return false
+	}
+
+	token, err := j.store.ReadFile(volumeID, j.tokenFileName)
+	if err != nil {
+		return true
+	}
+
+	parsed, err := jwt.ParseSigned(string(token), validSigningAlgs)
+	if err != nil {
+		return true
+	}
+
+	var claims jwt.Claims
+	if err := parsed.UnsafeClaimsWithoutVerification(&claims); err != nil ||
+		claims.IssuedAt == nil || claims.Expiry == nil {
+		return true
+	}
+
+	issuedAt, expiry := claims.IssuedAt.Time(), claims.Expiry.Time()
+	renewBefore := expiry.Sub(issuedAt) / 3
+
+	return !time.Now().Before(expiry.Add(-renewBefore))
+}
+
+// jwtAudiences returns the JWT-SVID audiences requested by the volume.
+func jwtAudiences(meta metadata.Metadata) []string {
+	var audiences []string
+	for aud := range strings.SplitSeq(meta.VolumeContext[volumeContextJWTAudiences], ",") {
+		if aud = strings.TrimSpace(aud); len(aud) > 0 {
+			audiences = append(audiences, aud)
+		}
+	}
+	return audiences
+}
+
+// spiffeIDFromChain returns the SPIFFE ID of the leaf certificate in the PEM
+// encoded chain.
+func spiffeIDFromChain(chain []byte) (spiffeid.ID, error) {
+	block, _ := pem.Decode(chain)
+	if block == nil {
+		return spiffeid.ID{}, errors.New("failed to decode certificate chain PEM")
+	}
+
+	crt, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return spiffeid.ID{}, fmt.Errorf("parsing issued certificate: %w", err)
+	}
+
+	if len(crt.URIs) != 1 {
+		return spiffeid.ID{}, fmt.Errorf("expected exactly 1 URI SAN on issued certificate, got=%d", len(crt.URIs))
+	}
+
+	return spiffeid.FromURI(crt.URIs[0])
+}
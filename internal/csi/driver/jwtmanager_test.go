@@ -0,0 +1,99 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/cert-manager/csi-lib/metadata"
+	"github.com/spiffe/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/svid/jwtsvid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+
+	csijwtsvid "github.com/cert-manager/csi-driver-spiffe/internal/csi/jwtsvid"
+)
+
+func Test_jwtmanager_files(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signingKeyPEM, err := utilpki.EncodePrivateKey(signingKey, cmapi.PKCS8)
+	require.NoError(t, err)
+
+	issuer, err := csijwtsvid.NewIssuer("cert-manager.io")
+	require.NoError(t, err)
+	require.NoError(t, issuer.SetKey(signingKeyPEM))
+
+	leafpk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTmpl, err := utilpki.CertificateTemplateFromCertificate(
+		&cmapi.Certificate{
+			Spec: cmapi.CertificateSpec{URIs: []string{"spiffe://cert-manager.io/ns/sandbox/sa/default"}},
+		},
+	)
+	require.NoError(t, err)
+	leafPEM, _, err := utilpki.SignCertificate(leafTmpl, leafTmpl, leafpk.Public(), leafpk)
+	require.NoError(t, err)
+
+	j := newJWTManager(ktesting.NewLogger(t, ktesting.DefaultConfig), nil, newVolumeLocks(), issuer, 0,
+		"tls.crt", "tls.key", "ca.crt", "", "")
+
+	tests := map[string]struct {
+		volumeContext map[string]string
+		expAudiences  []string
+	}{
+		"no audiences should only write the bundle": {
+			volumeContext: map[string]string{},
+		},
+		"empty audiences should only write the bundle": {
+			volumeContext: map[string]string{volumeContextJWTAudiences: " , "},
+		},
+		"audiences should write a JWT-SVID for the certificate's SPIFFE ID": {
+			volumeContext: map[string]string{volumeContextJWTAudiences: "aud-1, aud-2"},
+			expAudiences:  []string{"aud-1", "aud-2"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, err := j.files(metadata.Metadata{VolumeID: "vol-id", VolumeContext: test.volumeContext}, leafPEM)
+			require.NoError(t, err)
+
+			bundle, err := jwtbundle.Parse(spiffeid.RequireTrustDomainFromString("cert-manager.io"), files["jwt-bundle.json"])
+			require.NoError(t, err)
+			assert.Len(t, bundle.JWTAuthorities(), 1)
+
+			token, ok := files["jwt-svid.token"]
+			assert.Equal(t, len(test.expAudiences) > 0, ok)
+			if !ok {
+				return
+			}
+
+			svid, err := jwtsvid.ParseAndValidate(string(token), bundle, test.expAudiences[:1])
+			require.NoError(t, err)
+			assert.Equal(t, "spiffe://cert-manager.io/ns/sandbox/sa/default", svid.ID.String())
+			assert.Equal(t, test.expAudiences, svid.Audience)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/cert-manager/csi-lib/metadata"
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	// volumeContextKeystorePKCS12File is the volume attribute holding the file
+	// name of a PKCS#12 keystore, containing the private key and certificate
+	// chain, to write to the volume.
+	volumeContextKeystorePKCS12File = "spiffe.csi.cert-manager.io/keystore-pkcs12-file"
+
+	// volumeContextTruststorePKCS12File is the volume attribute holding the
+	// file name of a PKCS#12 truststore, containing the root CA certificates,
+	// to write to the volume.
+	volumeContextTruststorePKCS12File = "spiffe.csi.cert-manager.io/truststore-pkcs12-file"
+
+	// volumeContextTruststoreJKSFile is the volume attribute holding the file
+	// name of a JKS truststore, containing the root CA certificates, to write
+	// to the volume.
+	volumeContextTruststoreJKSFile = "spiffe.csi.cert-manager.io/truststore-jks-file"
+
+	// volumeContextKeystorePassword is the volume attribute holding the
+	// password of written keystores and truststores. Defaults to
+	// defaultKeystorePassword.
+	volumeContextKeystorePassword = "spiffe.csi.cert-manager.io/keystore-password"
+
+	// volumeContextKeystorePasswordFile is the volume attribute holding the
+	// file name that the keystore password is written to in the volume, so
+	// that applications need not be configured with it.
+	volumeContextKeystorePasswordFile = "spiffe.csi.cert-manager.io/keystore-password-file"
+
+	// defaultKeystorePassword is the conventional default password of Java
+	// keystores.
+	defaultKeystorePassword = "changeit"
+)
+
+// validateKeystoreFileNames returns an error if any keystore or truststore
+// file name requested by the volume attributes would overwrite another file
+// written to the volume, or is not a plain file name. Other files are the
+// given reserved file names, federated CA files if federatedCAFileNamePrefix
+// is not empty, or another requested keystore file.
+func validateKeystoreFileNames(meta metadata.Metadata, reserved []string, federatedCAFileNamePrefix string) error {
+	requested := make(map[string]string)
+	for _, attr := range []string{
+		volumeContextKeystorePKCS12File,
+		volumeContextTruststorePKCS12File,
+		volumeContextTruststoreJKSFile,
+		volumeContextKeystorePasswordFile,
+	} {
+		name := meta.VolumeContext[attr]
+		if len(name) == 0 {
+			continue
+		}
+
+		if err := validateKeystoreFileName(name); err != nil {
+			return fmt.Errorf("invalid %q volume attribute: %w", attr, err)
+		}
+
+		if slices.Contains(reserved, name) {
+			return fmt.Errorf("invalid %q volume attribute: file name %q is already written to the volume", attr, name)
+		}
+
+		if len(federatedCAFileNamePrefix) > 0 && strings.HasPrefix(name, federatedCAFileNamePrefix) && strings.HasSuffix(name, ".crt") {
+			return fmt.Errorf("invalid %q volume attribute: file name %q may collide with a federated CA file %q",
+				attr, name, federatedCAFileNamePrefix+"<trust-domain>.crt")
+		}
+
+		if other, ok := requested[name]; ok {
+			return fmt.Errorf("invalid %q volume attribute: file name %q is also requested by %q", attr, name, other)
+		}
+		requested[name] = attr
+	}
+
+	return nil
+}
+
+// keystoreFiles returns the keystore and truststore files requested by the
+// volume attributes, built from the PEM encoded private key, certificate
+// chain and root CA certificates. Returns no files if none are requested.
+func keystoreFiles(meta metadata.Metadata, keyPEM, chainPEM, caPEM []byte) (map[string][]byte, error) {
+	keystoreFile := meta.VolumeContext[volumeContextKeystorePKCS12File]
+	passwordFile := meta.VolumeContext[volumeContextKeystorePasswordFile]
+
+	files, err := truststoreFiles(meta, caPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keystoreFile) == 0 && len(files) == 0 {
+		return files, nil
+	}
+
+	for _, name := range []string{keystoreFile, passwordFile} {
+		if err := validateKeystoreFileName(name); err != nil {
+			return nil, err
+		}
+	}
+
+	password := keystorePassword(meta)
+
+	if len(keystoreFile) > 0 {
+		key, err := pki.DecodePrivateKeyBytes(keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode private key for keystore: %w", err)
+		}
+
+		chain, err := pki.DecodeX509CertificateChainBytes(chainPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode certificate chain for keystore: %w", err)
+		}
+
+		files[keystoreFile], err = pkcs12.Modern2023.Encode(key, chain[0], chain[1:], password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode PKCS#12 keystore: %w", err)
+		}
+	}
+
+	if len(passwordFile) > 0 {
+		files[passwordFile] = []byte(password)
+	}
+
+	return files, nil
+}
+
+// truststoreFiles returns the truststore files requested by the volume
+// attributes, built from the PEM encoded root CA certificates. Returns no
+// files if none are requested, or if there are no root CA certificates yet.
+func truststoreFiles(meta metadata.Metadata, caPEM []byte) (map[string][]byte, error) {
+	truststorePKCS12File := meta.VolumeContext[volumeContextTruststorePKCS12File]
+	truststoreJKSFile := meta.VolumeContext[volumeContextTruststoreJKSFile]
+
+	files := make(map[string][]byte)
+
+	if !truststoreRequested(meta) {
+		return files, nil
+	}
+
+	for _, name := range []string{truststorePKCS12File, truststoreJKSFile} {
+		if err := validateKeystoreFileName(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// Truststores are written once the root CA sources have valid
+	// certificates.
+	if len(caPEM) == 0 {
+		return files, nil
+	}
+
+	cas, err := pki.DecodeX509CertificateSetBytes(caPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode root CA certificates for truststore: %w", err)
+	}
+
+	password := keystorePassword(meta)
+
+	if len(truststorePKCS12File) > 0 {
+		files[truststorePKCS12File], err = pkcs12.Modern2023.EncodeTrustStore(cas, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode PKCS#12 truststore: %w", err)
+		}
+	}
+
+	if len(truststoreJKSFile) > 0 {
+		ks := keystore.New()
+		for i, ca := range cas {
+			if err := ks.SetTrustedCertificateEntry(strconv.Itoa(i), keystore.TrustedCertificateEntry{
+				CreationTime: time.Now(),
+				Certificate:  keystore.Certificate{Type: "X509", Content: ca.Raw},
+			}); err != nil {
+				return nil, fmt.Errorf("failed to add certificate to JKS truststore: %w", err)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := ks.Store(&buf, []byte(password)); err != nil {
+			return nil, fmt.Errorf("failed to encode JKS truststore: %w", err)
+		}
+		files[truststoreJKSFile] = buf.Bytes()
+	}
+
+	return files, nil
+}
+
+// truststoreRequested returns true if the volume attributes request a
+// truststore.
+func truststoreRequested(meta metadata.Metadata) bool {
+	return len(meta.VolumeContext[volumeContextTruststorePKCS12File]) > 0 ||
+		len(meta.VolumeContext[volumeContextTruststoreJKSFile]) > 0
+}
+
+// validateKeystoreFileName returns an error if the keystore file name is not
+// a plain file name. Names starting with ".." are reserved for the files the
+// volume is written with, such as "..data".
+func validateKeystoreFileName(name string) error {
+	if strings.ContainsRune(name, '/') || name == "." || strings.HasPrefix(name, "..") {
+		return fmt.Errorf("invalid keystore file name %q, must be a plain file name not starting with \"..\"", name)
+	}
+	return nil
+}
+
+// keystorePassword returns the password of the volume's keystores and
+// truststores.
+func keystorePassword(meta metadata.Metadata) string {
+	password, ok := meta.VolumeContext[volumeContextKeystorePassword]
+	if !ok {
+		return defaultKeystorePassword
+	}
+	return password
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/cert-manager/csi-lib/metadata"
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func Test_keystoreFiles(t *testing.T) {
+	capk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: cmapi.CertificateSpec{CommonName: "my-ca", IsCA: true}})
+	require.NoError(t, err)
+	caPEM, ca, err := utilpki.SignCertificate(caTmpl, caTmpl, capk.Public(), capk)
+	require.NoError(t, err)
+
+	leafpk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTmpl, err := utilpki.CertificateTemplateFromCertificate(
+		&cmapi.Certificate{
+			Spec: cmapi.CertificateSpec{URIs: []string{"spiffe://cert-manager.io/ns/sandbox/sa/default"}},
+		},
+	)
+	require.NoError(t, err)
+	leafPEM, _, err := utilpki.SignCertificate(leafTmpl, ca, leafpk.Public(), capk)
+	require.NoError(t, err)
+	keyPEM, err := utilpki.EncodePrivateKey(leafpk, cmapi.PKCS8)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		volumeContext map[string]string
+		caPEM         []byte
+		expFiles      []string
+		expPassword   string
+		expErr        bool
+	}{
+		"no attributes should write no files": {
+			volumeContext: map[string]string{},
+			caPEM:         caPEM,
+		},
+		"keystore should be written with the default password": {
+			volumeContext: map[string]string{volumeContextKeystorePKCS12File: "keystore.p12"},
+			caPEM:         caPEM,
+			expFiles:      []string{"keystore.p12"},
+			expPassword:   defaultKeystorePassword,
+		},
+		"keystore and truststores should be written with the given password": {
+			volumeContext: map[string]string{
+				volumeContextKeystorePKCS12File:   "keystore.p12",
+				volumeContextTruststorePKCS12File: "truststore.p12",
+				volumeContextTruststoreJKSFile:    "truststore.jks",
+				volumeContextKeystorePassword:     "hunter2",
+				volumeContextKeystorePasswordFile: "keystore-password",
+			},
+			caPEM:       caPEM,
+			expFiles:    []string{"keystore.p12", "truststore.p12", "truststore.jks", "keystore-password"},
+			expPassword: "hunter2",
+		},
+		"truststore without root CAs yet should write no truststore": {
+			volumeContext: map[string]string{volumeContextTruststorePKCS12File: "truststore.p12"},
+			expFiles:      []string{},
+		},
+		"file name with a path should error": {
+			volumeContext: map[string]string{volumeContextKeystorePKCS12File: "../keystore.p12"},
+			caPEM:         caPEM,
+			expErr:        true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, err := keystoreFiles(metadata.Metadata{VolumeContext: test.volumeContext}, keyPEM, leafPEM, test.caPEM)
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+			if err != nil {
+				return
+			}
+
+			assert.Len(t, files, len(test.expFiles))
+			for _, name := range test.expFiles {
+				require.Contains(t, files, name)
+			}
+
+			if data, ok := files["keystore.p12"]; ok {
+				key, leaf, _, err := pkcs12.DecodeChain(data, test.expPassword)
+				require.NoError(t, err)
+				assert.Equal(t, leafpk, key)
+				assert.Equal(t, "spiffe://cert-manager.io/ns/sandbox/sa/default", leaf.URIs[0].String())
+			}
+
+			if data, ok := files["truststore.p12"]; ok {
+				cas, err := pkcs12.DecodeTrustStore(data, test.expPassword)
+				require.NoError(t, err)
+				require.Len(t, cas, 1)
+				assert.True(t, cas[0].Equal(ca))
+			}
+
+			if data, ok := files["truststore.jks"]; ok {
+				ks := keystore.New()
+				require.NoError(t, ks.Load(bytes.NewReader(data), []byte(test.expPassword)))
+				assert.Len(t, ks.Aliases(), 1)
+			}
+
+			if data, ok := files["keystore-password"]; ok {
+				assert.Equal(t, test.expPassword, string(data))
+			}
+		})
+	}
+}
+
+func Test_validateKeystoreFileNames(t *testing.T) {
+	reserved := []string{"tls.crt", "tls.key", "ca.crt", "bundle.spiffe", "jwt-svid.token", "jwt-bundle.json", "spire-agent.sock"}
+
+	tests := map[string]struct {
+		volumeContext             map[string]string
+		federatedCAFileNamePrefix string
+		expErr                    bool
+	}{
+		"no attributes should not error": {
+			volumeContext: map[string]string{},
+		},
+		"distinct file names should not error": {
+			volumeContext: map[string]string{
+				volumeContextKeystorePKCS12File:   "keystore.p12",
+				volumeContextTruststorePKCS12File: "truststore.p12",
+				volumeContextTruststoreJKSFile:    "truststore.jks",
+				volumeContextKeystorePasswordFile: "keystore-password",
+			},
+			federatedCAFileNamePrefix: "federated-",
+		},
+		"keystore named as the certificate should error": {
+			volumeContext: map[string]string{volumeContextKeystorePKCS12File: "tls.crt"},
+			expErr:        true,
+		},
+		"keystore named as the private key should error": {
+			volumeContext: map[string]string{volumeContextKeystorePKCS12File: "tls.key"},
+			expErr:        true,
+		},
+		"truststore named as the CA file should error": {
+			volumeContext: map[string]string{volumeContextTruststoreJKSFile: "ca.crt"},
+			expErr:        true,
+		},
+		"password file named as the JWT bundle should error": {
+			volumeContext: map[string]string{
+				volumeContextKeystorePKCS12File:   "keystore.p12",
+				volumeContextKeystorePasswordFile: "jwt-bundle.json",
+			},
+			expErr: true,
+		},
+		"truststore named as a federated CA file should error": {
+			volumeContext:             map[string]string{volumeContextTruststorePKCS12File: "federated-example.org.crt"},
+			federatedCAFileNamePrefix: "federated-",
+			expErr:                    true,
+		},
+		"truststore with the federated prefix should not error if federated CAs are not configured": {
+			volumeContext: map[string]string{volumeContextTruststorePKCS12File: "federated-example.org.crt"},
+		},
+		"truststore named as the volume data directory should error": {
+			volumeContext: map[string]string{volumeContextTruststorePKCS12File: "..data"},
+			expErr:        true,
+		},
+		"password file starting with .. should error": {
+			volumeContext: map[string]string{
+				volumeContextKeystorePKCS12File:   "keystore.p12",
+				volumeContextKeystorePasswordFile: "..password",
+			},
+			expErr: true,
+		},
+		"keystore in a subdirectory should error": {
+			volumeContext: map[string]string{volumeContextKeystorePKCS12File: "certs/keystore.p12"},
+			expErr:        true,
+		},
+		"keystore starting with a single dot should not error": {
+			volumeContext: map[string]string{volumeContextKeystorePKCS12File: ".keystore.p12"},
+		},
+		"keystore and truststore with the same name should error": {
+			volumeContext: map[string]string{
+				volumeContextKeystorePKCS12File:   "store.p12",
+				volumeContextTruststorePKCS12File: "store.p12",
+			},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateKeystoreFileNames(metadata.Metadata{VolumeContext: test.volumeContext}, reserved, test.federatedCAFileNamePrefix)
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+		})
+	}
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"sync"
+	"time"
+
+	"github.com/cert-manager/csi-lib/storage"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// metricsNamespace is the namespace of all driver metrics.
+	metricsNamespace = "csi_driver_spiffe"
+
+	// Reasons an issuance failed, used as the reason label of
+	// issuanceFailures.
+	issuanceFailureGeneratePrivateKey = "generate_private_key"
+	issuanceFailureGenerateRequest    = "generate_request"
+	issuanceFailureSignRequest        = "sign_request"
+	issuanceFailureCertificateRequest = "certificate_request"
+	issuanceFailureWriteKeypair       = "write_keypair"
+)
+
+var (
+	// caManagerVolumes is the number of managed volumes by whether their CA
+	// files are on the current trust bundle.
+	caManagerVolumes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "ca_manager",
+		Name:      "volumes",
+		Help: "Number of managed volumes by whether their CA files were updated to the current " +
+			"trust bundle (state=\"current\") or failed to be (state=\"failed\").",
+	}, []string{"state"})
+
+	// issuanceAttempts is the number of certificate issuances started.
+	issuanceAttempts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "issuance",
+		Name:      "attempts_total",
+		Help:      "Number of certificate issuances started for managed volumes.",
+	})
+
+	// issuanceSuccesses is the number of certificate issuances which were
+	// written to their volume.
+	issuanceSuccesses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "issuance",
+		Name:      "successes_total",
+		Help:      "Number of certificate issuances which were written to their volume.",
+	})
+
+	// issuanceFailures is the number of certificate issuances which failed,
+	// by reason.
+	issuanceFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "issuance",
+		Name:      "failures_total",
+		Help: "Number of certificate issuances which failed, by reason. A reason of " +
+			"\"certificate_request\" means the CertificateRequest was not signed in time, " +
+			"for example because it was denied or failed.",
+	}, []string{"reason"})
+
+	// issuanceDuration is the time from a certificate issuance starting, to it
+	// being written to its volume.
+	issuanceDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "issuance",
+		Name:      "duration_seconds",
+		Help:      "Time from a certificate issuance starting to it being written to its volume.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 14),
+	})
+
+	// trustBundleInfo exposes the fingerprint of the current trust bundle.
+	trustBundleInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "trust_bundle",
+		Name:      "info",
+		Help:      "The SHA-256 fingerprint of the current trust bundle written to volumes. Always 1.",
+	}, []string{"fingerprint"})
+
+	// trustBundleVersion is the number of distinct trust bundles seen.
+	trustBundleVersion = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "trust_bundle",
+		Name:      "version",
+		Help:      "Version of the current trust bundle, incremented each time it changes since the driver started.",
+	})
+)
+
+var (
+	volumesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "volumes"),
+		"Number of volumes managed by the driver.",
+		nil, nil,
+	)
+
+	volumeCertificateExpirationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "volume", "certificate_expiration_timestamp_seconds"),
+		"Time the earliest expiring certificate of the volumes of a pod expires, as a Unix timestamp.",
+		[]string{"pod_namespace", "pod_name"}, nil,
+	)
+
+	volumeCertificateRenewalDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "volume", "certificate_renewal_timestamp_seconds"),
+		"Time the certificate of the volumes of a pod is next due to be renewed, as a Unix timestamp.",
+		[]string{"pod_namespace", "pod_name"}, nil,
+	)
+
+	caManagerSinceLastSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "ca_manager", "seconds_since_last_success"),
+		"Seconds since the CA files of all managed volumes were last updated successfully, "+
+			"or since the driver started if they have not been yet.",
+		nil, nil,
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		caManagerVolumes,
+		issuanceAttempts,
+		issuanceSuccesses,
+		issuanceFailures,
+		issuanceDuration,
+		trustBundleInfo,
+		trustBundleVersion,
+	)
+}
+
+// issuanceTracker records metrics of certificate issuances, tracking when
+// the in-flight issuance of each volume started.
+type issuanceTracker struct {
+	// lock is used as a semaphore for accessing the started map.
+	lock sync.Mutex
+
+	// started is when the in-flight issuance of each volume started, keyed
+	// by volume ID.
+	started map[string]time.Time
+}
+
+// newIssuanceTracker constructs a new issuanceTracker.
+func newIssuanceTracker() *issuanceTracker {
+	return &issuanceTracker{
+		started: make(map[string]time.Time),
+	}
+}
+
+// start records an issuance being started for the volume. If the previous
+// issuance of the volume did not complete, its CertificateRequest was not
+// signed and it is recorded as failed.
+func (i *issuanceTracker) start(volumeID string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if _, ok := i.started[volumeID]; ok {
+		issuanceFailures.WithLabelValues(issuanceFailureCertificateRequest).Inc()
+	}
+
+	issuanceAttempts.Inc()
+	i.started[volumeID] = time.Now()
+}
+
+// fail records the in-flight issuance of the volume as failed for the given
+// reason, if err is not nil. Returns err.
+func (i *issuanceTracker) fail(volumeID, reason string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	issuanceFailures.WithLabelValues(reason).Inc()
+	delete(i.started, volumeID)
+
+	return err
+}
+
+// succeed records the in-flight issuance of the volume as succeeded.
+func (i *issuanceTracker) succeed(volumeID string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	issuanceSuccesses.Inc()
+	if started, ok := i.started[volumeID]; ok {
+		issuanceDuration.Observe(time.Since(started).Seconds())
+		delete(i.started, volumeID)
+	}
+}
+
+// forget drops the in-flight issuances of volumes which are no longer managed.
+func (i *issuanceTracker) forget(volumeIDs []string) {
+	managed := make(map[string]struct{}, len(volumeIDs))
+	for _, volumeID := range volumeIDs {
+		managed[volumeID] = struct{}{}
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for volumeID := range i.started {
+		if _, ok := managed[volumeID]; !ok {
+			delete(i.started, volumeID)
+		}
+	}
+}
+
+// volumeCollector is a prometheus.Collector which exposes metrics of the
+// managed volumes, read from the store on each scrape.
+type volumeCollector struct {
+	// log is the logger for volumeCollector.
+	log logr.Logger
+
+	// store is the csi-lib file system storage implementation.
+	store *storage.Filesystem
+
+	// certFileName is the name of the certificate file written to volumes.
+	certFileName string
+
+	// camanager is used to expose how long it has been since the CA files
+	// were last updated successfully.
+	camanager *camanager
+
+	// issuance is pruned of volumes which are no longer managed.
+	issuance *issuanceTracker
+}
+
+// Describe implements prometheus.Collector.
+func (v *volumeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- volumesDesc
+	ch <- volumeCertificateExpirationDesc
+	ch <- volumeCertificateRenewalDesc
+	ch <- caManagerSinceLastSuccessDesc
+}
+
+// Collect implements prometheus.Collector.
+func (v *volumeCollector) Collect(ch chan<- prometheus.Metric) {
+	if lastSuccess := v.camanager.lastSuccessTime(); !lastSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(caManagerSinceLastSuccessDesc, prometheus.GaugeValue, time.Since(lastSuccess).Seconds())
+	}
+
+	volumeIDs, err := v.store.ListVolumes()
+	if err != nil {
+		v.log.Error(err, "failed to list managed volumes for metrics")
+		return
+	}
+
+	v.issuance.forget(volumeIDs)
+
+	ch <- prometheus.MustNewConstMetric(volumesDesc, prometheus.GaugeValue, float64(len(volumeIDs)))
+
+	// Volumes are labelled by their pod only, to keep the number of series
+	// bounded. A pod with more than one volume reports the earliest time of
+	// its volumes.
+	expirations := make(map[podKey]time.Time)
+	renewals := make(map[podKey]time.Time)
+	earliest := func(times map[podKey]time.Time, key podKey, t time.Time) {
+		if existing, ok := times[key]; !ok || t.Before(existing) {
+			times[key] = t
+		}
+	}
+
+	for _, volumeID := range volumeIDs {
+		meta, err := v.store.ReadMetadata(volumeID)
+		if err != nil {
+			v.log.V(2).Info("failed to read metadata of volume for metrics", "volume", volumeID, "error", err)
+			continue
+		}
+
+		key := podKey{namespace: meta.VolumeContext[volumeContextPodNamespace], name: meta.VolumeContext[volumeContextPodName]}
+
+		if meta.NextIssuanceTime != nil {
+			earliest(renewals, key, *meta.NextIssuanceTime)
+		}
+
+		// The volume has not been issued a certificate yet.
+		certData, err := v.store.ReadFile(volumeID, v.certFileName)
+		if err != nil {
+			continue
+		}
+		block, _ := pem.Decode(certData)
+		if block == nil {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			v.log.V(2).Info("failed to parse certificate of volume for metrics", "volume", volumeID, "error", err)
+			continue
+		}
+		earliest(expirations, key, cert.NotAfter)
+	}
+
+	for key, renewal := range renewals {
+		ch <- prometheus.MustNewConstMetric(volumeCertificateRenewalDesc, prometheus.GaugeValue,
+			float64(renewal.Unix()), key.namespace, key.name)
+	}
+	for key, expiration := range expirations {
+		ch <- prometheus.MustNewConstMetric(volumeCertificateExpirationDesc, prometheus.GaugeValue,
+			float64(expiration.Unix()), key.namespace, key.name)
+	}
+}
+
+// podKey identifies the pod a volume is mounted into.
+type podKey struct {
+	namespace, name string
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/cert-manager/csi-lib/metadata"
+	"github.com/cert-manager/csi-lib/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
+)
+
+func Test_issuanceTracker(t *testing.T) {
+	i := newIssuanceTracker()
+
+	attempts := testutil.ToFloat64(issuanceAttempts)
+	successes := testutil.ToFloat64(issuanceSuccesses)
+	durations := testutil.CollectAndCount(issuanceDuration)
+	failures := func(reason string) float64 {
+		return testutil.ToFloat64(issuanceFailures.WithLabelValues(reason))
+	}
+	signFailures := failures(issuanceFailureSignRequest)
+	crFailures := failures(issuanceFailureCertificateRequest)
+
+	t.Log("should record a successful issuance")
+	i.start("vol-1")
+	i.succeed("vol-1")
+	assert.Equal(t, attempts+1, testutil.ToFloat64(issuanceAttempts))
+	assert.Equal(t, successes+1, testutil.ToFloat64(issuanceSuccesses))
+	assert.Equal(t, durations, testutil.CollectAndCount(issuanceDuration), "expected a single histogram")
+
+	t.Log("should record a failed issuance by reason, and pass through the error")
+	i.start("vol-1")
+	err := errors.New("this is an error")
+	assert.Equal(t, err, i.fail("vol-1", issuanceFailureSignRequest, err))
+	assert.NoError(t, i.fail("vol-1", issuanceFailureSignRequest, nil))
+	assert.Equal(t, signFailures+1, failures(issuanceFailureSignRequest))
+
+	t.Log("should record an issuance which did not complete as a failed CertificateRequest")
+	i.start("vol-1")
+	i.start("vol-1")
+	assert.Equal(t, crFailures+1, failures(issuanceFailureCertificateRequest))
+
+	t.Log("should forget issuances of volumes which are no longer managed")
+	i.start("vol-2")
+	i.forget([]string{"vol-2"})
+	assert.Len(t, i.started, 1)
+	i.start("vol-1")
+	assert.Equal(t, crFailures+1, failures(issuanceFailureCertificateRequest), "expected the forgotten issuance to not be recorded as failed")
+	i.forget(nil)
+	assert.Empty(t, i.started)
+}
+
+func Test_volumeCollector(t *testing.T) {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: cmapi.CertificateSpec{CommonName: "my-cert"}})
+	require.NoError(t, err)
+	tmpl.NotAfter = time.Unix(2000000000, 0)
+	certPEM, _, err := utilpki.SignCertificate(tmpl, tmpl, pk.Public(), pk)
+	require.NoError(t, err)
+
+	store := storage.NewMemoryFS()
+
+	nextIssuanceTime := time.Unix(1900000000, 0)
+	issued := metadata.Metadata{
+		VolumeID:         "vol-1",
+		VolumeContext:    map[string]string{volumeContextPodNamespace: "sandbox", volumeContextPodName: "my-pod"},
+		NextIssuanceTime: &nextIssuanceTime,
+	}
+	_, err = store.RegisterMetadata(issued)
+	require.NoError(t, err)
+	require.NoError(t, store.WriteFiles(issued, map[string][]byte{"tls.crt": certPEM}))
+
+	_, err = store.RegisterMetadata(metadata.Metadata{VolumeID: "vol-2"})
+	require.NoError(t, err)
+
+	laterIssuanceTime := time.Unix(1950000000, 0)
+	_, err = store.RegisterMetadata(metadata.Metadata{
+		VolumeID:         "vol-3",
+		VolumeContext:    map[string]string{volumeContextPodNamespace: "sandbox", volumeContextPodName: "my-pod"},
+		NextIssuanceTime: &laterIssuanceTime,
+	})
+	require.NoError(t, err)
+
+	c := &volumeCollector{
+		log:          ktesting.NewLogger(t, ktesting.DefaultConfig),
+		store:        store,
+		certFileName: "tls.crt",
+		camanager:    &camanager{},
+		issuance:     newIssuanceTracker(),
+	}
+
+	t.Log("should expose the managed volumes, and the earliest certificate expiry and renewal of the volumes of each pod")
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(fmt.Sprintf(`
+# HELP csi_driver_spiffe_volume_certificate_expiration_timestamp_seconds Time the earliest expiring certificate of the volumes of a pod expires, as a Unix timestamp.
+# TYPE csi_driver_spiffe_volume_certificate_expiration_timestamp_seconds gauge
+csi_driver_spiffe_volume_certificate_expiration_timestamp_seconds{pod_name="my-pod",pod_namespace="sandbox"} %d
+# HELP csi_driver_spiffe_volume_certificate_renewal_timestamp_seconds Time the certificate of the volumes of a pod is next due to be renewed, as a Unix timestamp.
+# TYPE csi_driver_spiffe_volume_certificate_renewal_timestamp_seconds gauge
+csi_driver_spiffe_volume_certificate_renewal_timestamp_seconds{pod_name="my-pod",pod_namespace="sandbox"} 1.9e+09
+# HELP csi_driver_spiffe_volumes Number of volumes managed by the driver.
+# TYPE csi_driver_spiffe_volumes gauge
+csi_driver_spiffe_volumes 3
+`, tmpl.NotAfter.Unix()))))
+
+	t.Log("should expose how long since the CA manager last succeeded once it is running")
+	lastSuccess := time.Now().Add(-time.Minute)
+	c.camanager.lastSuccess.Store(&lastSuccess)
+	assert.Equal(t, 1, testutil.CollectAndCount(c, "csi_driver_spiffe_ca_manager_seconds_since_last_success"))
+}
+
+func Test_camanager_observeTrustBundle(t *testing.T) {
+	rootCAsChan := make(chan []byte)
+	c := &camanager{rootCAs: rootca.NewMemory(t.Context(), rootCAsChan)}
+
+	version := testutil.ToFloat64(trustBundleVersion)
+
+	t.Log("should not expose a trust bundle until there is one")
+	c.observeTrustBundle()
+	assert.Equal(t, version, testutil.ToFloat64(trustBundleVersion))
+
+	rootCAsChan <- []byte("root cas")
+	require.Eventually(t, func() bool { return len(c.rootCAs.CertificatesPEM()) > 0 }, time.Second, time.Millisecond*10)
+
+	t.Log("should expose the fingerprint of the trust bundle, and increment the version when it changes")
+	c.observeTrustBundle()
+	c.observeTrustBundle()
+	assert.Equal(t, version+1, testutil.ToFloat64(trustBundleVersion))
+	assert.Equal(t, 1, testutil.CollectAndCount(trustBundleInfo))
+	assert.Equal(t, float64(1), testutil.ToFloat64(trustBundleInfo.WithLabelValues(c.trustBundleFingerprint)))
+
+	rootCAsChan <- []byte("new root cas")
+	require.Eventually(t, func() bool { return string(c.rootCAs.CertificatesPEM()) == "new root cas" }, time.Second, time.Millisecond*10)
+	c.observeTrustBundle()
+	assert.Equal(t, version+2, testutil.ToFloat64(trustBundleVersion))
+	assert.Equal(t, 1, testutil.CollectAndCount(trustBundleInfo), "expected only the current fingerprint")
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+)
+
+// spiffeBundleFile encodes the root CA certificates as a SPIFFE trust bundle
+// to be written to volumes. The bundle sequence number is increased every time
+// the root CA certificates change.
+type spiffeBundleFile struct {
+	// trustDomain is the trust domain of the bundle.
+	trustDomain spiffeid.TrustDomain
+
+	// fileName is the name used when writing the bundle to volumes.
+	fileName string
+
+	// refreshHint is the refresh hint set on the bundle.
+	refreshHint time.Duration
+
+	// lock guards access to the fields below.
+	lock sync.Mutex
+
+	// certificatesPEM are the root CA certificates bundleJSON was encoded
+	// from.
+	certificatesPEM []byte
+
+	// sequenceNumber is the sequence number of bundleJSON.
+	sequenceNumber uint64
+
+	// bundleJSON is the encoded bundle of certificatesPEM.
+	bundleJSON []byte
+}
+
+// newSPIFFEBundleFile constructs a new spiffeBundleFile. Defaults to a
+// refresh hint of 5 minutes if zero.
+func newSPIFFEBundleFile(trustDomain, fileName string, refreshHint time.Duration) (*spiffeBundleFile, error) {
+	td, err := spiffeid.TrustDomainFromString(trustDomain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust domain %q: %w", trustDomain, err)
+	}
+
+	if refreshHint == 0 {
+		refreshHint = time.Minute * 5
+	}
+
+	return &spiffeBundleFile{
+		trustDomain: td,
+		fileName:    fileName,
+		refreshHint: refreshHint,
+	}, nil
+}
+
+// files returns the SPIFFE bundle of the root CA certificates, keyed by the
+// file name it is written to in volumes. Sequence numbers are based on the
+// current time so that they keep increasing across restarts.
+func (s *spiffeBundleFile) files(certificatesPEM []byte) (map[string][]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.bundleJSON != nil && bytes.Equal(s.certificatesPEM, certificatesPEM) {
+		return map[string][]byte{s.fileName: s.bundleJSON}, nil
+	}
+
+	authorities, err := pki.DecodeX509CertificateSetBytes(certificatesPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode root CA certificates for SPIFFE bundle: %w", err)
+	}
+
+	sequenceNumber := max(s.sequenceNumber+1, uint64(time.Now().Unix()))
+
+	bundle := spiffebundle.FromX509Authorities(s.trustDomain, authorities)
+	bundle.SetRefreshHint(s.refreshHint)
+	bundle.SetSequenceNumber(sequenceNumber)
+
+	bundleJSON, err := bundle.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SPIFFE bundle: %w", err)
+	}
+
+	s.certificatesPEM, s.sequenceNumber, s.bundleJSON = certificatesPEM, sequenceNumber, bundleJSON
+
+	return map[string][]byte{s.fileName: bundleJSON}, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_spiffeBundleFile(t *testing.T) {
+	newCA := func(commonName string) ([]byte, *x509.Certificate) {
+		pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		tmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: cmapi.CertificateSpec{CommonName: commonName, IsCA: true}})
+		require.NoError(t, err)
+		caPEM, ca, err := utilpki.SignCertificate(tmpl, tmpl, pk.Public(), pk)
+		require.NoError(t, err)
+		return caPEM, ca
+	}
+
+	ca1PEM, ca1 := newCA("ca-1")
+	ca2PEM, ca2 := newCA("ca-2")
+
+	s, err := newSPIFFEBundleFile("foo.bar", "bundle.spiffe.json", time.Minute)
+	require.NoError(t, err)
+
+	parse := func(files map[string][]byte) *spiffebundle.Bundle {
+		bundle, err := spiffebundle.Parse(spiffeid.RequireTrustDomainFromString("foo.bar"), files["bundle.spiffe.json"])
+		require.NoError(t, err)
+		return bundle
+	}
+
+	t.Log("should encode the root CAs as a SPIFFE bundle with a refresh hint and sequence number")
+	files, err := s.files(ca1PEM)
+	require.NoError(t, err)
+	bundle := parse(files)
+	assert.Equal(t, []*x509.Certificate{ca1}, bundle.X509Authorities())
+	refreshHint, ok := bundle.RefreshHint()
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, refreshHint)
+	sequenceNumber, ok := bundle.SequenceNumber()
+	assert.True(t, ok)
+
+	t.Log("should return the same bundle if the root CAs are unchanged")
+	sameFiles, err := s.files(ca1PEM)
+	require.NoError(t, err)
+	assert.Equal(t, files, sameFiles)
+
+	t.Log("should increase the sequence number when the root CAs change")
+	files, err = s.files(append(ca1PEM, ca2PEM...))
+	require.NoError(t, err)
+	bundle = parse(files)
+	assert.Equal(t, []*x509.Certificate{ca1, ca2}, bundle.X509Authorities())
+	newSequenceNumber, _ := bundle.SequenceNumber()
+	assert.Greater(t, newSequenceNumber, sequenceNumber)
+
+	t.Log("should error on invalid root CAs")
+	_, err = s.files([]byte("not a certificate"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cert-manager/csi-lib/manager/util"
+	"github.com/cert-manager/csi-lib/metadata"
+	"github.com/go-jose/go-jose/v4/jwt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// volumeContextPodNamespace is the volume context key set by the kubelet
+	// to the namespace of the mounting pod.
+	volumeContextPodNamespace = "csi.storage.k8s.io/pod.namespace"
+
+	// volumeContextPodUID is the volume context key set by the kubelet to the
+	// UID of the mounting pod.
+	volumeContextPodUID = "csi.storage.k8s.io/pod.uid"
+
+	// volumeContextServiceAccountName is the volume context key set by the
+	// kubelet to the ServiceAccount name of the mounting pod.
+	volumeContextServiceAccountName = "csi.storage.k8s.io/serviceAccount.name"
+
+	// serviceAccountUsernamePrefix is the prefix of the usernames of
+	// ServiceAccounts.
+	serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+	// podNameExtraKey and podUIDExtraKey are the user info extra keys holding
+	// the name and UID of the pod a ServiceAccount token is bound to.
+	podNameExtraKey = "authentication.kubernetes.io/pod-name"
+	podUIDExtraKey  = "authentication.kubernetes.io/pod-uid"
+)
+
+// tokenIdentity is the identity of the mounting pod, as asserted by its
+// ServiceAccount token.
+type tokenIdentity struct {
+	// namespace and serviceAccount are the namespace and name of the pod's
+	// ServiceAccount.
+	namespace, serviceAccount string
+
+	// podName and podUID are the name and UID of the pod the token is bound
+	// to. Empty if the token is not bound to a pod.
+	podName, podUID string
+}
+
+// identityFromToken returns the identity of the mounting pod from the
+// ServiceAccount token in the volume metadata.
+//
+// When creating CertificateRequests by impersonating the pod, the token is
+// used against the API server anyway, which is the source of trust for auth by
+// definition, so its claims don't need verifying here. When the driver uses
+// its own ServiceAccount, nothing else verifies the token, so it is verified
+// with a TokenReview and cross-checked against the pod info in the volume
+// context.
+func (d *Driver) identityFromToken(meta metadata.Metadata) (tokenIdentity, error) {
+	// Extract the service account token from the volume metadata in order to
+	// derive the service account, and thus identity of the pod.
+	token, err := util.EmptyAudienceTokenFromMetadata(meta)
+	if err != nil {
+		return tokenIdentity{}, err
+	}
+
+	var id tokenIdentity
+	if d.useOwnServiceAccount {
+		id, err = d.reviewToken(token)
+	} else {
+		id, err = unverifiedTokenIdentity(token)
+	}
+	if err != nil {
+		return tokenIdentity{}, err
+	}
+
+	if len(id.serviceAccount) == 0 || len(id.namespace) == 0 {
+		return tokenIdentity{}, fmt.Errorf("missing namespace or serviceaccount name in request token: %+v", id)
+	}
+
+	if d.useOwnServiceAccount {
+		if err := checkVolumeContextIdentity(meta, id); err != nil {
+			return tokenIdentity{}, err
+		}
+	}
+
+	return id, nil
+}
+
+// unverifiedTokenIdentity returns the identity claimed by the token, without
+// verifying the token.
+func unverifiedTokenIdentity(token string) (tokenIdentity, error) {
+	// see comment for validSigningAlgs for more details on how the algorithms were chosen
+	jwttoken, err := jwt.ParseSigned(token, validSigningAlgs)
+	if err != nil {
+		return tokenIdentity{}, fmt.Errorf("failed to parse token request token: %w", err)
+	}
+
+	claims := struct {
+		KubernetesIO struct {
+			Namespace      string `json:"namespace"`
+			ServiceAccount struct {
+				Name string `json:"name"`
+			} `json:"serviceaccount"`
+			Pod struct {
+				Name string `json:"name"`
+				UID  string `json:"uid"`
+			} `json:"pod"`
+		} `json:"kubernetes.io"`
+	}{}
+
+	if err := jwttoken.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return tokenIdentity{}, fmt.Errorf("failed to decode token request token: %w", err)
+	}
+
+	return tokenIdentity{
+		namespace:      claims.KubernetesIO.Namespace,
+		serviceAccount: claims.KubernetesIO.ServiceAccount.Name,
+		podName:        claims.KubernetesIO.Pod.Name,
+		podUID:         claims.KubernetesIO.Pod.UID,
+	}, nil
+}
+
+// reviewToken verifies the token with a TokenReview, and returns the identity
+// the API server authenticated it as.
+func (d *Driver) reviewToken(token string) (tokenIdentity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	review, err := d.kubeClient.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return tokenIdentity{}, fmt.Errorf("failed to review token request token: %w", err)
+	}
+
+	if !review.Status.Authenticated {
+		return tokenIdentity{}, fmt.Errorf("token request token was not authenticated: %s", review.Status.Error)
+	}
+
+	username := review.Status.User.Username
+	namespace, name, ok := strings.Cut(strings.TrimPrefix(username, serviceAccountUsernamePrefix), ":")
+	if !strings.HasPrefix(username, serviceAccountUsernamePrefix) || !ok {
+		return tokenIdentity{}, fmt.Errorf("token request token does not belong to a ServiceAccount: %q", username)
+	}
+
+	id := tokenIdentity{namespace: namespace, serviceAccount: name}
+	if v := review.Status.User.Extra[podNameExtraKey]; len(v) == 1 {
+		id.podName = v[0]
+	}
+	if v := review.Status.User.Extra[podUIDExtraKey]; len(v) == 1 {
+		id.podUID = v[0]
+	}
+
+	return id, nil
+}
+
+// checkVolumeContextIdentity checks that the pod info the kubelet added to the
+// volume context agrees with the token's identity.
+func checkVolumeContextIdentity(meta metadata.Metadata, id tokenIdentity) error {
+	for _, check := range []struct {
+		key, exp string
+	}{
+		{volumeContextPodNamespace, id.namespace},
+		{volumeContextServiceAccountName, id.serviceAccount},
+		{volumeContextPodName, id.podName},
+		{volumeContextPodUID, id.podUID},
+	} {
+		got, ok := meta.VolumeContext[check.key]
+		if !ok {
+			return fmt.Errorf("missing %q in volume context, pod info on mount must be enabled", check.key)
+		}
+
+		if got != check.exp {
+			return fmt.Errorf("volume context %q does not match request token, exp=%q got=%q", check.key, check.exp, got)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cert-manager/csi-lib/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_reviewToken(t *testing.T) {
+	tests := map[string]struct {
+		status    authenticationv1.TokenReviewStatus
+		reviewErr error
+		expID     tokenIdentity
+		expErr    bool
+	}{
+		"authenticated pod-bound token should return the pod's identity": {
+			status: authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authenticationv1.UserInfo{
+					Username: "system:serviceaccount:sandbox:sleep",
+					Extra: map[string]authenticationv1.ExtraValue{
+						podNameExtraKey: {"sleep-abc"},
+						podUIDExtraKey:  {"1234"},
+					},
+				},
+			},
+			expID: tokenIdentity{namespace: "sandbox", serviceAccount: "sleep", podName: "sleep-abc", podUID: "1234"},
+		},
+		"unauthenticated token should error": {
+			status: authenticationv1.TokenReviewStatus{
+				Authenticated: false,
+				Error:         "invalid signature",
+			},
+			expErr: true,
+		},
+		"authenticated token of a non-ServiceAccount user should error": {
+			status: authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authenticationv1.UserInfo{Username: "alice"},
+			},
+			expErr: true,
+		},
+		"failed review should error": {
+			reviewErr: errors.New("connection refused"),
+			expErr:    true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			kubeClient := fake.NewClientset()
+			kubeClient.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+				assert.Equal(t, "my-token", review.Spec.Token)
+				review.Status = test.status
+				return true, review, test.reviewErr
+			})
+
+			d := &Driver{kubeClient: kubeClient, useOwnServiceAccount: true}
+			id, err := d.reviewToken("my-token")
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+			assert.Equal(t, test.expID, id)
+		})
+	}
+}
+
+func Test_checkVolumeContextIdentity(t *testing.T) {
+	id := tokenIdentity{namespace: "sandbox", serviceAccount: "sleep", podName: "sleep-abc", podUID: "1234"}
+
+	podInfo := func(mutate func(map[string]string)) map[string]string {
+		volumeContext := map[string]string{
+			volumeContextPodNamespace:       "sandbox",
+			volumeContextServiceAccountName: "sleep",
+			volumeContextPodName:            "sleep-abc",
+			volumeContextPodUID:             "1234",
+		}
+		mutate(volumeContext)
+		return volumeContext
+	}
+
+	tests := map[string]struct {
+		volumeContext map[string]string
+		expErr        bool
+	}{
+		"matching pod info should not error": {
+			volumeContext: podInfo(func(map[string]string) {}),
+		},
+		"missing pod info should error": {
+			volumeContext: podInfo(func(m map[string]string) { delete(m, volumeContextPodUID) }),
+			expErr:        true,
+		},
+		"different namespace should error": {
+			volumeContext: podInfo(func(m map[string]string) { m[volumeContextPodNamespace] = "kube-system" }),
+			expErr:        true,
+		},
+		"different ServiceAccount should error": {
+			volumeContext: podInfo(func(m map[string]string) { m[volumeContextServiceAccountName] = "admin" }),
+			expErr:        true,
+		},
+		"different pod name should error": {
+			volumeContext: podInfo(func(m map[string]string) { m[volumeContextPodName] = "other" }),
+			expErr:        true,
+		},
+		"different pod UID should error": {
+			volumeContext: podInfo(func(m map[string]string) { m[volumeContextPodUID] = "5678" }),
+			expErr:        true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkVolumeContextIdentity(metadata.Metadata{VolumeContext: test.volumeContext}, id)
+			require.Equalf(t, test.expErr, err != nil, "%v", err)
+		})
+	}
+}
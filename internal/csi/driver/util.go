@@ -18,22 +18,30 @@ package driver
 
 import (
 	"crypto"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
+	cryptorand "crypto/rand"
 	"crypto/x509"
 	"encoding/asn1"
 	"encoding/pem"
 	"fmt"
+	"math/rand/v2"
+	"strconv"
 	"time"
 
 	cmpki "github.com/cert-manager/cert-manager/pkg/util/pki"
 	"github.com/cert-manager/csi-lib/metadata"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/keyalgorithm"
 )
 
-// generatePrivateKey generates an ECDSA private key, which is the only currently supported type
-func generatePrivateKey(_ metadata.Metadata) (crypto.PrivateKey, error) {
-	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+// generatePrivateKey generates a private key of the algorithm and size
+// requested by the volume attributes. Defaults to ECDSA P-256.
+func generatePrivateKey(meta metadata.Metadata) (crypto.PrivateKey, error) {
+	alg, err := keyalgorithm.Parse(meta.VolumeContext[volumeContextKeyAlgorithm])
+	if err != nil {
+		return nil, err
+	}
+
+	return keyalgorithm.Generate(alg, meta.VolumeContext[volumeContextKeySize])
 }
 
 // signRequest signs the given X.509 certificate request with the given key.
@@ -64,7 +72,7 @@ func signRequest(_ metadata.Metadata, key crypto.PrivateKey, request *x509.Certi
 		request.URIs = nil
 	}
 
-	csrDer, err := x509.CreateCertificateRequest(rand.Reader, request, key)
+	csrDer, err := x509.CreateCertificateRequest(cryptorand.Reader, request, key)
 	if err != nil {
 		return nil, err
 	}
@@ -75,20 +83,94 @@ func signRequest(_ metadata.Metadata, key crypto.PrivateKey, request *x509.Certi
 	}), nil
 }
 
+// renewBefore is how long before expiry a certificate should be renewed. It
+// is either a fixed duration, or a fraction of the certificate's lifetime.
+type renewBefore struct {
+	// duration is the fixed duration before expiry to renew. Used if non-zero.
+	duration time.Duration
+
+	// fraction is the fraction of the certificate's lifetime before expiry to
+	// renew.
+	fraction float64
+}
+
+// defaultRenewBefore renews a certificate once it is 2/3rds of the way through
+// its lifetime.
+var defaultRenewBefore = renewBefore{fraction: 1.0 / 3}
+
+// parseRenewBefore parses a renew before value, either as a duration (e.g.
+// "20m") or a fraction of the certificate lifetime between 0 and 1 (e.g.
+// "0.25"). An empty value returns defaultRenewBefore.
+func parseRenewBefore(value string) (renewBefore, error) {
+	if len(value) == 0 {
+		return defaultRenewBefore, nil
+	}
+
+	if fraction, err := strconv.ParseFloat(value, 64); err == nil {
+		if fraction <= 0 || fraction >= 1 {
+			return renewBefore{}, fmt.Errorf("renew before fraction must be between 0 and 1 exclusive: %q", value)
+		}
+		return renewBefore{fraction: fraction}, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return renewBefore{}, fmt.Errorf("renew before must be a duration or a fraction between 0 and 1: %q", value)
+	}
+	if duration <= 0 {
+		return renewBefore{}, fmt.Errorf("renew before duration must be positive: %q", value)
+	}
+
+	return renewBefore{duration: duration}, nil
+}
+
+// before returns how long before expiry a certificate with the given lifetime
+// should be renewed. Falls back to defaultRenewBefore if unset, or if a fixed
+// duration is not shorter than the lifetime.
+func (r renewBefore) before(lifetime time.Duration) time.Duration {
+	if r == (renewBefore{}) {
+		r = defaultRenewBefore
+	}
+
+	if r.duration > 0 {
+		if r.duration < lifetime {
+			return r.duration
+		}
+		r = defaultRenewBefore
+	}
+
+	return time.Duration(float64(lifetime) * r.fraction)
+}
+
 // calculateNextIssuanceTime returns the time when the certificate should be
-// renewed. This will be 2/3rds the duration of the leaf certificate's validity period.
-func calculateNextIssuanceTime(chain []byte) (time.Time, error) {
+// renewed. This is renewBefore the end of the leaf certificate's validity
+// period, moved earlier by a random jitter of up to the jitter fraction of
+// the validity period. The renewal time is never before the certificate's
+// NotBefore.
+func calculateNextIssuanceTime(chain []byte, renewBefore renewBefore, jitter float64) (time.Time, error) {
 	block, _ := pem.Decode(chain)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("parsing issued certificate: failed to decode PEM")
+	}
 
 	crt, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("parsing issued certificate: %w", err)
 	}
 
-	// Renew once a certificate is 2/3rds of the way through its actual lifetime.
 	actualDuration := crt.NotAfter.Sub(crt.NotBefore)
 
-	renewBeforeNotAfter := actualDuration / 3
+	nextIssuanceTime := crt.NotAfter.Add(-renewBefore.before(actualDuration))
+
+	if jitter > 0 {
+		// Spread renewals of certificates issued at the same time, such as
+		// after a node restart, so the issuer is not flooded.
+		// #nosec G404 -- jitter does not need a secure random source
+		nextIssuanceTime = nextIssuanceTime.Add(-time.Duration(rand.Float64() * jitter * float64(actualDuration)))
+		if nextIssuanceTime.Before(crt.NotBefore) {
+			nextIssuanceTime = crt.NotBefore
+		}
+	}
 
-	return crt.NotAfter.Add(-renewBeforeNotAfter), nil
+	return nextIssuanceTime, nil
 }
@@ -0,0 +1,186 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testChain returns a self-signed certificate PEM valid from notBefore for
+// lifetime.
+func testChain(t *testing.T, notBefore time.Time, lifetime time.Duration) []byte {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(lifetime),
+	}
+
+	chain, _, err := utilpki.SignCertificate(tmpl, tmpl, pk.Public(), pk)
+	require.NoError(t, err)
+
+	return chain
+}
+
+func Test_parseRenewBefore(t *testing.T) {
+	tests := map[string]struct {
+		value  string
+		exp    renewBefore
+		expErr bool
+	}{
+		"empty should return the default": {
+			value: "",
+			exp:   defaultRenewBefore,
+		},
+		"fraction should be parsed": {
+			value: "0.25",
+			exp:   renewBefore{fraction: 0.25},
+		},
+		"duration should be parsed": {
+			value: "20m",
+			exp:   renewBefore{duration: time.Minute * 20},
+		},
+		"fraction of 1 should error": {
+			value:  "1",
+			expErr: true,
+		},
+		"fraction of 0 should error": {
+			value:  "0",
+			expErr: true,
+		},
+		"negative duration should error": {
+			value:  "-5m",
+			expErr: true,
+		},
+		"garbage should error": {
+			value:  "soon",
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, err := parseRenewBefore(test.value)
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+			assert.Equal(t, test.exp, r)
+		})
+	}
+}
+
+func Test_calculateNextIssuanceTime(t *testing.T) {
+	notBefore := time.Now().Truncate(time.Second)
+	chain := testChain(t, notBefore, time.Hour)
+
+	tests := map[string]struct {
+		renewBefore renewBefore
+		exp         time.Time
+	}{
+		"default should renew 2/3rds through the lifetime": {
+			renewBefore: defaultRenewBefore,
+			exp:         notBefore.Add(time.Minute * 40),
+		},
+		"zero value should use the default": {
+			renewBefore: renewBefore{},
+			exp:         notBefore.Add(time.Minute * 40),
+		},
+		"fraction should renew that fraction before expiry": {
+			renewBefore: renewBefore{fraction: 0.25},
+			exp:         notBefore.Add(time.Minute * 45),
+		},
+		"duration should renew that duration before expiry": {
+			renewBefore: renewBefore{duration: time.Minute * 10},
+			exp:         notBefore.Add(time.Minute * 50),
+		},
+		"duration longer than the lifetime should use the default": {
+			renewBefore: renewBefore{duration: time.Hour * 2},
+			exp:         notBefore.Add(time.Minute * 40),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			next, err := calculateNextIssuanceTime(chain, test.renewBefore, 0)
+			require.NoError(t, err)
+			assert.Truef(t, test.exp.Equal(next), "expected %s, got %s", test.exp, next)
+		})
+	}
+}
+
+// Test_calculateNextIssuanceTime_jitter shows how jitter spreads the renewal
+// times of certificates issued at the same moment, such as after a node
+// restart, across a window before the renewal point.
+func Test_calculateNextIssuanceTime_jitter(t *testing.T) {
+	notBefore := time.Now().Truncate(time.Second)
+	lifetime := time.Hour
+	chain := testChain(t, notBefore, lifetime)
+
+	const (
+		samples = 10000
+		buckets = 10
+		jitter  = 0.1
+	)
+
+	// Without jitter, all renewals happen 40 minutes in. With 10% jitter, they
+	// are spread across the 6 minutes before that.
+	renewAt := notBefore.Add(time.Minute * 40)
+	window := time.Duration(jitter * float64(lifetime))
+
+	var counts [buckets]int
+	for range samples {
+		next, err := calculateNextIssuanceTime(chain, defaultRenewBefore, jitter)
+		require.NoError(t, err)
+
+		require.False(t, next.After(renewAt), "renewal must never be later than without jitter")
+		require.False(t, next.Before(renewAt.Add(-window)), "renewal must be within the jitter window")
+
+		bucket := int(renewAt.Sub(next) * buckets / window)
+		counts[min(bucket, buckets-1)]++
+	}
+
+	// Renewals should be spread evenly across the window, each bucket holding
+	// roughly 1/10th of the samples.
+	for i, count := range counts {
+		t.Logf("renewals %s-%s before the renewal point: %d",
+			window*time.Duration(i)/buckets, window*time.Duration(i+1)/buckets, count)
+		assert.InDelta(t, samples/buckets, count, samples/buckets*0.2)
+	}
+}
+
+func Test_calculateNextIssuanceTime_jitterClamped(t *testing.T) {
+	notBefore := time.Now().Truncate(time.Second)
+	chain := testChain(t, notBefore, time.Hour)
+
+	// A renewal point 1 minute in, with up to 50% jitter, must never be moved
+	// before the certificate is valid.
+	for range 1000 {
+		next, err := calculateNextIssuanceTime(chain, renewBefore{duration: time.Minute * 59}, 0.5)
+		require.NoError(t, err)
+		require.False(t, next.Before(notBefore))
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import "sync"
+
+// volumeLocks coordinates writes to the files of volumes. Every writer which
+// reads back files from a volume to write them again, such as the CA and JWT
+// managers, must hold the volume's lock across the read and the write, so
+// that a keypair renewed in between is never overwritten with the old one.
+type volumeLocks struct {
+	// lock is used as a semaphore for accessing the locks map.
+	lock sync.Mutex
+
+	// locks holds the lock of each volume which currently has a writer,
+	// keyed by volume ID.
+	locks map[string]*volumeLock
+}
+
+// volumeLock is the lock of a single volume.
+type volumeLock struct {
+	sync.Mutex
+
+	// refs is the number of writers holding, or waiting on, the lock.
+	refs int
+}
+
+// newVolumeLocks constructs a new empty set of volume locks.
+func newVolumeLocks() *volumeLocks {
+	return &volumeLocks{
+		locks: make(map[string]*volumeLock),
+	}
+}
+
+// lockVolume blocks until the lock of the volume is held, and returns the
+// func to release it. Locks are removed once released by every writer, so
+// unpublished volumes do not leak.
+func (v *volumeLocks) lockVolume(volumeID string) func() {
+	v.lock.Lock()
+	l, ok := v.locks[volumeID]
+	if !ok {
+		l = new(volumeLock)
+		v.locks[volumeID] = l
+	}
+	l.refs++
+	v.lock.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		v.lock.Lock()
+		defer v.lock.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(v.locks, volumeID)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jwtsvid mints JWT-SVIDs signed by a trust domain signing key, and
+// exposes the public keys of the trust domain as a JWT bundle.
+package jwtsvid
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/spiffe/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+)
+
+// signingKey is a loaded trust domain signing key.
+type signingKey struct {
+	// keyID is the JWK thumbprint of the public key, set as the "kid" header of
+	// signed tokens.
+	keyID string
+
+	// signer is used to sign tokens.
+	signer jose.Signer
+
+	// public is the public key of the signing key.
+	public crypto.PublicKey
+}
+
+// Issuer signs JWT-SVIDs with the current trust domain signing key. The
+// previous signing key is kept in the bundle after a rotation so that tokens
+// signed before the rotation continue to verify until they expire.
+type Issuer struct {
+	// trustDomain is the trust domain of the signed JWT-SVIDs and bundle.
+	trustDomain spiffeid.TrustDomain
+
+	// lock guards access to the fields below.
+	lock sync.RWMutex
+
+	// current is the key used to sign new tokens. Nil if no key has been
+	// loaded yet.
+	current *signingKey
+
+	// previous is the signing key which was replaced by current, if any.
+	previous *signingKey
+
+	// subscribers is the list of subscribers that will be sent a message when
+	// the signing key changes.
+	subscribers []chan<- struct{}
+}
+
+// NewIssuer constructs a new Issuer for the given trust domain. No tokens can
+// be signed until a signing key has been set.
+func NewIssuer(trustDomain string) (*Issuer, error) {
+	td, err := spiffeid.TrustDomainFromString(trustDomain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust domain %q: %w", trustDomain, err)
+	}
+
+	return &Issuer{trustDomain: td}, nil
+}
+
+// SetKey replaces the signing key with the PEM encoded private key. A no-op
+// if the key is unchanged. Subscribers are notified when the key changes.
+func (i *Issuer) SetKey(keyPEM []byte) error {
+	key, err := pki.DecodePrivateKeyBytes(keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to decode signing key: %w", err)
+	}
+
+	alg, err := signatureAlgorithm(key)
+	if err != nil {
+		return err
+	}
+
+	thumbprint, err := (&jose.JSONWebKey{Key: key.Public()}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to compute signing key thumbprint: %w", err)
+	}
+	keyID := base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.current != nil && i.current.keyID == keyID {
+		return nil
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: alg, Key: jose.JSONWebKey{Key: key, KeyID: keyID}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to build signer: %w", err)
+	}
+
+	i.previous = i.current
+	i.current = &signingKey{keyID: keyID, signer: signer, public: key.Public()}
+
+	for _, sub := range i.subscribers {
+		go func() { sub <- struct{}{} }()
+	}
+
+	return nil
+}
+
+// Sign returns a JWT-SVID for the given SPIFFE ID and audiences, valid for
+// duration. Returns the token and its expiry.
+func (i *Issuer) Sign(id spiffeid.ID, audiences []string, duration time.Duration) (string, time.Time, error) {
+	if !id.MemberOf(i.trustDomain) {
+		return "", time.Time{}, fmt.Errorf("SPIFFE ID %q is not a member of trust domain %q", id, i.trustDomain)
+	}
+
+	if len(audiences) == 0 {
+		return "", time.Time{}, errors.New("at least one audience is required")
+	}
+
+	i.lock.RLock()
+	current := i.current
+	i.lock.RUnlock()
+
+	if current == nil {
+		return "", time.Time{}, errors.New("no JWT signing key has been loaded")
+	}
+
+	now := time.Now()
+	expiry := now.Add(duration)
+
+	token, err := jwt.Signed(current.signer).Claims(jwt.Claims{
+		Subject:  id.String(),
+		Audience: jwt.Audience(audiences),
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(expiry),
+	}).Serialize()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign JWT-SVID: %w", err)
+	}
+
+	return token, expiry.Truncate(time.Second), nil
+}
+
+// Bundle returns the JWT bundle of the trust domain, containing the public
+// keys of the current and previous signing key.
+func (i *Issuer) Bundle() *jwtbundle.Bundle {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	bundle := jwtbundle.New(i.trustDomain)
+	for _, key := range []*signingKey{i.current, i.previous} {
+		if key != nil {
+			// Key IDs are unique since current and previous always differ.
+			_ = bundle.AddJWTAuthority(key.keyID, key.public)
+		}
+	}
+
+	return bundle
+}
+
+// BundleJSON returns the JWT bundle of the trust domain, encoded as a JWKS
+// document.
+func (i *Issuer) BundleJSON() ([]byte, error) {
+	return i.Bundle().Marshal()
+}
+
+// Subscribe returns a channel which will receive messages when the signing
+// key changes.
+func (i *Issuer) Subscribe() <-chan struct{} {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	sub := make(chan struct{})
+	i.subscribers = append(i.subscribers, sub)
+	return sub
+}
+
+// signatureAlgorithm returns the JWS algorithm used for the given key. Only
+// algorithms permitted by the JWT-SVID specification are supported.
+func signatureAlgorithm(key crypto.Signer) (jose.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+		return "", fmt.Errorf("unsupported ECDSA curve for JWT signing key: %s", k.Curve.Params().Name)
+
+	case *rsa.PrivateKey:
+		return jose.RS256, nil
+
+	default:
+		return "", fmt.Errorf("unsupported JWT signing key type: %T", key)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwtsvid
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strconv"
+	"testing"
+	"time"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/go-logr/logr"
+	"github.com/spiffe/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	spiffejwtsvid "github.com/spiffe/go-spiffe/v2/svid/jwtsvid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/ktesting"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func mustKeyPEM(t *testing.T, key crypto.PrivateKey) []byte {
+	keyPEM, err := pki.EncodePrivateKey(key, cmapi.PKCS8)
+	require.NoError(t, err)
+	return keyPEM
+}
+
+func Test_Sign(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		key       []byte
+		id        string
+		audiences []string
+		expKeyErr bool
+		expErr    bool
+	}{
+		"ECDSA key should sign a valid JWT-SVID": {
+			key:       mustKeyPEM(t, ecKey),
+			id:        "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			audiences: []string{"aud-1", "aud-2"},
+		},
+		"RSA key should sign a valid JWT-SVID": {
+			key:       mustKeyPEM(t, rsaKey),
+			id:        "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			audiences: []string{"aud-1"},
+		},
+		"Ed25519 key should not be accepted": {
+			key:       mustKeyPEM(t, edKey),
+			expKeyErr: true,
+		},
+		"invalid PEM should not be accepted": {
+			key:       []byte("not a key"),
+			expKeyErr: true,
+		},
+		"SPIFFE ID of another trust domain should error": {
+			key:       mustKeyPEM(t, ecKey),
+			id:        "spiffe://other.td/ns/sandbox/sa/sleep",
+			audiences: []string{"aud-1"},
+			expErr:    true,
+		},
+		"no audiences should error": {
+			key:    mustKeyPEM(t, ecKey),
+			id:     "spiffe://foo.bar/ns/sandbox/sa/sleep",
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			i, err := NewIssuer("foo.bar")
+			require.NoError(t, err)
+
+			err = i.SetKey(test.key)
+			assert.Equalf(t, test.expKeyErr, err != nil, "%v", err)
+			if err != nil {
+				return
+			}
+
+			token, expiry, err := i.Sign(spiffeid.RequireFromString(test.id), test.audiences, time.Minute*5)
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+			if err != nil {
+				return
+			}
+
+			svid, err := spiffejwtsvid.ParseAndValidate(token, i.Bundle(), test.audiences[:1])
+			require.NoError(t, err)
+			assert.Equal(t, test.id, svid.ID.String())
+			assert.Equal(t, test.audiences, svid.Audience)
+			assert.True(t, expiry.Equal(svid.Expiry), "exp=%s got=%s", expiry, svid.Expiry)
+		})
+	}
+}
+
+func Test_Sign_noKey(t *testing.T) {
+	i, err := NewIssuer("foo.bar")
+	require.NoError(t, err)
+
+	_, _, err = i.Sign(spiffeid.RequireFromString("spiffe://foo.bar/ns/sandbox/sa/sleep"), []string{"aud"}, time.Minute)
+	assert.Error(t, err)
+
+	bundleJSON, err := i.BundleJSON()
+	require.NoError(t, err)
+	bundle, err := jwtbundle.Parse(spiffeid.RequireTrustDomainFromString("foo.bar"), bundleJSON)
+	require.NoError(t, err)
+	assert.True(t, bundle.Empty())
+}
+
+func Test_SetKey_rotation(t *testing.T) {
+	i, err := NewIssuer("foo.bar")
+	require.NoError(t, err)
+	sub := i.Subscribe()
+
+	id := spiffeid.RequireFromString("spiffe://foo.bar/ns/sandbox/sa/sleep")
+
+	key1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, i.SetKey(mustKeyPEM(t, key1)))
+	<-sub
+
+	oldToken, _, err := i.Sign(id, []string{"aud"}, time.Minute)
+	require.NoError(t, err)
+
+	t.Log("setting the same key again should not notify subscribers")
+	require.NoError(t, i.SetKey(mustKeyPEM(t, key1)))
+	select {
+	case <-sub:
+		assert.Fail(t, "unexpected notification for unchanged key")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	t.Log("rotating the key should keep the previous key in the bundle")
+	key2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, i.SetKey(mustKeyPEM(t, key2)))
+	<-sub
+
+	newToken, _, err := i.Sign(id, []string{"aud"}, time.Minute)
+	require.NoError(t, err)
+
+	assert.Len(t, i.Bundle().JWTAuthorities(), 2)
+	_, err = spiffejwtsvid.ParseAndValidate(oldToken, i.Bundle(), []string{"aud"})
+	assert.NoError(t, err)
+	_, err = spiffejwtsvid.ParseAndValidate(newToken, i.Bundle(), []string{"aud"})
+	assert.NoError(t, err)
+
+	t.Log("rotating again should drop the oldest key")
+	key3, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, i.SetKey(mustKeyPEM(t, key3)))
+	<-sub
+
+	assert.Len(t, i.Bundle().JWTAuthorities(), 2)
+	_, err = spiffejwtsvid.ParseAndValidate(oldToken, i.Bundle(), []string{"aud"})
+	assert.Error(t, err)
+}
+
+func Test_NewFromSecret(t *testing.T) {
+	k8sClient := fake.NewClientBuilder().Build()
+
+	ctx := logr.NewContext(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig))
+	i, err := NewFromSecret(ctx, k8sClient, "foo.bar",
+		types.NamespacedName{Name: "jwt-signing-key", Namespace: "cert-manager"}, "tls.key")
+	require.NoError(t, err)
+
+	key1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "jwt-signing-key", Namespace: "cert-manager"},
+		Data:       map[string][]byte{"tls.key": mustKeyPEM(t, key1)},
+	}
+	require.NoError(t, k8sClient.Create(t.Context(), secret))
+
+	id := spiffeid.RequireFromString("spiffe://foo.bar/ns/sandbox/sa/sleep")
+
+	// The fake client does not replay existing objects to new watchers, so keep
+	// modifying the Secret until the watcher has observed it.
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		_, _, err := i.Sign(id, []string{"aud"}, time.Minute)
+		if !assert.NoError(c, err) {
+			secret.Labels = map[string]string{"generation": strconv.FormatInt(time.Now().UnixNano(), 10)}
+			assert.NoError(c, k8sClient.Update(t.Context(), secret))
+		}
+	}, time.Second*5, time.Millisecond*10)
+
+	t.Log("updating the Secret should rotate the signing key")
+	key2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secret.Data["tls.key"] = mustKeyPEM(t, key2)
+	require.NoError(t, k8sClient.Update(t.Context(), secret))
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Len(c, i.Bundle().JWTAuthorities(), 2)
+	}, time.Second*5, time.Millisecond*10)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwtsvid
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewFromSecret constructs a new Issuer whose signing key is loaded from the
+// dataKey of the given Secret. The Secret is watched, and the signing key is
+// rotated whenever the Secret data changes. If the Secret is deleted, the last
+// loaded key continues to be used. The logger is extracted from ctx via
+// logr.FromContext.
+func NewFromSecret(ctx context.Context, k8sClient client.WithWatch, trustDomain string, secret types.NamespacedName, dataKey string) (*Issuer, error) {
+	i, err := NewIssuer(trustDomain)
+	if err != nil {
+		return nil, err
+	}
+
+	log := logr.FromContextOrDiscard(ctx).
+		WithName("jwt-signing-key-watcher").
+		WithValues("secret-name", secret.Name, "secret-namespace", secret.Namespace)
+
+	go i.watchSecret(ctx, log, k8sClient, secret, dataKey)
+
+	return i, nil
+}
+
+// watchSecret watches the Secret for changes and updates the signing key. It
+// retries on failure with a 5s delay, and returns when ctx is cancelled.
+func (i *Issuer) watchSecret(ctx context.Context, log logr.Logger, k8sClient client.WithWatch, secret types.NamespacedName, dataKey string) {
+LOOP:
+	for {
+		log.Info("Starting / restarting watcher for JWT signing key")
+
+		watcher, err := k8sClient.Watch(ctx, &corev1.SecretList{}, &client.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", secret.Name),
+			Namespace:     secret.Namespace,
+		})
+		if err != nil {
+			log.Error(err, "Failed to create Secret watcher; will retry in 5s")
+			select {
+			case <-ctx.Done():
+				break LOOP
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Received context cancellation, shutting down JWT signing key watcher")
+				watcher.Stop()
+				break LOOP
+
+			case event, open := <-watcher.ResultChan():
+				if !open {
+					log.Info("Received closed channel from Secret watcher, will recreate")
+					watcher.Stop()
+					continue LOOP
+				}
+
+				switch event.Type {
+				case watch.Added, watch.Modified:
+					if err := i.handleSecret(event, dataKey); err != nil {
+						log.Error(err, "Failed to load JWT signing key from Secret")
+						continue
+					}
+					log.Info("Loaded JWT signing key from Secret")
+
+				case watch.Deleted:
+					log.Info("JWT signing key Secret was deleted; continuing to use the last loaded key")
+
+				case watch.Error:
+					log.Error(fmt.Errorf("%v", event.Object), "Got an error event when watching JWT signing key Secret")
+				}
+			}
+		}
+	}
+
+	log.Info("Stopped JWT signing key watcher")
+}
+
+// handleSecret sets the signing key from the Secret in the event.
+func (i *Issuer) handleSecret(event watch.Event, dataKey string) error {
+	secret, ok := event.Object.(*corev1.Secret)
+	if !ok {
+		return fmt.Errorf("got unexpected type for JWT signing key source; this is likely a programming error")
+	}
+
+	keyPEM, ok := secret.Data[dataKey]
+	if !ok || len(keyPEM) == 0 {
+		return fmt.Errorf("missing key in Secret data: %s", dataKey)
+	}
+
+	return i.SetKey(keyPEM)
+}
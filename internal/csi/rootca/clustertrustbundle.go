@@ -0,0 +1,292 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterTrustBundles is an implementation of RootCAs which watches
+// ClusterTrustBundles through the API, and broadcasts a message when the
+// root certificates of any of them change. The root certificates are the
+// deduplicated concatenation of all matching ClusterTrustBundles.
+type clusterTrustBundles struct {
+	// log is the RootCAs clusterTrustBundles logger.
+	log logr.Logger
+
+	// k8sClient is used to list and watch ClusterTrustBundles.
+	k8sClient client.WithWatch
+
+	// signerName, if not empty, selects ClusterTrustBundles by signer name.
+	signerName string
+
+	// selector selects ClusterTrustBundles by label.
+	selector labels.Selector
+
+	// sanitize are the options used to sanitise the root certificates of the
+	// ClusterTrustBundles.
+	sanitize SanitizeOptions
+
+	// lock is used as a semaphore for accessing the fields below.
+	lock sync.RWMutex
+
+	// trustBundles is the PEM content of every matching ClusterTrustBundle,
+	// keyed by name.
+	trustBundles map[string]string
+
+	// certificatesPEM is the current root certificates.
+	certificatesPEM []byte
+
+	// subscribers is the list of subscribers that will be sent a message when
+	// the root certificates changes.
+	subscribers []chan<- struct{}
+}
+
+// NewClusterTrustBundles constructs a new ClusterTrustBundle implementation of
+// RootCAs. ClusterTrustBundles are selected by signerName and/or the label
+// selector, at least one of which must be given. Matching ClusterTrustBundles
+// are listed straight away, and then watched for changes. Bundles that do not
+// contain valid PEM encoded certificates are ignored, and the last valid root
+// certificates continue to be used if none remain. The logger is extracted
+// from ctx via logr.FromContext.
+func NewClusterTrustBundles(ctx context.Context, k8sClient client.WithWatch, signerName string, selector labels.Selector, sanitize SanitizeOptions) (Interface, error) {
+	if selector == nil {
+		selector = labels.Everything()
+	}
+
+	if len(signerName) == 0 && selector.Empty() {
+		return nil, errors.New("root CAs ClusterTrustBundle signer name or label selector is required")
+	}
+
+	c := &clusterTrustBundles{
+		log: logr.FromContextOrDiscard(ctx).
+			WithName("cluster-trust-bundles").
+			WithValues("signer_name", signerName, "selector", selector.String()),
+		k8sClient:    k8sClient,
+		signerName:   signerName,
+		selector:     selector,
+		sanitize:     sanitize,
+		trustBundles: make(map[string]string),
+	}
+
+	if err := c.list(ctx); err != nil {
+		return nil, err
+	}
+
+	go c.start(ctx)
+
+	return c, nil
+}
+
+// listOptions returns the options selecting the ClusterTrustBundles.
+func (c *clusterTrustBundles) listOptions() *client.ListOptions {
+	opts := &client.ListOptions{LabelSelector: c.selector}
+	if len(c.signerName) > 0 {
+		opts.FieldSelector = fields.OneTermEqualSelector("spec.signerName", c.signerName)
+	}
+	return opts
+}
+
+// list replaces the known ClusterTrustBundles with those currently matching.
+func (c *clusterTrustBundles) list(ctx context.Context) error {
+	var list certificatesv1beta1.ClusterTrustBundleList
+	if err := c.k8sClient.List(ctx, &list, c.listOptions()); err != nil {
+		return fmt.Errorf("failed to list root CAs ClusterTrustBundles: %w", err)
+	}
+
+	trustBundles := make(map[string]string)
+	for _, ctb := range list.Items {
+		if c.matches(&ctb) {
+			trustBundles[ctb.Name] = ctb.Spec.TrustBundle
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.trustBundles = trustBundles
+	c.updateLocked()
+
+	return nil
+}
+
+// start watches the ClusterTrustBundles for changes and updates the root
+// certificates. It retries on failure with a 5s delay, and returns when ctx is
+// cancelled. ClusterTrustBundles are re-listed every time the watcher is
+// recreated so that no change is missed.
+func (c *clusterTrustBundles) start(ctx context.Context) {
+	var relist bool
+
+LOOP:
+	for {
+		c.log.Info("Starting / restarting watcher for root CAs")
+
+		if relist {
+			if err := c.list(ctx); err != nil {
+				c.log.Error(err, "Failed to list root CAs ClusterTrustBundles; will retry in 5s")
+				select {
+				case <-ctx.Done():
+					break LOOP
+				case <-time.After(5 * time.Second):
+				}
+				continue
+			}
+		}
+		relist = true
+
+		watcher, err := c.k8sClient.Watch(ctx, &certificatesv1beta1.ClusterTrustBundleList{}, c.listOptions())
+		if err != nil {
+			c.log.Error(err, "Failed to create root CAs watcher; will retry in 5s")
+			select {
+			case <-ctx.Done():
+				break LOOP
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				c.log.Info("Received context cancellation, shutting down root CAs watcher")
+				watcher.Stop()
+				break LOOP
+
+			case event, open := <-watcher.ResultChan():
+				if !open {
+					c.log.Info("Received closed channel from root CAs watcher, will recreate")
+					watcher.Stop()
+					continue LOOP
+				}
+
+				switch event.Type {
+				case watch.Added, watch.Modified, watch.Deleted:
+					ctb, ok := event.Object.(*certificatesv1beta1.ClusterTrustBundle)
+					if !ok {
+						c.log.Error(errors.New("got unexpected type for ClusterTrustBundle; this is likely a programming error"), "Failed to handle root CAs event")
+						continue
+					}
+					c.handleClusterTrustBundle(event.Type, ctb)
+
+				case watch.Error:
+					c.log.Error(fmt.Errorf("%v", event.Object), "Got an error event when watching root CAs ClusterTrustBundles")
+				}
+			}
+		}
+	}
+
+	c.log.Info("Stopped root CAs watcher")
+}
+
+// matches returns true if the ClusterTrustBundle is selected. The API server
+// already filters the watch, but the selection is checked again in case it is
+// not honoured.
+func (c *clusterTrustBundles) matches(ctb *certificatesv1beta1.ClusterTrustBundle) bool {
+	if len(c.signerName) > 0 && ctb.Spec.SignerName != c.signerName {
+		return false
+	}
+	return c.selector.Matches(labels.Set(ctb.Labels))
+}
+
+// handleClusterTrustBundle updates the known ClusterTrustBundles with the
+// watch event, and the root certificates if they changed.
+func (c *clusterTrustBundles) handleClusterTrustBundle(eventType watch.EventType, ctb *certificatesv1beta1.ClusterTrustBundle) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if eventType == watch.Deleted || !c.matches(ctb) {
+		delete(c.trustBundles, ctb.Name)
+	} else {
+		c.trustBundles[ctb.Name] = ctb.Spec.TrustBundle
+	}
+
+	c.updateLocked()
+}
+
+// updateLocked computes the root certificates from the known
+// ClusterTrustBundles, ordered by name and sanitised, and
+// broadcasts an event to subscribers if they changed. Must be called with the
+// lock held.
+func (c *clusterTrustBundles) updateLocked() {
+	names := make([]string, 0, len(c.trustBundles))
+	for name := range c.trustBundles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var certificatesPEM []byte
+	for _, name := range names {
+		trustBundle, err := Sanitize([]byte(c.trustBundles[name]), c.sanitize)
+		if err != nil {
+			rejectedRootCAs.WithLabelValues("cluster_trust_bundle").Inc()
+			c.log.Error(err, "Ignoring ClusterTrustBundle with invalid certificates", "cluster_trust_bundle", name)
+			continue
+		}
+		certificatesPEM = append(certificatesPEM, trustBundle...)
+	}
+
+	// Sanitise again to remove certificates duplicated across
+	// ClusterTrustBundles.
+	certificatesPEM, err := Sanitize(certificatesPEM, c.sanitize)
+	if err != nil {
+		c.log.Error(err, "Rejecting root CAs from ClusterTrustBundles; continuing to use the last valid root CAs")
+		return
+	}
+
+	// If the certificates haven't changed, no need to update store and
+	// broadcast event.
+	if bytes.Equal(certificatesPEM, c.certificatesPEM) {
+		return
+	}
+
+	c.log.Info("Loaded root CAs from ClusterTrustBundles", "cluster_trust_bundles", names)
+
+	c.certificatesPEM = certificatesPEM
+	for i := range c.subscribers {
+		go func(i int) { c.subscribers[i] <- struct{}{} }(i)
+	}
+}
+
+// CertificatesPEM returns the current root CA certificates of the
+// ClusterTrustBundles.
+func (c *clusterTrustBundles) CertificatesPEM() []byte {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.certificatesPEM
+}
+
+// Subscribe subscribes the consumer to events to when the root CAs of the
+// ClusterTrustBundles change.
+func (c *clusterTrustBundles) Subscribe() <-chan struct{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	sub := make(chan struct{})
+	c.subscribers = append(c.subscribers, sub)
+	return sub
+}
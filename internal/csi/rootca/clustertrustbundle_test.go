@@ -0,0 +1,111 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2/ktesting"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_NewClusterTrustBundles(t *testing.T) {
+	ca1, _ := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+	ca3, _ := testCA(t, "ca-3")
+
+	newCTB := func(name, signerName string, lbls map[string]string, trustBundle []byte) *certificatesv1beta1.ClusterTrustBundle {
+		return &certificatesv1beta1.ClusterTrustBundle{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: lbls},
+			Spec:       certificatesv1beta1.ClusterTrustBundleSpec{SignerName: signerName, TrustBundle: string(trustBundle)},
+		}
+	}
+
+	ctbA := newCTB("example.com:spiffe:a", "example.com/spiffe", map[string]string{"trust": "spiffe"}, testCertificatesPEM(t, ca1))
+	ctbB := newCTB("example.com:spiffe:b", "example.com/spiffe", map[string]string{"trust": "spiffe"}, testCertificatesPEM(t, ca1, ca2))
+	otherSigner := newCTB("example.com:other:a", "example.com/other", map[string]string{"trust": "spiffe"}, testCertificatesPEM(t, ca3))
+	otherLabel := newCTB("example.com:spiffe:c", "example.com/spiffe", nil, testCertificatesPEM(t, ca3))
+
+	k8sClient := fake.NewClientBuilder().
+		WithObjects(ctbA, ctbB, otherSigner, otherLabel).
+		WithIndex(&certificatesv1beta1.ClusterTrustBundle{}, "spec.signerName", func(obj client.Object) []string {
+			return []string{obj.(*certificatesv1beta1.ClusterTrustBundle).Spec.SignerName}
+		}).
+		Build()
+
+	ctx := logr.NewContext(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig))
+	c, err := NewClusterTrustBundles(ctx, k8sClient, "example.com/spiffe", labels.SelectorFromSet(labels.Set{"trust": "spiffe"}), SanitizeOptions{})
+	require.NoError(t, err)
+	sub := c.Subscribe()
+
+	t.Log("should concatenate and deduplicate the matching ClusterTrustBundles straight away")
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2), c.CertificatesPEM())
+
+	t.Log("should fire an event when a matching ClusterTrustBundle changes")
+	ctbA.Spec.TrustBundle = string(testCertificatesPEM(t, ca3))
+
+	// The watcher may not have started before the update, so keep modifying
+	// the ClusterTrustBundle until the watcher has observed it.
+	assert.EventuallyWithT(t, func(c2 *assert.CollectT) {
+		if !assert.Equal(c2, testCertificatesPEM(t, ca3, ca1, ca2), c.CertificatesPEM()) {
+			ctbA.Labels["generation"] = strconv.FormatInt(time.Now().UnixNano(), 10)
+			assert.NoError(c2, k8sClient.Update(t.Context(), ctbA))
+		}
+	}, time.Second*5, time.Millisecond*10)
+
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when the ClusterTrustBundle changed")
+	}
+
+	t.Log("should ignore changes to ClusterTrustBundles which do not match")
+	otherSigner.Spec.TrustBundle = string(testCertificatesPEM(t, ca1))
+	require.NoError(t, k8sClient.Update(t.Context(), otherSigner))
+	otherLabel.Spec.TrustBundle = string(testCertificatesPEM(t, ca1))
+	require.NoError(t, k8sClient.Update(t.Context(), otherLabel))
+
+	select {
+	case <-sub:
+		assert.Fail(t, "expected to not receive an event when a non-matching ClusterTrustBundle changed")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	t.Log("should remove the certificates of deleted ClusterTrustBundles")
+	require.NoError(t, k8sClient.Delete(t.Context(), ctbA))
+
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when the ClusterTrustBundle was deleted")
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2), c.CertificatesPEM())
+}
+
+func Test_NewClusterTrustBundles_validation(t *testing.T) {
+	_, err := NewClusterTrustBundles(t.Context(), fake.NewClientBuilder().Build(), "", labels.Everything(), SanitizeOptions{})
+	assert.Error(t, err, "expected an error when neither a signer name or label selector is given")
+}
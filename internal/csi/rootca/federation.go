@@ -0,0 +1,394 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/go-logr/logr"
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/federation"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"sigs.k8s.io/yaml"
+)
+
+// Profile is a SPIFFE bundle endpoint profile, determining how the endpoint
+// server is authenticated.
+type Profile string
+
+const (
+	// ProfileHTTPSWeb authenticates the endpoint server with Web PKI.
+	ProfileHTTPSWeb Profile = "https_web"
+
+	// ProfileHTTPSSPIFFE authenticates the endpoint server with an X509-SVID,
+	// issued by the federated trust domain itself.
+	ProfileHTTPSSPIFFE Profile = "https_spiffe"
+)
+
+// FederationEndpoint is the SPIFFE bundle endpoint of a federated trust
+// domain.
+type FederationEndpoint struct {
+	// TrustDomain is the name of the federated trust domain.
+	TrustDomain string
+
+	// URL is the HTTPS URL of the bundle endpoint.
+	URL string
+
+	// Profile is the bundle endpoint profile. Defaults to ProfileHTTPSWeb if
+	// empty.
+	Profile Profile
+
+	// EndpointSPIFFEID is the SPIFFE ID of the endpoint server. Required when
+	// Profile is ProfileHTTPSSPIFFE.
+	EndpointSPIFFEID string
+
+	// BundlePEM are PEM encoded X.509 root certificates of the trust domain
+	// used to authenticate the endpoint server until a bundle has been
+	// fetched. Required when Profile is ProfileHTTPSSPIFFE.
+	BundlePEM []byte
+}
+
+// federationConfigEndpoint is a bundle endpoint as it appears in a federation
+// config file.
+type federationConfigEndpoint struct {
+	TrustDomain      string  `json:"trustDomain"`
+	URL              string  `json:"url"`
+	Profile          Profile `json:"profile,omitempty"`
+	EndpointSPIFFEID string  `json:"endpointSPIFFEID,omitempty"`
+	BundleFile       string  `json:"bundleFile,omitempty"`
+}
+
+// ReadFederationConfig reads the bundle endpoints of federated trust domains
+// from a YAML or JSON config file, containing a list of endpoints with the
+// keys trustDomain, url, profile, endpointSPIFFEID and bundleFile. The bundle
+// file of each endpoint is read from disk.
+func ReadFederationConfig(path string) ([]FederationEndpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read federation config file: %w", err)
+	}
+
+	var config []federationConfigEndpoint
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse federation config file %q: %w", path, err)
+	}
+
+	endpoints := make([]FederationEndpoint, 0, len(config))
+	for _, c := range config {
+		endpoint := FederationEndpoint{
+			TrustDomain:      c.TrustDomain,
+			URL:              c.URL,
+			Profile:          c.Profile,
+			EndpointSPIFFEID: c.EndpointSPIFFEID,
+		}
+
+		if len(c.BundleFile) > 0 {
+			endpoint.BundlePEM, err = os.ReadFile(c.BundleFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read bundle file of federated trust domain %q: %w", c.TrustDomain, err)
+			}
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, nil
+}
+
+// FederationOptions are options for the federation source.
+type FederationOptions struct {
+	// Endpoints are the bundle endpoints of each federated trust domain.
+	Endpoints []FederationEndpoint
+
+	// DefaultRefreshPeriod is how often bundles are fetched when the endpoint
+	// gives no refresh hint. Defaults to 5 minutes if zero.
+	DefaultRefreshPeriod time.Duration
+
+	// RetryPeriod is how long to wait before fetching again after a failure.
+	// Defaults to 30 seconds if zero.
+	RetryPeriod time.Duration
+
+	// WebPKIRoots are the root certificates used to authenticate endpoints
+	// using ProfileHTTPSWeb. Defaults to the system roots if nil.
+	WebPKIRoots *x509.CertPool
+
+	// Sanitize are the options used to sanitise the bundles of federated
+	// trust domains.
+	Sanitize SanitizeOptions
+}
+
+// minimumRefreshPeriod is the shortest period bundles are fetched with,
+// regardless of the refresh hint given by the endpoint.
+var minimumRefreshPeriod = time.Second
+
+// bundleEndpoints is an implementation of FederatedInterface that polls the SPIFFE
+// bundle endpoints of federated trust domains.
+type bundleEndpoints struct {
+	// log is the bundleEndpoints logger.
+	log logr.Logger
+
+	// defaultRefreshPeriod, retryPeriod are how long to wait between fetches
+	// when no refresh hint is given, and after a failure.
+	defaultRefreshPeriod, retryPeriod time.Duration
+
+	// webPKIRoots are the roots used to authenticate https_web endpoints.
+	webPKIRoots *x509.CertPool
+
+	// sanitize are the options used to sanitise fetched bundles.
+	sanitize SanitizeOptions
+
+	// certificatesPEM is the current root certificates of each federated trust
+	// domain.
+	certificatesPEM map[string][]byte
+
+	// sequenceNumbers is the sequence number of the last fetched bundle of
+	// each federated trust domain, if it had one.
+	sequenceNumbers map[string]uint64
+
+	// lock is used as a semaphore for accessing the certificatesPEM and
+	// sequenceNumbers data.
+	lock sync.RWMutex
+
+	// subscribers is the list of subscribers that will be sent a message when
+	// the root certificates of any federated trust domain change.
+	subscribers []chan<- struct{}
+}
+
+// NewFederation constructs a new FederatedInterface which polls the given
+// SPIFFE bundle endpoints until the context is cancelled. The bundle of a
+// trust domain is kept if fetching a new one fails, or if the new one has an
+// older sequence number.
+func NewFederation(ctx context.Context, log logr.Logger, opts FederationOptions) (FederatedInterface, error) {
+	f := &bundleEndpoints{
+		log:                  log.WithName("federation"),
+		defaultRefreshPeriod: opts.DefaultRefreshPeriod,
+		retryPeriod:          opts.RetryPeriod,
+		webPKIRoots:          opts.WebPKIRoots,
+		sanitize:             opts.Sanitize,
+		certificatesPEM:      make(map[string][]byte),
+		sequenceNumbers:      make(map[string]uint64),
+	}
+
+	if f.defaultRefreshPeriod == 0 {
+		f.defaultRefreshPeriod = time.Minute * 5
+	}
+
+	if f.retryPeriod == 0 {
+		f.retryPeriod = time.Second * 30
+	}
+
+	type poller struct {
+		endpoint    FederationEndpoint
+		trustDomain spiffeid.TrustDomain
+		endpointID  spiffeid.ID
+	}
+
+	pollers := make([]poller, 0, len(opts.Endpoints))
+	for _, endpoint := range opts.Endpoints {
+		td, err := spiffeid.TrustDomainFromString(endpoint.TrustDomain)
+		if err != nil {
+			return nil, fmt.Errorf("invalid federated trust domain %q: %w", endpoint.TrustDomain, err)
+		}
+
+		if _, ok := f.certificatesPEM[td.Name()]; ok {
+			return nil, fmt.Errorf("federated trust domain %q is configured more than once", td.Name())
+		}
+
+		if !strings.HasPrefix(endpoint.URL, "https://") {
+			return nil, fmt.Errorf("bundle endpoint URL of federated trust domain %q must use https: %q", td.Name(), endpoint.URL)
+		}
+
+		p := poller{endpoint: endpoint, trustDomain: td}
+
+		switch endpoint.Profile {
+		case "", ProfileHTTPSWeb:
+
+		case ProfileHTTPSSPIFFE:
+			p.endpointID, err = spiffeid.FromString(endpoint.EndpointSPIFFEID)
+			if err != nil {
+				return nil, fmt.Errorf("invalid endpoint SPIFFE ID of federated trust domain %q: %w", td.Name(), err)
+			}
+
+			if len(endpoint.BundlePEM) == 0 {
+				return nil, fmt.Errorf("federated trust domain %q uses the %s profile, but no bundle was given", td.Name(), ProfileHTTPSSPIFFE)
+			}
+
+			endpoint.BundlePEM, err = Sanitize(endpoint.BundlePEM, f.sanitize)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bundle of federated trust domain %q: %w", td.Name(), err)
+			}
+
+		default:
+			return nil, fmt.Errorf("unsupported bundle endpoint profile %q of federated trust domain %q, must be %s or %s",
+				endpoint.Profile, td.Name(), ProfileHTTPSWeb, ProfileHTTPSSPIFFE)
+		}
+
+		// The given bundle is trusted until one has been fetched.
+		f.certificatesPEM[td.Name()] = endpoint.BundlePEM
+		pollers = append(pollers, p)
+	}
+
+	for _, p := range pollers {
+		go f.poll(ctx, p.trustDomain, p.endpoint, p.endpointID)
+	}
+
+	return f, nil
+}
+
+// poll fetches the bundle of the trust domain from its endpoint until the
+// context is cancelled, honouring the refresh hint of fetched bundles.
+func (f *bundleEndpoints) poll(ctx context.Context, td spiffeid.TrustDomain, endpoint FederationEndpoint, endpointID spiffeid.ID) {
+	log := f.log.WithValues("trust_domain", td.Name(), "url", endpoint.URL)
+
+	for {
+		wait, err := f.fetch(ctx, td, endpoint, endpointID)
+		if err != nil {
+			log.Error(err, "failed to fetch federated bundle, retrying", "retry_period", f.retryPeriod)
+			wait = f.retryPeriod
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("closing federated bundle poller")
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// fetch fetches the bundle of the trust domain once, and returns how long to
+// wait before fetching it again.
+func (f *bundleEndpoints) fetch(ctx context.Context, td spiffeid.TrustDomain, endpoint FederationEndpoint, endpointID spiffeid.ID) (time.Duration, error) {
+	var option federation.FetchOption
+	if endpoint.Profile == ProfileHTTPSSPIFFE {
+		certs, err := pki.DecodeX509CertificateSetBytes(f.TrustDomainCertificatesPEM()[td.Name()])
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode current bundle: %w", err)
+		}
+		option = federation.WithSPIFFEAuth(x509bundle.FromX509Authorities(td, certs), endpointID)
+	} else {
+		option = federation.WithWebPKIRoots(f.webPKIRoots)
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	bundle, err := federation.FetchBundle(fetchCtx, td, endpoint.URL, option)
+	if err != nil {
+		return 0, err
+	}
+
+	refreshPeriod, ok := bundle.RefreshHint()
+	if !ok {
+		refreshPeriod = f.defaultRefreshPeriod
+	}
+	refreshPeriod = max(refreshPeriod, minimumRefreshPeriod)
+
+	// Bundles older than the current one, for example served by a lagging
+	// replica of the endpoint, are ignored.
+	sequenceNumber, hasSequenceNumber := bundle.SequenceNumber()
+	if hasSequenceNumber {
+		f.lock.RLock()
+		last, ok := f.sequenceNumbers[td.Name()]
+		f.lock.RUnlock()
+		if ok && sequenceNumber < last {
+			f.log.Info("ignoring federated bundle with an older sequence number", "trust_domain", td.Name(),
+				"sequence_number", sequenceNumber, "last_sequence_number", last)
+			return refreshPeriod, nil
+		}
+	}
+
+	authorities := bundle.X509Authorities()
+	if len(authorities) == 0 {
+		return 0, errors.New("fetched bundle contains no X.509 authorities")
+	}
+
+	var certificatesPEM []byte
+	for _, authority := range authorities {
+		certificatesPEM = append(certificatesPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: authority.Raw})...)
+	}
+
+	certificatesPEM, err = Sanitize(certificatesPEM, f.sanitize)
+	if err != nil {
+		rejectedRootCAs.WithLabelValues("federation").Inc()
+		return 0, fmt.Errorf("rejecting fetched bundle: %w", err)
+	}
+
+	if hasSequenceNumber {
+		f.lock.Lock()
+		f.sequenceNumbers[td.Name()] = sequenceNumber
+		f.lock.Unlock()
+	}
+
+	f.setCertificatesPEM(td.Name(), certificatesPEM)
+
+	return refreshPeriod, nil
+}
+
+// setCertificatesPEM updates the root certificates of the trust domain, and
+// broadcasts an event to subscribers if they changed.
+func (f *bundleEndpoints) setCertificatesPEM(trustDomain string, certificatesPEM []byte) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if bytes.Equal(f.certificatesPEM[trustDomain], certificatesPEM) {
+		return
+	}
+
+	f.log.Info("federated bundle changed", "trust_domain", trustDomain)
+
+	f.certificatesPEM[trustDomain] = certificatesPEM
+	for i := range f.subscribers {
+		go func(i int) { f.subscribers[i] <- struct{}{} }(i)
+	}
+}
+
+// TrustDomainCertificatesPEM returns the current root certificates of each
+// federated trust domain which has a bundle.
+func (f *bundleEndpoints) TrustDomainCertificatesPEM() map[string][]byte {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	out := make(map[string][]byte, len(f.certificatesPEM))
+	for trustDomain, certificatesPEM := range f.certificatesPEM {
+		if len(certificatesPEM) > 0 {
+			out[trustDomain] = certificatesPEM
+		}
+	}
+
+	return out
+}
+
+// Subscribe subscribes the consumer to events to when the root certificates
+// of any federated trust domain change.
+func (f *bundleEndpoints) Subscribe() <-chan struct{} {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	sub := make(chan struct{})
+	f.subscribers = append(f.subscribers, sub)
+	return sub
+}
@@ -0,0 +1,343 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+)
+
+// testBundleEndpoint is a stand-in SPIFFE bundle endpoint, serving the bundle
+// of a trust domain with the given X.509 authorities.
+type testBundleEndpoint struct {
+	*httptest.Server
+
+	lock           sync.Mutex
+	td             spiffeid.TrustDomain
+	authorities    []*x509.Certificate
+	refreshHint    time.Duration
+	sequenceNumber uint64
+}
+
+func newTestBundleEndpoint(t *testing.T, td string, refreshHint time.Duration, serverCert *tls.Certificate, authorities ...*x509.Certificate) *testBundleEndpoint {
+	e := &testBundleEndpoint{
+		td:          spiffeid.RequireTrustDomainFromString(td),
+		authorities: authorities,
+		refreshHint: refreshHint,
+	}
+
+	e.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+
+		bundle := spiffebundle.FromX509Authorities(e.td, e.authorities)
+		if e.refreshHint > 0 {
+			bundle.SetRefreshHint(e.refreshHint)
+		}
+		if e.sequenceNumber > 0 {
+			bundle.SetSequenceNumber(e.sequenceNumber)
+		}
+
+		data, err := bundle.Marshal()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write(data)
+	}))
+
+	if serverCert != nil {
+		e.TLS = &tls.Config{Certificates: []tls.Certificate{*serverCert}, MinVersion: tls.VersionTLS12}
+	}
+
+	e.StartTLS()
+	t.Cleanup(e.Close)
+
+	return e
+}
+
+func (e *testBundleEndpoint) setAuthorities(authorities ...*x509.Certificate) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.authorities = authorities
+}
+
+func (e *testBundleEndpoint) setBundle(sequenceNumber uint64, authorities ...*x509.Certificate) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.sequenceNumber = sequenceNumber
+	e.authorities = authorities
+}
+
+func testCA(t *testing.T, commonName string) (*x509.Certificate, crypto.Signer) {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: cmapi.CertificateSpec{CommonName: commonName, IsCA: true}})
+	require.NoError(t, err)
+
+	_, ca, err := utilpki.SignCertificate(tmpl, tmpl, pk.Public(), pk)
+	require.NoError(t, err)
+
+	return ca, pk
+}
+
+func testCertificatesPEM(t *testing.T, certs ...*x509.Certificate) []byte {
+	var out []byte
+	for _, cert := range certs {
+		certPEM, err := utilpki.EncodeX509(cert)
+		require.NoError(t, err)
+		out = append(out, certPEM...)
+	}
+	return out
+}
+
+func Test_NewFederation_httpsWeb(t *testing.T) {
+	ca1, _ := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+
+	endpoint := newTestBundleEndpoint(t, "foo.bar", time.Second, nil, ca1)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(endpoint.Certificate())
+
+	f, err := NewFederation(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), FederationOptions{
+		Endpoints: []FederationEndpoint{
+			{TrustDomain: "foo.bar", URL: endpoint.URL, Profile: ProfileHTTPSWeb},
+		},
+		// Bundles are only refetched because of the refresh hint.
+		DefaultRefreshPeriod: time.Hour,
+		RetryPeriod:          time.Millisecond * 50,
+		WebPKIRoots:          roots,
+	})
+	require.NoError(t, err)
+	sub := f.Subscribe()
+
+	t.Log("should fetch the bundle from the endpoint")
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, map[string][]byte{"foo.bar": testCertificatesPEM(t, ca1)}, f.TrustDomainCertificatesPEM())
+	}, time.Second*5, time.Millisecond*10)
+
+	select {
+	case <-sub:
+	case <-time.After(time.Second):
+		assert.Fail(t, "expected to receive an event when the bundle was fetched")
+	}
+
+	t.Log("should fetch the changed bundle after the refresh hint, and fire an event")
+	endpoint.setAuthorities(ca1, ca2)
+
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when the bundle changed")
+	}
+
+	assert.Equal(t, map[string][]byte{"foo.bar": testCertificatesPEM(t, ca1, ca2)}, f.TrustDomainCertificatesPEM())
+}
+
+func Test_NewFederation_sequenceNumber(t *testing.T) {
+	ca1, _ := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+	ca3, _ := testCA(t, "ca-3")
+
+	endpoint := newTestBundleEndpoint(t, "foo.bar", time.Second, nil)
+	endpoint.setBundle(10, ca1)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(endpoint.Certificate())
+
+	f, err := NewFederation(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), FederationOptions{
+		Endpoints: []FederationEndpoint{
+			{TrustDomain: "foo.bar", URL: endpoint.URL, Profile: ProfileHTTPSWeb},
+		},
+		DefaultRefreshPeriod: time.Hour,
+		RetryPeriod:          time.Millisecond * 50,
+		WebPKIRoots:          roots,
+	})
+	require.NoError(t, err)
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, map[string][]byte{"foo.bar": testCertificatesPEM(t, ca1)}, f.TrustDomainCertificatesPEM())
+	}, time.Second*5, time.Millisecond*10)
+
+	t.Log("should ignore a bundle with an older sequence number")
+	endpoint.setBundle(9, ca2)
+	assert.Never(t, func() bool {
+		return string(f.TrustDomainCertificatesPEM()["foo.bar"]) != string(testCertificatesPEM(t, ca1))
+	}, time.Second*3, time.Millisecond*50)
+
+	t.Log("should fetch a bundle with a newer sequence number")
+	endpoint.setBundle(11, ca3)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, map[string][]byte{"foo.bar": testCertificatesPEM(t, ca3)}, f.TrustDomainCertificatesPEM())
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func Test_NewFederation_httpsSPIFFE(t *testing.T) {
+	ca1, ca1Key := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+
+	// The endpoint server is authenticated with an X509-SVID issued by the
+	// federated trust domain.
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serverTmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{
+		Spec: cmapi.CertificateSpec{URIs: []string{"spiffe://foo.bar/bundle-server"}},
+	})
+	require.NoError(t, err)
+	_, serverCert, err := utilpki.SignCertificate(serverTmpl, ca1, serverKey.Public(), ca1Key)
+	require.NoError(t, err)
+
+	endpoint := newTestBundleEndpoint(t, "foo.bar", 0, &tls.Certificate{
+		Certificate: [][]byte{serverCert.Raw},
+		PrivateKey:  serverKey,
+	}, ca1, ca2)
+
+	t.Log("should not fetch the bundle if the endpoint server has a different SPIFFE ID")
+	f, err := NewFederation(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), FederationOptions{
+		Endpoints: []FederationEndpoint{
+			{TrustDomain: "foo.bar", URL: endpoint.URL, Profile: ProfileHTTPSSPIFFE, EndpointSPIFFEID: "spiffe://foo.bar/other", BundlePEM: testCertificatesPEM(t, ca1)},
+		},
+	})
+	require.NoError(t, err)
+	_, err = f.(*bundleEndpoints).fetch(t.Context(), spiffeid.RequireTrustDomainFromString("foo.bar"),
+		FederationEndpoint{URL: endpoint.URL, Profile: ProfileHTTPSSPIFFE}, spiffeid.RequireFromString("spiffe://foo.bar/other"))
+	assert.Error(t, err)
+	assert.Equal(t, map[string][]byte{"foo.bar": testCertificatesPEM(t, ca1)}, f.TrustDomainCertificatesPEM(),
+		"expected the given bundle to be kept")
+
+	t.Log("should fetch the bundle, authenticating the endpoint server with the given bundle")
+	f, err = NewFederation(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), FederationOptions{
+		Endpoints: []FederationEndpoint{
+			{TrustDomain: "foo.bar", URL: endpoint.URL, Profile: ProfileHTTPSSPIFFE, EndpointSPIFFEID: "spiffe://foo.bar/bundle-server", BundlePEM: testCertificatesPEM(t, ca1)},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, map[string][]byte{"foo.bar": testCertificatesPEM(t, ca1, ca2)}, f.TrustDomainCertificatesPEM())
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func Test_NewFederation_validation(t *testing.T) {
+	ca, _ := testCA(t, "ca")
+
+	tests := map[string]struct {
+		endpoints []FederationEndpoint
+		expErr    bool
+	}{
+		"https_web endpoint should be valid": {
+			endpoints: []FederationEndpoint{{TrustDomain: "foo.bar", URL: "https://foo.bar/bundle"}},
+		},
+		"https_spiffe endpoint should be valid": {
+			endpoints: []FederationEndpoint{{TrustDomain: "foo.bar", URL: "https://foo.bar/bundle", Profile: ProfileHTTPSSPIFFE,
+				EndpointSPIFFEID: "spiffe://foo.bar/bundle-server", BundlePEM: testCertificatesPEM(t, ca)}},
+		},
+		"invalid trust domain should error": {
+			endpoints: []FederationEndpoint{{TrustDomain: "Foo Bar", URL: "https://foo.bar/bundle"}},
+			expErr:    true,
+		},
+		"duplicate trust domain should error": {
+			endpoints: []FederationEndpoint{
+				{TrustDomain: "foo.bar", URL: "https://foo.bar/bundle"},
+				{TrustDomain: "foo.bar", URL: "https://bar.foo/bundle"},
+			},
+			expErr: true,
+		},
+		"http URL should error": {
+			endpoints: []FederationEndpoint{{TrustDomain: "foo.bar", URL: "http://foo.bar/bundle"}},
+			expErr:    true,
+		},
+		"unknown profile should error": {
+			endpoints: []FederationEndpoint{{TrustDomain: "foo.bar", URL: "https://foo.bar/bundle", Profile: "https_other"}},
+			expErr:    true,
+		},
+		"https_spiffe endpoint without a bundle should error": {
+			endpoints: []FederationEndpoint{{TrustDomain: "foo.bar", URL: "https://foo.bar/bundle", Profile: ProfileHTTPSSPIFFE,
+				EndpointSPIFFEID: "spiffe://foo.bar/bundle-server"}},
+			expErr: true,
+		},
+		"https_spiffe endpoint with an invalid SPIFFE ID should error": {
+			endpoints: []FederationEndpoint{{TrustDomain: "foo.bar", URL: "https://foo.bar/bundle", Profile: ProfileHTTPSSPIFFE,
+				EndpointSPIFFEID: "https://foo.bar/bundle-server", BundlePEM: testCertificatesPEM(t, ca)}},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewFederation(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), FederationOptions{
+				Endpoints:   test.endpoints,
+				RetryPeriod: time.Hour,
+			})
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+		})
+	}
+}
+
+func Test_ReadFederationConfig(t *testing.T) {
+	ca, _ := testCA(t, "ca")
+
+	dir := t.TempDir()
+	bundleFile := filepath.Join(dir, "bundle.pem")
+	require.NoError(t, os.WriteFile(bundleFile, testCertificatesPEM(t, ca), 0600))
+
+	configFile := filepath.Join(dir, "federation.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+- trustDomain: foo.bar
+  url: https://foo.bar/bundle
+- trustDomain: bar.foo
+  url: https://bar.foo/bundle
+  profile: https_spiffe
+  endpointSPIFFEID: spiffe://bar.foo/bundle-server
+  bundleFile: `+bundleFile+`
+`), 0600))
+
+	endpoints, err := ReadFederationConfig(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, []FederationEndpoint{
+		{TrustDomain: "foo.bar", URL: "https://foo.bar/bundle"},
+		{TrustDomain: "bar.foo", URL: "https://bar.foo/bundle", Profile: ProfileHTTPSSPIFFE,
+			EndpointSPIFFEID: "spiffe://bar.foo/bundle-server", BundlePEM: testCertificatesPEM(t, ca)},
+	}, endpoints)
+
+	t.Log("should error on unknown keys")
+	require.NoError(t, os.WriteFile(configFile, []byte(`[{"trustDomain": "foo.bar", "endpoint": "https://foo.bar/bundle"}]`), 0600))
+	_, err = ReadFederationConfig(configFile)
+	assert.Error(t, err)
+}
@@ -21,21 +21,36 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/go-logr/logr"
 )
 
+// fileDebouncePeriod is how long to wait for a burst of file events to settle
+// before reloading the root certificates.
+var fileDebouncePeriod = time.Millisecond * 100
+
 // file is an implementation of RootCAs which watches reads the root
 // certificates from file, and broadcasts a message when that file has changed.
 type file struct {
 	// log is the RootCAs file logger.
 	log logr.Logger
 
-	// filepath is the file path location to where the root certificates are
-	// stored, and will be watched for changes.
-	filepath string
+	// path is the file path location to where the root certificates are
+	// stored, and will be watched for changes. If path is a directory, the
+	// root certificates are read from all "*.pem" files in it.
+	path string
+
+	// isDir is true if path is a directory.
+	isDir bool
+
+	// sanitize are the options used to sanitise the root certificates read
+	// from file.
+	sanitize SanitizeOptions
 
 	// certificatesPEM is the current root certificates.
 	certificatesPEM []byte
@@ -49,14 +64,43 @@ type file struct {
 }
 
 // NewFile constructs a new file implementation of RootCAs. NewFile reads and
-// sets up a watcher for the root CAs on file.
-func NewFile(ctx context.Context, log logr.Logger, filepath string) (Interface, error) {
-	log = log.WithName("file").WithValues("filepath", filepath)
+// sets up a watcher for the root CAs on file. If path is a directory, all
+// "*.pem" files in it are read as one bundle. The root CAs are sanitised
+// before use; if the file is later rewritten with no valid certificates, the
+// last valid root CAs continue to be used. If the file has no valid
+// certificates to begin with, such as when it is populated after start up, no
+// root CAs are used until it is updated with valid certificates.
+//
+// The parent directory of a file, or the directory itself, is watched rather
+// than the file, so that atomic symlink swaps made by Kubernetes projected
+// ConfigMap and Secret volumes are followed. Bursts of events are debounced.
+func NewFile(ctx context.Context, log logr.Logger, path string, sanitize SanitizeOptions) (Interface, error) {
+	log = log.WithName("file").WithValues("filepath", path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root CAs file %q: %s", path, err)
+	}
+
+	f := &file{
+		log:      log,
+		path:     path,
+		isDir:    info.IsDir(),
+		sanitize: sanitize,
+	}
 
 	// Read initial certificates from file.
-	certificatesPEM, err := os.ReadFile(filepath)
+	certificatesPEM, err := f.readCertificatesPEM()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read root CAs file %q: %s", filepath, err)
+		return nil, fmt.Errorf("failed to read root CAs file %q: %s", path, err)
+	}
+
+	certificatesPEM, err = Sanitize(certificatesPEM, sanitize)
+	if err != nil {
+		rejectedRootCAs.WithLabelValues("file").Inc()
+		log.Error(err, "rejecting root CAs file, waiting for it to be updated with valid root CAs")
+	} else {
+		f.certificatesPEM = certificatesPEM
 	}
 
 	watcher, err := fsnotify.NewWatcher()
@@ -64,14 +108,14 @@ func NewFile(ctx context.Context, log logr.Logger, filepath string) (Interface,
 		return nil, fmt.Errorf("failed to create file watch: %w", err)
 	}
 
-	if err := watcher.Add(filepath); err != nil {
-		return nil, fmt.Errorf("failed to add root CAs file for watching %q: %w", filepath, err)
+	watchDir := path
+	if !f.isDir {
+		watchDir = filepath.Dir(path)
 	}
 
-	f := &file{
-		log:             log,
-		filepath:        filepath,
-		certificatesPEM: certificatesPEM,
+	if err := watcher.Add(watchDir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to add root CAs directory for watching %q: %w", watchDir, err)
 	}
 
 	// Start the file watcher.
@@ -83,6 +127,12 @@ func NewFile(ctx context.Context, log logr.Logger, filepath string) (Interface,
 func (f *file) start(ctx context.Context, watcher *fsnotify.Watcher) {
 	defer watcher.Close()
 
+	// debounce fires once events have settled. It is stopped until the first
+	// event is received.
+	debounce := time.NewTimer(time.Hour)
+	debounce.Stop()
+	defer debounce.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -90,27 +140,15 @@ func (f *file) start(ctx context.Context, watcher *fsnotify.Watcher) {
 			return
 
 		case event := <-watcher.Events:
-			f.log.V(3).Info("received event from file watcher", "event", event.Op.String())
-
-			// Watch for remove events, since this is actually the syslink being
-			// changed in the volume mount.
-			if event.Op == fsnotify.Remove {
-				if err := watcher.Remove(event.Name); err != nil {
-					f.log.Error(err, "failed to remove file watch")
-				}
-				if err := watcher.Add(f.filepath); err != nil {
-					f.log.Error(err, "failed to add new file watch")
-				}
-
-				f.reloadCertificatesPEM()
-				continue
-			}
-
-			// Also allow normal files to be modified and reloaded.
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				f.reloadCertificatesPEM()
-				continue
-			}
+			f.log.V(3).Info("received event from file watcher", "event", event.Op.String(), "name", event.Name)
+
+			// Any event in the directory may change the root certificates,
+			// including the "..data" symlink of a projected volume being
+			// swapped, so reload once the events have settled.
+			debounce.Reset(fileDebouncePeriod)
+
+		case <-debounce.C:
+			f.reloadCertificatesPEM()
 
 		case err := <-watcher.Errors:
 			f.log.Error(err, "error watching root CAs file")
@@ -118,16 +156,51 @@ func (f *file) start(ctx context.Context, watcher *fsnotify.Watcher) {
 	}
 }
 
+// readCertificatesPEM reads the root certificates from the file, following
+// symlinks, or from all "*.pem" files in the directory in name order.
+func (f *file) readCertificatesPEM() ([]byte, error) {
+	if !f.isDir {
+		return os.ReadFile(f.path)
+	}
+
+	files, err := filepath.Glob(filepath.Join(f.path, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(files)
+
+	var certificatesPEM []byte
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		certificatesPEM = append(certificatesPEM, data...)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			certificatesPEM = append(certificatesPEM, '\n')
+		}
+	}
+
+	return certificatesPEM, nil
+}
+
 func (f *file) reloadCertificatesPEM() {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	certificatesPEM, err := os.ReadFile(f.filepath)
+	certificatesPEM, err := f.readCertificatesPEM()
 	if err != nil {
 		f.log.Error(err, "failed to read root CAs file")
 		return
 	}
 
+	certificatesPEM, err = Sanitize(certificatesPEM, f.sanitize)
+	if err != nil {
+		rejectedRootCAs.WithLabelValues("file").Inc()
+		f.log.Error(err, "rejecting root CAs file, continuing to use the last valid root CAs")
+		return
+	}
+
 	// If the file contents hasn't changed, no need to update store and broadcast
 	// event.
 	if bytes.Equal(certificatesPEM, f.certificatesPEM) {
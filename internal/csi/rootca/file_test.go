@@ -22,43 +22,156 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"k8s.io/klog/v2/ktesting"
 )
 
 func Test_NewFile(t *testing.T) {
+	ca1, _ := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+
 	filepath := filepath.Join(t.TempDir(), "test-file.pem")
 
 	t.Log("if no file exists, expect NewFile to error")
-	_, err := NewFile(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), filepath)
+	_, err := NewFile(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), filepath, SanitizeOptions{})
 	assert.Error(t, err, "expect file to not exist")
 
-	t.Log("should return the contents of the file with CertificatesPEM()")
+	t.Log("if the file contains no certificates, expect NewFile to return no certificates and count the rejection")
+	rejected := testutil.ToFloat64(rejectedRootCAs.WithLabelValues("file"))
 	assert.NoError(t, os.WriteFile(filepath, []byte("test data"), 0600))
 
-	f, err := NewFile(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), filepath)
+	f, err := NewFile(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), filepath, SanitizeOptions{})
 	assert.NoError(t, err)
 
-	assert.Equal(t, []byte("test data"), f.CertificatesPEM())
+	assert.Empty(t, f.CertificatesPEM())
+	assert.Greater(t, testutil.ToFloat64(rejectedRootCAs.WithLabelValues("file")), rejected, "expected the rejection to be counted")
 
-	t.Log("should not fire an event when the file doesn't change")
+	t.Log("should fire an event, and return the contents of the file with CertificatesPEM(), once it contains certificates")
 	sub := f.Subscribe()
-	assert.NoError(t, os.WriteFile(filepath, []byte("test data"), 0600))
+	assert.NoError(t, os.WriteFile(filepath, testCertificatesPEM(t, ca1), 0600))
+
+	select {
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when the target file has changed")
+	case <-sub:
+	}
+
+	assert.Equal(t, testCertificatesPEM(t, ca1), f.CertificatesPEM())
+
+	t.Log("should not fire an event when the file doesn't change")
+	assert.NoError(t, os.WriteFile(filepath, testCertificatesPEM(t, ca1), 0600))
 	select {
-	case <-time.After(time.Millisecond * 50):
+	case <-time.After(fileDebouncePeriod * 3):
 	case <-sub:
 		assert.Fail(t, "expected to not receive an event when the target file hasn't changed")
 	}
 
 	t.Log("should fire an event when the file changes")
-	assert.NoError(t, os.WriteFile(filepath, []byte("new test data"), 0600))
+	assert.NoError(t, os.WriteFile(filepath, testCertificatesPEM(t, ca1, ca2), 0600))
 
 	select {
-	case <-time.After(time.Millisecond * 50):
+	case <-time.After(time.Second * 5):
 		assert.Fail(t, "expected to receive an event when the target file has changed")
 	case <-sub:
 	}
 
-	t.Log("should return new test data now it has been written to file")
-	assert.Equal(t, []byte("new test data"), f.CertificatesPEM())
+	t.Log("should return new certificates now they have been written to file")
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2), f.CertificatesPEM())
+
+	t.Log("should not fire an event, and keep the last valid certificates, when the file is invalid")
+	rejected = testutil.ToFloat64(rejectedRootCAs.WithLabelValues("file"))
+	assert.NoError(t, os.WriteFile(filepath, []byte("garbage"), 0600))
+	select {
+	case <-time.After(fileDebouncePeriod * 3):
+	case <-sub:
+		assert.Fail(t, "expected to not receive an event when the target file is invalid")
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2), f.CertificatesPEM())
+	assert.Greater(t, testutil.ToFloat64(rejectedRootCAs.WithLabelValues("file")), rejected, "expected the rejection to be counted")
+}
+
+func Test_NewFile_symlinkSwap(t *testing.T) {
+	ca1, _ := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+
+	// Mimic the layout of a Kubernetes projected volume, where the file is a
+	// symlink through the "..data" symlink to a timestamped directory.
+	dir := t.TempDir()
+	writeData := func(name string, certificatesPEM []byte) {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, name), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name, "ca.crt"), certificatesPEM, 0600))
+		require.NoError(t, os.Symlink(name, filepath.Join(dir, "..data_tmp")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	}
+
+	writeData("..2026_01_01", testCertificatesPEM(t, ca1))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "ca.crt"), filepath.Join(dir, "ca.crt")))
+
+	f, err := NewFile(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), filepath.Join(dir, "ca.crt"), SanitizeOptions{})
+	require.NoError(t, err)
+	sub := f.Subscribe()
+	assert.Equal(t, testCertificatesPEM(t, ca1), f.CertificatesPEM())
+
+	t.Log("should fire an event when the ..data symlink is swapped")
+	writeData("..2026_01_02", testCertificatesPEM(t, ca1, ca2))
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when the ..data symlink was swapped")
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2), f.CertificatesPEM())
+
+	t.Log("should fire a single event for a burst of changes")
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "..2026_01_01")))
+	writeData("..2026_01_03", testCertificatesPEM(t, ca2))
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "..2026_01_02")))
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when the ..data symlink was swapped")
+	}
+	select {
+	case <-sub:
+		assert.Fail(t, "expected to receive a single event for a burst of changes")
+	case <-time.After(fileDebouncePeriod * 3):
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca2), f.CertificatesPEM())
+}
+
+func Test_NewFile_directory(t *testing.T) {
+	ca1, _ := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+	ca3, _ := testCA(t, "ca-3")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.pem"), testCertificatesPEM(t, ca2), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.pem"), testCertificatesPEM(t, ca1), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.txt"), testCertificatesPEM(t, ca3), 0600))
+
+	f, err := NewFile(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), dir, SanitizeOptions{})
+	require.NoError(t, err)
+	sub := f.Subscribe()
+
+	t.Log("should read all *.pem files in the directory in name order")
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2), f.CertificatesPEM())
+
+	t.Log("should fire an event when a *.pem file is added")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.pem"), testCertificatesPEM(t, ca3), 0600))
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when a file was added")
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2, ca3), f.CertificatesPEM())
+
+	t.Log("should fire an event when a *.pem file is removed")
+	require.NoError(t, os.Remove(filepath.Join(dir, "a.pem")))
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when a file was removed")
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca2, ca3), f.CertificatesPEM())
 }
@@ -0,0 +1,159 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-logr/logr"
+)
+
+// merge is an implementation of RootCAs which combines the root certificates
+// of multiple sources, and broadcasts a message when the combined root
+// certificates change.
+type merge struct {
+	// log is the RootCAs merge logger.
+	log logr.Logger
+
+	// sources are the combined sources, in order.
+	sources []Interface
+
+	// sanitize are the options used to sanitise the combined root
+	// certificates.
+	sanitize SanitizeOptions
+
+	// certificatesPEM is the current combined root certificates.
+	certificatesPEM []byte
+
+	// lock is used as a semaphore for accessing the certificatesPEM data.
+	lock sync.RWMutex
+
+	// subscribers is the list of subscribers that will be sent a message when
+	// the root certificates changes.
+	subscribers []chan<- struct{}
+}
+
+// NewMerge constructs a new merge implementation of RootCAs, combining the
+// root certificates of the given sources. The root certificates of each
+// source are concatenated in the order the sources are given, and duplicate
+// certificates are dropped, so the result is deterministic. A single event is
+// broadcast for any upstream change which changes the combined root
+// certificates.
+func NewMerge(ctx context.Context, log logr.Logger, sources []Interface, sanitize SanitizeOptions) (Interface, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("at least one root CAs source is required to merge")
+	}
+
+	m := &merge{
+		log:      log.WithName("merge"),
+		sources:  sources,
+		sanitize: sanitize,
+	}
+
+	// Subscribe before reading the initial certificates so that no change is
+	// missed. Events from all sources are coalesced into changed, so that
+	// simultaneous upstream changes are handled once.
+	changed := make(chan struct{}, 1)
+	for _, source := range sources {
+		go func(sub <-chan struct{}) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-sub:
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}(source.Subscribe())
+	}
+
+	m.update()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				m.log.Info("closing root CAs merge")
+				return
+			case <-changed:
+				m.update()
+			}
+		}
+	}()
+
+	return m, nil
+}
+
+// update combines the current root certificates of all sources, and
+// broadcasts an event to subscribers if they changed. Sources with no root
+// certificates are skipped, and the last valid root certificates continue to
+// be used if none remain.
+func (m *merge) update() {
+	var combined []byte
+	for _, source := range m.sources {
+		combined = append(combined, source.CertificatesPEM()...)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if len(combined) == 0 {
+		m.log.V(2).Info("no root CAs available from any source yet")
+		return
+	}
+
+	certificatesPEM, err := Sanitize(combined, m.sanitize)
+	if err != nil {
+		rejectedRootCAs.WithLabelValues("merge").Inc()
+		m.log.Error(err, "rejecting merged root CAs, continuing to use the last valid root CAs")
+		return
+	}
+
+	// If the certificates haven't changed, no need to update store and
+	// broadcast event.
+	if bytes.Equal(certificatesPEM, m.certificatesPEM) {
+		return
+	}
+
+	m.certificatesPEM = certificatesPEM
+	for i := range m.subscribers {
+		go func(i int) { m.subscribers[i] <- struct{}{} }(i)
+	}
+}
+
+// CertificatesPEM returns the current combined root CA certificates.
+func (m *merge) CertificatesPEM() []byte {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.certificatesPEM
+}
+
+// Subscribe subscribes the consumer to events to when the combined root CAs
+// change.
+func (m *merge) Subscribe() <-chan struct{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	sub := make(chan struct{})
+	m.subscribers = append(m.subscribers, sub)
+	return sub
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func Test_NewMerge(t *testing.T) {
+	ca1, _ := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+	ca3, _ := testCA(t, "ca-3")
+
+	currentChan, nextChan, partnerChan := make(chan []byte), make(chan []byte), make(chan []byte)
+	current := NewMemory(t.Context(), currentChan)
+	next := NewMemory(t.Context(), nextChan)
+	partner := NewMemory(t.Context(), partnerChan)
+
+	currentChan <- testCertificatesPEM(t, ca1)
+	assert.Eventually(t, func() bool { return len(current.CertificatesPEM()) > 0 }, time.Second, time.Millisecond*10)
+
+	m, err := NewMerge(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), []Interface{current, next, partner}, SanitizeOptions{})
+	require.NoError(t, err)
+	sub := m.Subscribe()
+
+	t.Log("should combine the root CAs of sources which have them straight away")
+	assert.Equal(t, testCertificatesPEM(t, ca1), m.CertificatesPEM())
+
+	t.Log("should fire an event, and combine in source order, when a source changes")
+	partnerChan <- testCertificatesPEM(t, ca3)
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when a source changed")
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca3), m.CertificatesPEM())
+
+	nextChan <- testCertificatesPEM(t, ca2, ca1)
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when a source changed")
+	}
+
+	t.Log("should drop duplicate certificates across sources")
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2, ca3), m.CertificatesPEM())
+
+	t.Log("should not fire an event when a source change does not change the combined root CAs")
+	partnerChan <- testCertificatesPEM(t, ca3, ca1)
+	select {
+	case <-sub:
+		assert.Fail(t, "expected to not receive an event when the combined root CAs are unchanged")
+	case <-time.After(time.Millisecond * 100):
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2, ca3), m.CertificatesPEM())
+}
+
+func Test_NewMerge_noSources(t *testing.T) {
+	_, err := NewMerge(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), nil, SanitizeOptions{})
+	assert.Error(t, err)
+}
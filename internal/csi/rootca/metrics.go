@@ -0,0 +1,37 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// rejectedRootCAs is the number of times root CAs were rejected by
+	// Sanitize, and the last valid root CAs continued to be used.
+	rejectedRootCAs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "csi_driver_spiffe",
+		Subsystem: "root_cas",
+		Name:      "rejected_total",
+		Help:      "Number of times root CAs were rejected as invalid, by source.",
+	}, []string{"source"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(rejectedRootCAs)
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ObjectKind is the kind of Kubernetes object root certificates are read
+// from.
+type ObjectKind string
+
+const (
+	// ObjectKindConfigMap reads root certificates from a ConfigMap key, in
+	// either its data or binary data.
+	ObjectKindConfigMap ObjectKind = "ConfigMap"
+
+	// ObjectKindSecret reads root certificates from a Secret key.
+	ObjectKindSecret ObjectKind = "Secret"
+)
+
+// object is an implementation of RootCAs which watches a ConfigMap or Secret
+// key through the API for the root certificates, and broadcasts a message
+// when they change.
+type object struct {
+	// log is the RootCAs object logger.
+	log logr.Logger
+
+	// k8sClient is used to watch the object.
+	k8sClient client.WithWatch
+
+	// kind, name, key identify the object, and the key in its data, containing
+	// the root certificates.
+	kind ObjectKind
+	name types.NamespacedName
+	key  string
+
+	// sanitize are the options used to sanitise the root certificates read
+	// from the object.
+	sanitize SanitizeOptions
+
+	// certificatesPEM is the last valid root certificates.
+	certificatesPEM []byte
+
+	// lock is used as a semaphore for accessing the certificatesPEM data.
+	lock sync.RWMutex
+
+	// subscribers is the list of subscribers that will be sent a message when
+	// the root certificates changes.
+	subscribers []chan<- struct{}
+}
+
+// NewObject constructs a new object implementation of RootCAs, reading the
+// root certificates from the key of the given ConfigMap or Secret. The object
+// is read straight away, and then watched for changes. If the object is
+// deleted, or its key does not contain valid PEM encoded certificates, the
+// last valid root certificates continue to be used. The logger is extracted
+// from ctx via logr.FromContext.
+func NewObject(ctx context.Context, k8sClient client.WithWatch, kind ObjectKind, name types.NamespacedName, key string, sanitize SanitizeOptions) (Interface, error) {
+	if kind != ObjectKindConfigMap && kind != ObjectKindSecret {
+		return nil, fmt.Errorf("unsupported root CAs object kind %q, must be %s or %s", kind, ObjectKindConfigMap, ObjectKindSecret)
+	}
+
+	if len(name.Name) == 0 || len(name.Namespace) == 0 || len(key) == 0 {
+		return nil, fmt.Errorf("root CAs %s name, namespace and key are required", kind)
+	}
+
+	o := &object{
+		log: logr.FromContextOrDiscard(ctx).
+			WithName("object").
+			WithValues("kind", kind, "name", name.Name, "namespace", name.Namespace, "key", key),
+		k8sClient: k8sClient,
+		kind:      kind,
+		name:      name,
+		key:       key,
+		sanitize:  sanitize,
+	}
+
+	// Read the initial certificates. The object may not exist yet, in which
+	// case it is loaded once the watcher observes it.
+	obj := o.newObject()
+	if err := k8sClient.Get(ctx, name, obj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get root CAs %s %s: %w", kind, name, err)
+		}
+		o.log.Info("root CAs object does not exist yet, waiting for it to be created")
+	} else if err := o.handleObject(obj); err != nil {
+		o.log.Error(err, "failed to load root CAs from object")
+	}
+
+	go o.start(ctx)
+
+	return o, nil
+}
+
+// newObject returns an empty object of the watched kind.
+func (o *object) newObject() client.Object {
+	if o.kind == ObjectKindSecret {
+		return &corev1.Secret{}
+	}
+	return &corev1.ConfigMap{}
+}
+
+// newObjectList returns an empty list of the watched kind.
+func (o *object) newObjectList() client.ObjectList {
+	if o.kind == ObjectKindSecret {
+		return &corev1.SecretList{}
+	}
+	return &corev1.ConfigMapList{}
+}
+
+// start watches the object for changes and updates the root certificates. It
+// retries on failure with a 5s delay, and returns when ctx is cancelled.
+func (o *object) start(ctx context.Context) {
+LOOP:
+	for {
+		o.log.Info("Starting / restarting watcher for root CAs")
+
+		watcher, err := o.k8sClient.Watch(ctx, o.newObjectList(), &client.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", o.name.Name),
+			Namespace:     o.name.Namespace,
+		})
+		if err != nil {
+			o.log.Error(err, "Failed to create root CAs watcher; will retry in 5s")
+			select {
+			case <-ctx.Done():
+				break LOOP
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				o.log.Info("Received context cancellation, shutting down root CAs watcher")
+				watcher.Stop()
+				break LOOP
+
+			case event, open := <-watcher.ResultChan():
+				if !open {
+					o.log.Info("Received closed channel from root CAs watcher, will recreate")
+					watcher.Stop()
+					continue LOOP
+				}
+
+				switch event.Type {
+				case watch.Added, watch.Modified:
+					if err := o.handleObject(event.Object); err != nil {
+						o.log.Error(err, "Failed to load root CAs from object; continuing to use the last valid root CAs")
+					}
+
+				case watch.Deleted:
+					o.log.Info("Root CAs object was deleted; continuing to use the last valid root CAs")
+
+				case watch.Error:
+					o.log.Error(fmt.Errorf("%v", event.Object), "Got an error event when watching root CAs object")
+				}
+			}
+		}
+	}
+
+	o.log.Info("Stopped root CAs watcher")
+}
+
+// handleObject sets the root certificates from the object's key, if they are
+// valid, and broadcasts an event to subscribers if they changed.
+func (o *object) handleObject(obj runtime.Object) error {
+	var (
+		certificatesPEM []byte
+		ok              bool
+	)
+
+	switch obj := obj.(type) {
+	case *corev1.ConfigMap:
+		var data string
+		if data, ok = obj.Data[o.key]; ok {
+			certificatesPEM = []byte(data)
+		} else {
+			certificatesPEM, ok = obj.BinaryData[o.key]
+		}
+
+	case *corev1.Secret:
+		certificatesPEM, ok = obj.Data[o.key]
+
+	default:
+		return errors.New("got unexpected type for root CAs object; this is likely a programming error")
+	}
+
+	if !ok {
+		return fmt.Errorf("missing key in %s data: %s", o.kind, o.key)
+	}
+
+	certificatesPEM, err := Sanitize(certificatesPEM, o.sanitize)
+	if err != nil {
+		rejectedRootCAs.WithLabelValues("object").Inc()
+		return fmt.Errorf("invalid root CAs in %s key %s: %w", o.kind, o.key, err)
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	// If the certificates haven't changed, no need to update store and
+	// broadcast event.
+	if bytes.Equal(certificatesPEM, o.certificatesPEM) {
+		return nil
+	}
+
+	o.log.Info("Loaded root CAs from object")
+
+	o.certificatesPEM = certificatesPEM
+	for i := range o.subscribers {
+		go func(i int) { o.subscribers[i] <- struct{}{} }(i)
+	}
+
+	return nil
+}
+
+// CertificatesPEM returns the last valid root CA certificates of the object.
+func (o *object) CertificatesPEM() []byte {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return o.certificatesPEM
+}
+
+// Subscribe subscribes the consumer to events to when the root CAs of the
+// object change.
+func (o *object) Subscribe() <-chan struct{} {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	sub := make(chan struct{})
+	o.subscribers = append(o.subscribers, sub)
+	return sub
+}
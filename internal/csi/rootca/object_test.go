@@ -0,0 +1,157 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/ktesting"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_NewObject_configMap(t *testing.T) {
+	ca1, _ := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "trust-bundle", Namespace: "cert-manager"},
+		Data:       map[string]string{"ca.crt": string(testCertificatesPEM(t, ca1))},
+	}
+	k8sClient := fake.NewClientBuilder().WithObjects(configMap).Build()
+
+	ctx := logr.NewContext(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig))
+	o, err := NewObject(ctx, k8sClient, ObjectKindConfigMap,
+		types.NamespacedName{Name: "trust-bundle", Namespace: "cert-manager"}, "ca.crt", SanitizeOptions{})
+	require.NoError(t, err)
+	sub := o.Subscribe()
+
+	t.Log("should read the root CAs of the existing ConfigMap straight away")
+	assert.Equal(t, testCertificatesPEM(t, ca1), o.CertificatesPEM())
+
+	t.Log("should fire an event when the ConfigMap changes")
+	configMap.Data["ca.crt"] = string(testCertificatesPEM(t, ca1, ca2))
+
+	// The watcher may not have started before the update, so keep modifying
+	// the ConfigMap until the watcher has observed it.
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		if !assert.Equal(c, testCertificatesPEM(t, ca1, ca2), o.CertificatesPEM()) {
+			configMap.Labels = map[string]string{"generation": strconv.FormatInt(time.Now().UnixNano(), 10)}
+			assert.NoError(c, k8sClient.Update(t.Context(), configMap))
+		}
+	}, time.Second*5, time.Millisecond*10)
+
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when the ConfigMap changed")
+	}
+
+	t.Log("should keep the last valid root CAs when the ConfigMap is invalid")
+	configMap.Data["ca.crt"] = "not a certificate"
+	require.NoError(t, k8sClient.Update(t.Context(), configMap))
+
+	select {
+	case <-sub:
+		assert.Fail(t, "expected to not receive an event when the ConfigMap is invalid")
+	case <-time.After(time.Millisecond * 100):
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2), o.CertificatesPEM())
+
+	t.Log("should keep the last valid root CAs when the ConfigMap is deleted")
+	require.NoError(t, k8sClient.Delete(t.Context(), configMap))
+
+	select {
+	case <-sub:
+		assert.Fail(t, "expected to not receive an event when the ConfigMap is deleted")
+	case <-time.After(time.Millisecond * 100):
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2), o.CertificatesPEM())
+}
+
+func Test_NewObject_secret(t *testing.T) {
+	ca, _ := testCA(t, "ca")
+
+	k8sClient := fake.NewClientBuilder().Build()
+
+	ctx := logr.NewContext(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig))
+	o, err := NewObject(ctx, k8sClient, ObjectKindSecret,
+		types.NamespacedName{Name: "trust-bundle", Namespace: "cert-manager"}, "ca.crt", SanitizeOptions{})
+	require.NoError(t, err)
+
+	t.Log("should have no root CAs until the Secret is created")
+	assert.Empty(t, o.CertificatesPEM())
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "trust-bundle", Namespace: "cert-manager"},
+		Data:       map[string][]byte{"ca.crt": testCertificatesPEM(t, ca)},
+	}
+	require.NoError(t, k8sClient.Create(t.Context(), secret))
+
+	// The fake client does not replay existing objects to new watchers, so keep
+	// modifying the Secret until the watcher has observed it.
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		if !assert.Equal(c, testCertificatesPEM(t, ca), o.CertificatesPEM()) {
+			secret.Labels = map[string]string{"generation": strconv.FormatInt(time.Now().UnixNano(), 10)}
+			assert.NoError(c, k8sClient.Update(t.Context(), secret))
+		}
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func Test_NewObject_validation(t *testing.T) {
+	name := types.NamespacedName{Name: "trust-bundle", Namespace: "cert-manager"}
+
+	tests := map[string]struct {
+		kind   ObjectKind
+		name   types.NamespacedName
+		key    string
+		expErr bool
+	}{
+		"ConfigMap should be valid": {
+			kind: ObjectKindConfigMap, name: name, key: "ca.crt",
+		},
+		"Secret should be valid": {
+			kind: ObjectKindSecret, name: name, key: "ca.crt",
+		},
+		"unknown kind should error": {
+			kind: "Bundle", name: name, key: "ca.crt",
+			expErr: true,
+		},
+		"missing namespace should error": {
+			kind: ObjectKindConfigMap, name: types.NamespacedName{Name: "trust-bundle"}, key: "ca.crt",
+			expErr: true,
+		},
+		"missing key should error": {
+			kind: ObjectKindConfigMap, name: name,
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewObject(t.Context(), fake.NewClientBuilder().Build(), test.kind, test.name, test.key, SanitizeOptions{})
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+		})
+	}
+}
@@ -0,0 +1,337 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/go-logr/logr"
+)
+
+// RetainOptions are options for retaining root certificates which have been
+// removed from a source.
+type RetainOptions struct {
+	// Period is how long a root certificate is retained after it has been
+	// removed from the source. Retained certificates are dropped early if
+	// they expire.
+	Period time.Duration
+
+	// StateFile is the file path the retained certificates are persisted to,
+	// so that they survive restarts.
+	StateFile string
+}
+
+// retainedCertificate is a root certificate in the retain state.
+type retainedCertificate struct {
+	// Certificate is the PEM encoded certificate.
+	Certificate string `json:"certificate"`
+
+	// RemovedAt is when the certificate was removed from the source. Nil if
+	// the certificate is in the source.
+	RemovedAt *time.Time `json:"removedAt,omitempty"`
+
+	// cert is the parsed Certificate.
+	cert *x509.Certificate
+}
+
+// retain is an implementation of RootCAs which wraps a source, and continues
+// to distribute root certificates for a period after they have been removed
+// from it, so that peers holding certificates issued by a removed root have
+// time to renew.
+type retain struct {
+	// log is the RootCAs retain logger.
+	log logr.Logger
+
+	// source is the wrapped source.
+	source Interface
+
+	// period is how long removed certificates are retained.
+	period time.Duration
+
+	// stateFile is the file path the state is persisted to.
+	stateFile string
+
+	// lock is used as a semaphore for accessing the fields below.
+	lock sync.RWMutex
+
+	// state is every certificate which is distributed, in source order
+	// followed by removal order.
+	state []*retainedCertificate
+
+	// certificatesPEM is the current root certificates.
+	certificatesPEM []byte
+
+	// subscribers is the list of subscribers that will be sent a message when
+	// the root certificates changes.
+	subscribers []chan<- struct{}
+}
+
+// NewRetain constructs a new retain implementation of RootCAs which wraps the
+// source. Root certificates removed from the source continue to be
+// distributed until the retain period passes, or they expire. The retained
+// certificates are loaded from the state file, if it exists.
+func NewRetain(ctx context.Context, log logr.Logger, source Interface, opts RetainOptions) (Interface, error) {
+	if opts.Period <= 0 {
+		return nil, errors.New("root CAs retain period must be positive")
+	}
+
+	if len(opts.StateFile) == 0 {
+		return nil, errors.New("root CAs retain state file is required")
+	}
+
+	r := &retain{
+		log:       log.WithName("retain").WithValues("state_file", opts.StateFile, "period", opts.Period),
+		source:    source,
+		period:    opts.Period,
+		stateFile: opts.StateFile,
+	}
+
+	if err := r.loadState(); err != nil {
+		return nil, err
+	}
+
+	sub := source.Subscribe()
+	r.update()
+
+	go func() {
+		for {
+			// Re-evaluate when the next retained certificate is due to be
+			// dropped.
+			timer := time.NewTimer(r.nextExpiry())
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				r.log.Info("closing root CAs retain")
+				return
+			case <-sub:
+			case <-timer.C:
+			}
+
+			timer.Stop()
+			r.update()
+		}
+	}()
+
+	return r, nil
+}
+
+// loadState loads the state from the state file. A missing state file is not
+// an error.
+func (r *retain) loadState() error {
+	data, err := os.ReadFile(r.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read root CAs retain state file %q: %w", r.stateFile, err)
+	}
+
+	var state []*retainedCertificate
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to decode root CAs retain state file %q: %w", r.stateFile, err)
+	}
+
+	for _, rc := range state {
+		block, _ := pem.Decode([]byte(rc.Certificate))
+		if block == nil {
+			r.log.Error(errors.New("invalid PEM"), "dropping invalid certificate from retain state")
+			continue
+		}
+		rc.cert, err = x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			r.log.Error(err, "dropping invalid certificate from retain state")
+			continue
+		}
+		r.state = append(r.state, rc)
+	}
+
+	return nil
+}
+
+// saveStateLocked atomically writes the state to the state file. Must be called
+// with the lock held.
+func (r *retain) saveStateLocked() error {
+	data, err := json.Marshal(r.state)
+	if err != nil {
+		return fmt.Errorf("failed to encode root CAs retain state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.stateFile), filepath.Base(r.stateFile)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create root CAs retain state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write root CAs retain state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write root CAs retain state file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), r.stateFile); err != nil {
+		return fmt.Errorf("failed to replace root CAs retain state file: %w", err)
+	}
+
+	return nil
+}
+
+// nextExpiry returns how long until the next retained certificate is due to
+// be dropped. Defaults to the retain period if no certificates are retained.
+func (r *retain) nextExpiry() time.Duration {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	next := r.period
+	for _, rc := range r.state {
+		if rc.RemovedAt == nil {
+			continue
+		}
+		until := time.Until(minTime(rc.RemovedAt.Add(r.period), rc.cert.NotAfter))
+		next = min(next, max(until, time.Second))
+	}
+
+	return next
+}
+
+// update reconciles the state with the current root certificates of the
+// source, drops retained certificates which are due, and broadcasts an event
+// to subscribers if the root certificates changed.
+func (r *retain) update() {
+	var current []*x509.Certificate
+	if certificatesPEM := r.source.CertificatesPEM(); len(certificatesPEM) > 0 {
+		var err error
+		certificatesPEM, err = Sanitize(certificatesPEM, SanitizeOptions{})
+		if err != nil {
+			rejectedRootCAs.WithLabelValues("retain").Inc()
+			r.log.Error(err, "rejecting root CAs of source, continuing to use the last valid root CAs")
+			return
+		}
+		current, err = pki.DecodeX509CertificateSetBytes(certificatesPEM)
+		if err != nil {
+			r.log.Error(err, "failed to decode root CAs of source")
+			return
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// The source has no root CAs yet, so no root CA can be known to have been
+	// removed from it.
+	if len(current) == 0 {
+		return
+	}
+
+	now := time.Now()
+
+	// dirty is set if the state needs to be persisted.
+	dirty := false
+
+	inSource := make(map[string]struct{}, len(current))
+	state := make([]*retainedCertificate, 0, len(current)+len(r.state))
+	for _, cert := range current {
+		inSource[string(cert.Raw)] = struct{}{}
+		state = append(state, &retainedCertificate{
+			Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+			cert:        cert,
+		})
+	}
+
+	for _, rc := range r.state {
+		if _, ok := inSource[string(rc.cert.Raw)]; ok {
+			continue
+		}
+
+		if rc.RemovedAt == nil {
+			r.log.Info("root CA removed from source, retaining it", "subject", rc.cert.Subject.String(), "not_after", rc.cert.NotAfter)
+			rc.RemovedAt = &now
+			dirty = true
+		}
+
+		if now.After(rc.RemovedAt.Add(r.period)) || now.After(rc.cert.NotAfter) {
+			r.log.Info("dropping retained root CA", "subject", rc.cert.Subject.String(), "removed_at", *rc.RemovedAt, "not_after", rc.cert.NotAfter)
+			dirty = true
+			continue
+		}
+
+		state = append(state, rc)
+	}
+
+	var certificatesPEM []byte
+	for _, rc := range state {
+		certificatesPEM = append(certificatesPEM, rc.Certificate...)
+	}
+
+	r.state = state
+
+	changed := !bytes.Equal(certificatesPEM, r.certificatesPEM)
+	if dirty || changed {
+		if err := r.saveStateLocked(); err != nil {
+			r.log.Error(err, "failed to persist root CAs retain state")
+		}
+	}
+
+	// If the certificates haven't changed, no need to update store and
+	// broadcast event.
+	if !changed {
+		return
+	}
+
+	r.certificatesPEM = certificatesPEM
+	for i := range r.subscribers {
+		go func(i int) { r.subscribers[i] <- struct{}{} }(i)
+	}
+}
+
+// minTime returns the earlier of a and b.
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// CertificatesPEM returns the current root CA certificates of the source,
+// followed by any retained root CA certificates.
+func (r *retain) CertificatesPEM() []byte {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.certificatesPEM
+}
+
+// Subscribe subscribes the consumer to events to when the root CAs change.
+func (r *retain) Subscribe() <-chan struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	sub := make(chan struct{})
+	r.subscribers = append(r.subscribers, sub)
+	return sub
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func Test_NewRetain(t *testing.T) {
+	ca1, _ := testCA(t, "ca-1")
+	ca2, _ := testCA(t, "ca-2")
+
+	stateFile := filepath.Join(t.TempDir(), "ca-retention.json")
+
+	newSource := func(ctx context.Context, certificatesPEM []byte) (Interface, chan<- []byte) {
+		ch := make(chan []byte)
+		source := NewMemory(ctx, ch)
+		ch <- certificatesPEM
+		assert.Eventually(t, func() bool { return len(source.CertificatesPEM()) > 0 }, time.Second, time.Millisecond*10)
+		return source, ch
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	source, sourceChan := newSource(ctx, testCertificatesPEM(t, ca1, ca2))
+
+	r, err := NewRetain(ctx, ktesting.NewLogger(t, ktesting.DefaultConfig), source, RetainOptions{Period: time.Hour, StateFile: stateFile})
+	require.NoError(t, err)
+	sub := r.Subscribe()
+
+	t.Log("should distribute the root CAs of the source straight away")
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2), r.CertificatesPEM())
+
+	t.Log("should retain a root CA removed from the source")
+	sourceChan <- testCertificatesPEM(t, ca2)
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when the root CAs of the source changed")
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca2, ca1), r.CertificatesPEM(), "expected retained root CAs after those of the source")
+
+	t.Log("should retain the root CA across restarts")
+	cancel()
+	ctx, cancel = context.WithCancel(t.Context())
+	source, sourceChan = newSource(t.Context(), testCertificatesPEM(t, ca2))
+	r, err = NewRetain(ctx, ktesting.NewLogger(t, ktesting.DefaultConfig), source, RetainOptions{Period: time.Hour, StateFile: stateFile})
+	require.NoError(t, err)
+	sub = r.Subscribe()
+	assert.Equal(t, testCertificatesPEM(t, ca2, ca1), r.CertificatesPEM())
+
+	t.Log("should stop retaining a root CA which is added back to the source")
+	sourceChan <- testCertificatesPEM(t, ca1, ca2)
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when the root CA order changed")
+	}
+	assert.Equal(t, testCertificatesPEM(t, ca1, ca2), r.CertificatesPEM())
+
+	t.Log("should drop a removed root CA once the retain period has passed")
+	cancel()
+	r, err = NewRetain(t.Context(), ktesting.NewLogger(t, ktesting.DefaultConfig), source, RetainOptions{Period: time.Millisecond * 100, StateFile: stateFile})
+	require.NoError(t, err)
+	sub = r.Subscribe()
+	sourceChan <- testCertificatesPEM(t, ca2)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, testCertificatesPEM(t, ca2), r.CertificatesPEM())
+	}, time.Second*5, time.Millisecond*10)
+	select {
+	case <-sub:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "expected to receive an event when the retained root CA was dropped")
+	}
+}
+
+func Test_NewRetain_validation(t *testing.T) {
+	source := NewMemory(t.Context(), nil)
+	log := ktesting.NewLogger(t, ktesting.DefaultConfig)
+
+	_, err := NewRetain(t.Context(), log, source, RetainOptions{StateFile: filepath.Join(t.TempDir(), "state.json")})
+	assert.Error(t, err, "expected an error when no period is given")
+
+	_, err = NewRetain(t.Context(), log, source, RetainOptions{Period: time.Hour})
+	assert.Error(t, err, "expected an error when no state file is given")
+}
@@ -28,3 +28,18 @@ type Interface interface {
 	// domain root certificates change.
 	Subscribe() <-chan struct{}
 }
+
+// FederatedInterface provides the root PEM encoded X.509 Certificate
+// Authority certificates of federated trust domains. Consumers can subscribe
+// to events to when the root certificates of any federated trust domain
+// change.
+type FederatedInterface interface {
+	// TrustDomainCertificatesPEM returns the current PEM encoded X.509
+	// Certificate Authority certificates of each federated trust domain,
+	// keyed by trust domain name.
+	TrustDomainCertificatesPEM() map[string][]byte
+
+	// Subscribe returns a channel which will receive messages when the root
+	// certificates of any federated trust domain change.
+	Subscribe() <-chan struct{}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SanitizeOptions are options for sanitising root certificates before they are
+// distributed.
+type SanitizeOptions struct {
+	// DropExpired drops certificates which have expired.
+	DropExpired bool
+}
+
+// Sanitize parses the PEM encoded root certificates, and returns them
+// re-encoded with non-certificate blocks, duplicates and, optionally, expired
+// certificates dropped. An error is returned if a certificate block cannot be
+// parsed, the PEM data is truncated, or no certificates remain, so that a
+// partially written bundle is never distributed.
+func Sanitize(certificatesPEM []byte, opts SanitizeOptions) ([]byte, error) {
+	now := time.Now()
+
+	var (
+		out  []byte
+		seen = make(map[string]struct{})
+		rest = certificatesPEM
+	)
+
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse root CA certificate: %w", err)
+		}
+
+		if _, ok := seen[string(cert.Raw)]; ok {
+			continue
+		}
+		seen[string(cert.Raw)] = struct{}{}
+
+		if opts.DropExpired && now.After(cert.NotAfter) {
+			continue
+		}
+
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+
+	// pem.Decode stops at the first block it cannot decode, so a truncated
+	// block leaves its header in the remaining data.
+	if bytes.Contains(rest, []byte("-----BEGIN")) {
+		return nil, errors.New("root CA certificates contain a truncated or malformed PEM block")
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("no valid root CA certificates found")
+	}
+
+	return out, nil
+}
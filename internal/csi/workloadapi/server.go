@@ -0,0 +1,242 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workloadapi
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/spiffe/go-spiffe/v2/proto/spiffe/workload"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// headerKey is the gRPC metadata header which all Workload API clients
+	// must set, to guard against server-side request forgery.
+	headerKey = "workload.spiffe.io"
+
+	// maxSocketPathLength is the maximum length of a unix socket path that can
+	// be bound to directly. Longer paths are bound via /proc/self/fd.
+	maxSocketPathLength = 100
+)
+
+// server serves the SPIFFE Workload API for a single volume.
+type server struct {
+	workload.UnimplementedSpiffeWorkloadAPIServer
+
+	// log is the logger for this volume's server.
+	log logr.Logger
+
+	// socketPath is the path of the unix socket this server listens on.
+	socketPath string
+
+	// grpcServer is the gRPC server serving the Workload API.
+	grpcServer *grpc.Server
+
+	// lock guards access to the fields below.
+	lock sync.RWMutex
+
+	// svid is the current X.509-SVID of the volume, without bundle.
+	svid *workload.X509SVID
+
+	// trustDomainID is the SPIFFE ID of the local trust domain, used as the
+	// key of the local bundle.
+	trustDomainID string
+
+	// bundle is the concatenated DER encoded local trust domain bundle. Nil
+	// until the root CA sources have valid certificates, during which nothing
+	// is sent to workloads.
+	bundle []byte
+
+	// updated is closed and replaced whenever the svid or bundle changes,
+	// waking all open streams.
+	updated chan struct{}
+}
+
+// newServer starts serving the Workload API on a unix socket at socketPath.
+func newServer(log logr.Logger, socketPath, trustDomainID string, svid *workload.X509SVID, bundle []byte) (*server, error) {
+	s := &server{
+		log:           log,
+		socketPath:    socketPath,
+		grpcServer:    grpc.NewServer(),
+		svid:          svid,
+		trustDomainID: trustDomainID,
+		bundle:        bundle,
+		updated:       make(chan struct{}),
+	}
+
+	workload.RegisterSpiffeWorkloadAPIServer(s.grpcServer, s)
+
+	lis, err := listenUnix(socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := s.grpcServer.Serve(lis); err != nil {
+			s.log.Error(err, "workload API server stopped")
+		}
+	}()
+
+	return s, nil
+}
+
+// stop closes all open streams and removes the socket.
+func (s *server) stop() {
+	s.grpcServer.Stop()
+	if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
+		s.log.Error(err, "failed to remove workload API socket")
+	}
+}
+
+// setSVID replaces the served X.509-SVID and notifies open streams.
+func (s *server) setSVID(svid *workload.X509SVID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.svid = svid
+	s.notify()
+}
+
+// setBundle replaces the served trust bundle and notifies open streams.
+func (s *server) setBundle(bundle []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.bundle = bundle
+	s.notify()
+}
+
+// notify wakes all open streams. Must be called with lock held.
+func (s *server) notify() {
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// FetchX509SVID streams the volume's X.509-SVID, sending a new response
+// whenever the SVID or bundle changes. Nothing is sent until the bundle is
+// available.
+func (s *server) FetchX509SVID(_ *workload.X509SVIDRequest, stream grpc.ServerStreamingServer[workload.X509SVIDResponse]) error {
+	if err := checkHeader(stream); err != nil {
+		return err
+	}
+
+	for {
+		s.lock.RLock()
+		svid := &workload.X509SVID{
+			SpiffeId:    s.svid.GetSpiffeId(),
+			X509Svid:    s.svid.GetX509Svid(),
+			X509SvidKey: s.svid.GetX509SvidKey(),
+			Bundle:      s.bundle,
+		}
+		updated := s.updated
+		s.lock.RUnlock()
+
+		if len(svid.Bundle) > 0 {
+			if err := stream.Send(&workload.X509SVIDResponse{Svids: []*workload.X509SVID{svid}}); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-updated:
+		}
+	}
+}
+
+// FetchX509Bundles streams the local trust domain bundle, sending a new
+// response whenever it changes. Nothing is sent until the bundle is available.
+func (s *server) FetchX509Bundles(_ *workload.X509BundlesRequest, stream grpc.ServerStreamingServer[workload.X509BundlesResponse]) error {
+	if err := checkHeader(stream); err != nil {
+		return err
+	}
+
+	for {
+		s.lock.RLock()
+		bundle := s.bundle
+		updated := s.updated
+		s.lock.RUnlock()
+
+		if len(bundle) > 0 {
+			resp := &workload.X509BundlesResponse{
+				Bundles: map[string][]byte{s.trustDomainID: bundle},
+			}
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-updated:
+		}
+	}
+}
+
+// checkHeader returns an error if the stream is missing the required
+// Workload API security header.
+func checkHeader(stream grpc.ServerStream) error {
+	md, ok := metadata.FromIncomingContext(stream.Context())
+	if !ok || len(md.Get(headerKey)) != 1 || md.Get(headerKey)[0] != "true" {
+		return status.Errorf(codes.InvalidArgument, "security header missing from request")
+	}
+	return nil
+}
+
+// listenUnix listens on a unix socket at path, replacing any stale socket.
+// Volume paths are often longer than the unix socket path limit, so long
+// paths are bound relative to an open handle of their parent directory.
+func listenUnix(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket %q: %w", path, err)
+	}
+
+	bindPath := path
+	if len(path) > maxSocketPathLength {
+		dir, err := os.Open(filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open socket directory: %w", err)
+		}
+		defer dir.Close()
+
+		bindPath = fmt.Sprintf("/proc/self/fd/%d/%s", dir.Fd(), filepath.Base(path))
+	}
+
+	lis, err := net.Listen("unix", bindPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on socket %q: %w", path, err)
+	}
+
+	// The socket is removed explicitly on stop using its real path.
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	// Pods may run as any user, access is restricted by the volume itself.
+	if err := os.Chmod(path, 0777); err != nil { // #nosec G302 -- socket must be usable by any pod user
+		lis.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	return lis, nil
+}
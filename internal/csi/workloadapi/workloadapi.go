@@ -0,0 +1,368 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package workloadapi serves the SPIFFE Workload API to pods over a unix
+// socket placed inside each managed volume. Each volume's socket only serves
+// the X.509-SVID which was issued for that volume.
+package workloadapi
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/spiffe/go-spiffe/v2/proto/spiffe/workload"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
+)
+
+// Store is the subset of the csi-lib file system storage used to discover
+// managed volumes and their current keypair.
+type Store interface {
+	// ListVolumes returns the IDs of all managed volumes.
+	ListVolumes() ([]string, error)
+
+	// ReadFile reads the named file from the volume.
+	ReadFile(volumeID, name string) ([]byte, error)
+
+	// PathForVolume returns the path of the volume's data directory, which is
+	// mounted into the pod.
+	PathForVolume(volumeID string) string
+}
+
+// Options configure the Workload API Manager.
+type Options struct {
+	// Store is used to discover managed volumes.
+	Store Store
+
+	// RootCAs is the trust bundle served to workloads. Required.
+	RootCAs rootca.Interface
+
+	// TrustDomain is the trust domain of the served SVIDs and bundle.
+	TrustDomain string
+
+	// SocketName is the file name of the socket within each volume.
+	SocketName string
+
+	// CertificateFileName and KeyFileName are the names of the files the
+	// keypair is written to in each volume. Used to serve existing volumes
+	// when the driver restarts.
+	CertificateFileName, KeyFileName string
+}
+
+// Manager runs a Workload API server for every managed volume.
+type Manager struct {
+	// log is the Manager logger.
+	log logr.Logger
+
+	// store is used to discover managed volumes.
+	store Store
+
+	// rootCAs is the trust bundle served to workloads.
+	rootCAs rootca.Interface
+
+	// trustDomain is the trust domain of the served SVIDs and bundle.
+	trustDomain spiffeid.TrustDomain
+
+	// socketName, certFileName, keyFileName are the names of files within each
+	// volume.
+	socketName, certFileName, keyFileName string
+
+	// lock guards servers.
+	lock sync.Mutex
+
+	// servers are the running servers, keyed by volume ID.
+	servers map[string]*server
+}
+
+// New constructs a new Workload API Manager.
+func New(log logr.Logger, opts Options) (*Manager, error) {
+	if opts.RootCAs == nil {
+		return nil, errors.New("a root CA bundle source is required to serve the workload API")
+	}
+
+	if len(opts.SocketName) == 0 {
+		return nil, errors.New("a socket name is required to serve the workload API")
+	}
+
+	td, err := spiffeid.TrustDomainFromString(opts.TrustDomain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust domain %q: %w", opts.TrustDomain, err)
+	}
+
+	return &Manager{
+		log:          log.WithName("workload-api"),
+		store:        opts.Store,
+		rootCAs:      opts.RootCAs,
+		trustDomain:  td,
+		socketName:   opts.SocketName,
+		certFileName: opts.CertificateFileName,
+		keyFileName:  opts.KeyFileName,
+		servers:      make(map[string]*server),
+	}, nil
+}
+
+// Run starts serving existing volumes, and keeps the set of servers in sync
+// with the managed volumes. Servers are stopped for volumes which have been
+// removed. Trust bundle changes are pushed to all servers. Blocking function.
+func (m *Manager) Run(ctx context.Context, resyncPeriod time.Duration) {
+	watcher := m.rootCAs.Subscribe()
+
+	m.log.Info("starting workload API manager")
+	m.resync()
+
+	ticker := time.NewTicker(resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.log.Info("closing workload API manager")
+			m.stopAll()
+			return
+
+		case <-watcher:
+			m.log.Info("root CA event received, updating workload API bundles")
+			m.updateBundle()
+
+		case <-ticker.C:
+			m.resync()
+		}
+	}
+}
+
+// Update serves the given keypair on the volume's Workload API socket,
+// starting the server if it is not already running.
+func (m *Manager) Update(volumeID string, key crypto.PrivateKey, chain []byte) error {
+	svid, err := m.buildSVID(key, chain)
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if s, ok := m.servers[volumeID]; ok {
+		s.setSVID(svid)
+		return nil
+	}
+
+	return m.startServer(volumeID, svid)
+}
+
+// resync starts servers for volumes which aren't being served, from the
+// keypair stored in the volume, and stops servers of removed volumes.
+func (m *Manager) resync() {
+	volumeIDs, err := m.store.ListVolumes()
+	if err != nil {
+		m.log.Error(err, "failed to list managed volumes")
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	exists := make(map[string]bool, len(volumeIDs))
+	for _, volumeID := range volumeIDs {
+		exists[volumeID] = true
+
+		if _, ok := m.servers[volumeID]; ok {
+			continue
+		}
+
+		svid, err := m.readSVID(volumeID)
+		if err != nil {
+			// The volume may not have been issued a certificate yet.
+			m.log.V(2).Info("not serving volume", "volume", volumeID, "reason", err.Error())
+			continue
+		}
+
+		if err := m.startServer(volumeID, svid); err != nil {
+			m.log.Error(err, "failed to start workload API server", "volume", volumeID)
+		}
+	}
+
+	for volumeID, s := range m.servers {
+		if !exists[volumeID] {
+			m.log.Info("stopping workload API server for removed volume", "volume", volumeID)
+			s.stop()
+			delete(m.servers, volumeID)
+		}
+	}
+}
+
+// updateBundle pushes the current trust bundle to all servers.
+func (m *Manager) updateBundle() {
+	bundle, err := m.bundle()
+	if err != nil {
+		m.log.Error(err, "failed to decode root CA certificates, not updating workload API bundles")
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, s := range m.servers {
+		s.setBundle(bundle)
+	}
+}
+
+// stopAll stops all running servers.
+func (m *Manager) stopAll() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for volumeID, s := range m.servers {
+		s.stop()
+		delete(m.servers, volumeID)
+	}
+}
+
+// startServer starts a server for the volume. Must be called with lock held.
+func (m *Manager) startServer(volumeID string, svid *workload.X509SVID) error {
+	bundle, err := m.bundle()
+	if err != nil {
+		return fmt.Errorf("failed to decode root CA certificates: %w", err)
+	}
+
+	socketPath := filepath.Join(m.store.PathForVolume(volumeID), m.socketName)
+	log := m.log.WithValues("volume", volumeID)
+
+	s, err := newServer(log, socketPath, m.trustDomain.IDString(), svid, bundle)
+	if err != nil {
+		return err
+	}
+
+	log.Info("started workload API server", "socket", socketPath)
+	m.servers[volumeID] = s
+
+	return nil
+}
+
+// readSVID builds an X.509-SVID from the keypair stored in the volume.
+func (m *Manager) readSVID(volumeID string) (*workload.X509SVID, error) {
+	chain, err := m.store.ReadFile(volumeID, m.certFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+
+	keyPEM, err := m.store.ReadFile(volumeID, m.keyFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("failed to decode key file PEM")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	return m.buildSVID(key, chain)
+}
+
+// buildSVID builds a Workload API X.509-SVID from a private key and PEM
+// encoded certificate chain.
+func (m *Manager) buildSVID(key crypto.PrivateKey, chain []byte) (*workload.X509SVID, error) {
+	chainDER, err := pemToDER(chain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode certificate chain: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(firstCertificate(chain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse leaf certificate: %w", err)
+	}
+
+	if len(leaf.URIs) != 1 {
+		return nil, fmt.Errorf("expected exactly 1 URI SAN on leaf certificate, got=%d", len(leaf.URIs))
+	}
+
+	id, err := spiffeid.FromURI(leaf.URIs[0])
+	if err != nil {
+		return nil, fmt.Errorf("leaf certificate has invalid SPIFFE ID: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	return &workload.X509SVID{
+		SpiffeId:    id.String(),
+		X509Svid:    chainDER,
+		X509SvidKey: keyDER,
+	}, nil
+}
+
+// pemToDER returns the concatenated DER bytes of all certificates in the PEM
+// data, as expected by the Workload API.
+// bundle returns the current trust bundle DER encoded, or nil if the root CA
+// sources have no valid certificates yet.
+func (m *Manager) bundle() ([]byte, error) {
+	certificatesPEM := m.rootCAs.CertificatesPEM()
+	if len(certificatesPEM) == 0 {
+		return nil, nil
+	}
+	return pemToDER(certificatesPEM)
+}
+
+func pemToDER(data []byte) ([]byte, error) {
+	var der []byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		der = append(der, block.Bytes...)
+	}
+
+	if len(der) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+
+	return der, nil
+}
+
+// firstCertificate returns the DER bytes of the first certificate in the PEM
+// data.
+func firstCertificate(data []byte) []byte {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil
+		}
+		if block.Type == "CERTIFICATE" {
+			return block.Bytes
+		}
+	}
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workloadapi
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	utilpki "github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	spiffeworkloadapi "github.com/spiffe/go-spiffe/v2/workloadapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+
+	"github.com/cert-manager/csi-driver-spiffe/internal/csi/rootca"
+)
+
+// fakeStore is an in-memory Store with volume directories on disk.
+type fakeStore struct {
+	baseDir string
+	lock    sync.Mutex
+	files   map[string]map[string][]byte
+}
+
+func (f *fakeStore) ListVolumes() ([]string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var ids []string
+	for id := range f.files {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (f *fakeStore) ReadFile(volumeID, name string) ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	data, ok := f.files[volumeID][name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return data, nil
+}
+
+func (f *fakeStore) PathForVolume(volumeID string) string {
+	return filepath.Join(f.baseDir, volumeID)
+}
+
+func (f *fakeStore) addVolume(t *testing.T, volumeID string, files map[string][]byte) {
+	require.NoError(t, os.MkdirAll(f.PathForVolume(volumeID), 0700))
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.files[volumeID] = files
+}
+
+func (f *fakeStore) removeVolume(volumeID string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.files, volumeID)
+}
+
+func newTestCA(t *testing.T, name string) ([]byte, func(id string) (crypto.PrivateKey, []byte)) {
+	capk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	caTmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: cmapi.CertificateSpec{CommonName: name, IsCA: true}})
+	require.NoError(t, err)
+
+	caPEM, ca, err := utilpki.SignCertificate(caTmpl, caTmpl, capk.Public(), capk)
+	require.NoError(t, err)
+
+	return caPEM, func(id string) (crypto.PrivateKey, []byte) {
+		leafpk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		leafTmpl, err := utilpki.CertificateTemplateFromCertificate(&cmapi.Certificate{Spec: cmapi.CertificateSpec{URIs: []string{id}}})
+		require.NoError(t, err)
+
+		leafPEM, _, err := utilpki.SignCertificate(leafTmpl, ca, leafpk.Public(), capk)
+		require.NoError(t, err)
+
+		return leafpk, leafPEM
+	}
+}
+
+func Test_Manager(t *testing.T) {
+	caPEM, issue := newTestCA(t, "ca-1")
+
+	rootCAsChan := make(chan []byte)
+	rootCAs := rootca.NewMemory(t.Context(), rootCAsChan)
+	rootCAsChan <- caPEM
+
+	store := &fakeStore{baseDir: t.TempDir(), files: make(map[string]map[string][]byte)}
+
+	m, err := New(ktesting.NewLogger(t, ktesting.DefaultConfig), Options{
+		Store:               store,
+		RootCAs:             rootCAs,
+		TrustDomain:         "foo.bar",
+		SocketName:          "agent.sock",
+		CertificateFileName: "tls.crt",
+		KeyFileName:         "tls.key",
+	})
+	require.NoError(t, err)
+
+	t.Log("existing volumes should be served when the manager starts")
+	key, chain := issue("spiffe://foo.bar/ns/sandbox/sa/existing")
+	keyPEM, err := utilpki.EncodePrivateKey(key, cmapi.PKCS8)
+	require.NoError(t, err)
+	store.addVolume(t, "vol-existing", map[string][]byte{"tls.crt": chain, "tls.key": keyPEM})
+
+	go m.Run(t.Context(), time.Millisecond*50)
+
+	existingAddr := "unix://" + filepath.Join(store.PathForVolume("vol-existing"), "agent.sock")
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		svid, err := spiffeworkloadapi.FetchX509SVID(t.Context(), spiffeworkloadapi.WithAddr(existingAddr))
+		if assert.NoError(c, err) {
+			assert.Equal(c, "spiffe://foo.bar/ns/sandbox/sa/existing", svid.ID.String())
+		}
+	}, time.Second*5, time.Millisecond*50)
+
+	t.Log("calling Update should serve a new volume")
+	store.addVolume(t, "vol-new", map[string][]byte{})
+	key, chain = issue("spiffe://foo.bar/ns/sandbox/sa/new")
+	require.NoError(t, m.Update("vol-new", key, chain))
+
+	newAddr := "unix://" + filepath.Join(store.PathForVolume("vol-new"), "agent.sock")
+	svid, err := spiffeworkloadapi.FetchX509SVID(t.Context(), spiffeworkloadapi.WithAddr(newAddr))
+	require.NoError(t, err)
+	assert.Equal(t, "spiffe://foo.bar/ns/sandbox/sa/new", svid.ID.String())
+
+	bundles, err := spiffeworkloadapi.FetchX509Bundles(t.Context(), spiffeworkloadapi.WithAddr(newAddr))
+	require.NoError(t, err)
+	bundle, err := bundles.GetX509BundleForTrustDomain(spiffeid.RequireTrustDomainFromString("foo.bar"))
+	require.NoError(t, err)
+	assert.Len(t, bundle.X509Authorities(), 1)
+
+	t.Log("trust bundle changes should be pushed to workloads")
+	otherCAPEM, _ := newTestCA(t, "ca-2")
+	rootCAsChan <- append(append([]byte{}, caPEM...), otherCAPEM...)
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		bundles, err := spiffeworkloadapi.FetchX509Bundles(t.Context(), spiffeworkloadapi.WithAddr(newAddr))
+		if !assert.NoError(c, err) {
+			return
+		}
+		bundle, err := bundles.GetX509BundleForTrustDomain(spiffeid.RequireTrustDomainFromString("foo.bar"))
+		if assert.NoError(c, err) {
+			assert.Len(c, bundle.X509Authorities(), 2)
+		}
+	}, time.Second*5, time.Millisecond*50)
+
+	t.Log("servers of removed volumes should be stopped")
+	store.removeVolume("vol-existing")
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		_, err := os.Stat(strings.TrimPrefix(existingAddr, "unix://"))
+		assert.True(c, os.IsNotExist(err), "expected socket to be removed")
+	}, time.Second*5, time.Millisecond*50)
+}
+
+func Test_Manager_noRootCAsYet(t *testing.T) {
+	caPEM, issue := newTestCA(t, "ca-1")
+
+	rootCAsChan := make(chan []byte)
+	rootCAs := rootca.NewMemory(t.Context(), rootCAsChan)
+
+	store := &fakeStore{baseDir: t.TempDir(), files: make(map[string]map[string][]byte)}
+
+	m, err := New(ktesting.NewLogger(t, ktesting.DefaultConfig), Options{
+		Store:               store,
+		RootCAs:             rootCAs,
+		TrustDomain:         "foo.bar",
+		SocketName:          "agent.sock",
+		CertificateFileName: "tls.crt",
+		KeyFileName:         "tls.key",
+	})
+	require.NoError(t, err)
+
+	go m.Run(t.Context(), time.Millisecond*50)
+
+	t.Log("calling Update should serve a volume while there are no root CAs")
+	store.addVolume(t, "vol-new", map[string][]byte{})
+	key, chain := issue("spiffe://foo.bar/ns/sandbox/sa/new")
+	require.NoError(t, m.Update("vol-new", key, chain))
+
+	t.Log("should send nothing until the root CAs are available")
+	addr := "unix://" + filepath.Join(store.PathForVolume("vol-new"), "agent.sock")
+	ctx, cancel := context.WithTimeout(t.Context(), time.Millisecond*200)
+	defer cancel()
+	_, err = spiffeworkloadapi.FetchX509SVID(ctx, spiffeworkloadapi.WithAddr(addr))
+	assert.Error(t, err, "expected no X.509-SVID to be sent without a bundle")
+
+	t.Log("should send the X.509-SVID once the root CAs are available")
+	rootCAsChan <- caPEM
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		svid, err := spiffeworkloadapi.FetchX509SVID(t.Context(), spiffeworkloadapi.WithAddr(addr))
+		if assert.NoError(c, err) {
+			assert.Equal(c, "spiffe://foo.bar/ns/sandbox/sa/new", svid.ID.String())
+		}
+	}, time.Second*5, time.Millisecond*50)
+}
+
+func Test_listenUnix_longPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), strings.Repeat("a", 60), strings.Repeat("b", 60))
+	require.NoError(t, os.MkdirAll(dir, 0700))
+
+	path := filepath.Join(dir, "agent.sock")
+	require.Greater(t, len(path), maxSocketPathLength)
+
+	lis, err := listenUnix(path)
+	require.NoError(t, err)
+	defer lis.Close()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSocket, info.Mode().Type())
+}
@@ -0,0 +1,288 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package identity builds the SPIFFE IDs of mounting pods from a configurable
+// path template. The same template is used by the driver when requesting
+// certificates and by the approver when verifying them.
+package identity
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+)
+
+const (
+	// DefaultPathTemplate is the path template used when none is configured.
+	// It produces the SPIFFE ID format used by Istio and most SPIFFE tooling,
+	// spiffe://<trust-domain>/ns/<namespace>/sa/<service-account>.
+	DefaultPathTemplate = "/ns/{namespace}/sa/{service-account}"
+
+	placeholderTrustDomain    = "trust-domain"
+	placeholderNamespace      = "namespace"
+	placeholderServiceAccount = "service-account"
+	placeholderClusterName    = "cluster-name"
+
+	// placeholderPodLabelPrefix is the prefix of a placeholder which is
+	// replaced with the value of the named pod label, e.g.
+	// {pod-label:app.kubernetes.io/name}.
+	placeholderPodLabelPrefix = "pod-label:"
+)
+
+// Params are the values substituted into a Template.
+type Params struct {
+	// TrustDomain is the trust domain of the SPIFFE ID.
+	TrustDomain string
+
+	// Namespace is the namespace of the mounting pod.
+	Namespace string
+
+	// ServiceAccount is the name of the ServiceAccount of the mounting pod.
+	ServiceAccount string
+
+	// ClusterName is the name of the cluster, as configured at install time.
+	ClusterName string
+
+	// PodLabels are the labels of the mounting pod. Only labels referenced by
+	// the template are required to be present.
+	PodLabels map[string]string
+}
+
+// part is a single piece of a parsed template; either a literal string or a
+// placeholder name.
+type part struct {
+	literal     string
+	placeholder string
+}
+
+// Template is a parsed SPIFFE ID path template.
+type Template struct {
+	// raw is the template as it was given to Parse.
+	raw string
+
+	// parts is the parsed template.
+	parts []part
+
+	// podLabels is the list of pod label keys referenced by the template.
+	podLabels []string
+}
+
+// Parse parses the given SPIFFE ID path template. Placeholders are enclosed
+// in braces and may be one of {trust-domain}, {namespace},
+// {service-account}, {cluster-name} or {pod-label:<key>}. Pod labels may only
+// be referenced if their key is present in allowedPodLabels, since the value
+// of any label used must be trusted by both the driver and approver. Every
+// placeholder must be a whole path segment, and the template must reference
+// both {namespace} and {service-account}, so that no two ServiceAccounts can
+// share the same identity.
+func Parse(tmpl string, allowedPodLabels []string) (*Template, error) {
+	if !strings.HasPrefix(tmpl, "/") {
+		return nil, fmt.Errorf("SPIFFE ID path template must begin with '/': %q", tmpl)
+	}
+
+	t := &Template{raw: tmpl}
+
+	for rest := tmpl; len(rest) > 0; {
+		open := strings.IndexByte(rest, '{')
+		if open == -1 {
+			t.parts = append(t.parts, part{literal: rest})
+			break
+		}
+
+		if open > 0 {
+			t.parts = append(t.parts, part{literal: rest[:open]})
+		}
+
+		closing := strings.IndexByte(rest[open:], '}')
+		if closing == -1 {
+			return nil, fmt.Errorf("unterminated placeholder in SPIFFE ID path template %q", tmpl)
+		}
+
+		name := rest[open+1 : open+closing]
+		if strings.ContainsRune(name, '{') {
+			return nil, fmt.Errorf("nested placeholder in SPIFFE ID path template %q", tmpl)
+		}
+
+		switch {
+		case name == placeholderTrustDomain, name == placeholderNamespace,
+			name == placeholderServiceAccount, name == placeholderClusterName:
+
+		case strings.HasPrefix(name, placeholderPodLabelPrefix):
+			key := strings.TrimPrefix(name, placeholderPodLabelPrefix)
+			if !slices.Contains(allowedPodLabels, key) {
+				return nil, fmt.Errorf("pod label %q referenced in SPIFFE ID path template is not in the list of allowed pod labels %q",
+					key, allowedPodLabels)
+			}
+			if !slices.Contains(t.podLabels, key) {
+				t.podLabels = append(t.podLabels, key)
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown placeholder %q in SPIFFE ID path template %q", name, tmpl)
+		}
+
+		t.parts = append(t.parts, part{placeholder: name})
+		rest = rest[open+closing+1:]
+	}
+
+	for i, p := range t.parts {
+		if strings.ContainsRune(p.literal, '}') {
+			return nil, fmt.Errorf("unexpected '}' in SPIFFE ID path template %q", tmpl)
+		}
+
+		// Placeholders must fill a whole segment. Otherwise values could be
+		// shifted between adjacent placeholders and literals to render the
+		// same path, e.g. "/{namespace}-{service-account}" renders
+		// namespace "a-b" and ServiceAccount "c" the same as namespace "a"
+		// and ServiceAccount "b-c".
+		if len(p.placeholder) > 0 {
+			// The template begins with '/', so a placeholder is never first.
+			startsSegment := strings.HasSuffix(t.parts[i-1].literal, "/")
+			endsSegment := i == len(t.parts)-1 || strings.HasPrefix(t.parts[i+1].literal, "/")
+			if !startsSegment || !endsSegment {
+				return nil, fmt.Errorf("placeholder {%s} must be a whole path segment in SPIFFE ID path template %q", p.placeholder, tmpl)
+			}
+		}
+	}
+
+	if !t.references(placeholderNamespace) || !t.references(placeholderServiceAccount) {
+		return nil, fmt.Errorf("SPIFFE ID path template must reference both {%s} and {%s}: %q",
+			placeholderNamespace, placeholderServiceAccount, tmpl)
+	}
+
+	// Render the template with placeholder values to check the literal parts
+	// form a valid SPIFFE ID path.
+	example := Params{
+		TrustDomain:    "example.org",
+		Namespace:      "namespace",
+		ServiceAccount: "service-account",
+		ClusterName:    "cluster",
+		PodLabels:      map[string]string{},
+	}
+	for _, key := range t.podLabels {
+		example.PodLabels[key] = "value"
+	}
+	if _, err := t.Path(example); err != nil {
+		return nil, fmt.Errorf("invalid SPIFFE ID path template %q: %w", tmpl, err)
+	}
+
+	return t, nil
+}
+
+// Default returns the parsed DefaultPathTemplate.
+func Default() *Template {
+	t, err := Parse(DefaultPathTemplate, nil)
+	if err != nil {
+		panic("failed to parse default SPIFFE ID path template, this is a bug: " + err.Error())
+	}
+	return t
+}
+
+// String returns the template as it was given to Parse.
+func (t *Template) String() string {
+	return t.raw
+}
+
+// PodLabels returns the keys of the pod labels referenced by the template.
+func (t *Template) PodLabels() []string {
+	return slices.Clone(t.podLabels)
+}
+
+// UsesPodLabels returns true if the template references any pod labels. In
+// that case the pod must be looked up in order to render the template.
+func (t *Template) UsesPodLabels() bool {
+	return len(t.podLabels) > 0
+}
+
+// Path renders the SPIFFE ID path for the given parameters. An error is
+// returned if a referenced value is missing, or the result is not a valid
+// SPIFFE ID path.
+func (t *Template) Path(params Params) (string, error) {
+	var b strings.Builder
+
+	for _, p := range t.parts {
+		if len(p.placeholder) == 0 {
+			b.WriteString(p.literal)
+			continue
+		}
+
+		value, err := params.value(p.placeholder)
+		if err != nil {
+			return "", err
+		}
+
+		if strings.ContainsRune(value, '/') {
+			return "", fmt.Errorf("value %q for {%s} must not contain '/'", value, p.placeholder)
+		}
+
+		b.WriteString(value)
+	}
+
+	path := b.String()
+	if err := spiffeid.ValidatePath(path); err != nil {
+		return "", fmt.Errorf("rendered path %q is not a valid SPIFFE ID path: %w", path, err)
+	}
+
+	return path, nil
+}
+
+// ID renders the full SPIFFE ID for the given parameters.
+func (t *Template) ID(params Params) (spiffeid.ID, error) {
+	td, err := spiffeid.TrustDomainFromString(params.TrustDomain)
+	if err != nil {
+		return spiffeid.ID{}, fmt.Errorf("invalid trust domain %q: %w", params.TrustDomain, err)
+	}
+
+	path, err := t.Path(params)
+	if err != nil {
+		return spiffeid.ID{}, err
+	}
+
+	return spiffeid.FromPath(td, path)
+}
+
+// references returns true if the template contains the named placeholder.
+func (t *Template) references(name string) bool {
+	return slices.ContainsFunc(t.parts, func(p part) bool {
+		return p.placeholder == name
+	})
+}
+
+// value returns the value for the named placeholder.
+func (p Params) value(name string) (string, error) {
+	var value string
+
+	switch {
+	case name == placeholderTrustDomain:
+		value = p.TrustDomain
+	case name == placeholderNamespace:
+		value = p.Namespace
+	case name == placeholderServiceAccount:
+		value = p.ServiceAccount
+	case name == placeholderClusterName:
+		value = p.ClusterName
+	case strings.HasPrefix(name, placeholderPodLabelPrefix):
+		value = p.PodLabels[strings.TrimPrefix(name, placeholderPodLabelPrefix)]
+	}
+
+	if len(value) == 0 {
+		return "", fmt.Errorf("no value for {%s}", name)
+	}
+
+	return value, nil
+}
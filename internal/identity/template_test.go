@@ -0,0 +1,173 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Parse(t *testing.T) {
+	tests := map[string]struct {
+		tmpl          string
+		allowedLabels []string
+		expErr        bool
+	}{
+		"default template should parse": {
+			tmpl:   DefaultPathTemplate,
+			expErr: false,
+		},
+		"template with all placeholders should parse": {
+			tmpl:          "/td/{trust-domain}/cluster/{cluster-name}/ns/{namespace}/sa/{service-account}/app/{pod-label:app}",
+			allowedLabels: []string{"app"},
+			expErr:        false,
+		},
+		"template without leading slash should error": {
+			tmpl:   "ns/{namespace}/sa/{service-account}",
+			expErr: true,
+		},
+		"template without namespace should error": {
+			tmpl:   "/sa/{service-account}",
+			expErr: true,
+		},
+		"template without service account should error": {
+			tmpl:   "/ns/{namespace}",
+			expErr: true,
+		},
+		"template with unknown placeholder should error": {
+			tmpl:   "/ns/{namespace}/sa/{service-account}/{pod-name}",
+			expErr: true,
+		},
+		"template with unterminated placeholder should error": {
+			tmpl:   "/ns/{namespace}/sa/{service-account",
+			expErr: true,
+		},
+		"template with stray closing brace should error": {
+			tmpl:   "/ns/{namespace}/sa/{service-account}}",
+			expErr: true,
+		},
+		"template with pod label not allowed should error": {
+			tmpl:          "/ns/{namespace}/sa/{service-account}/{pod-label:app}",
+			allowedLabels: []string{"version"},
+			expErr:        true,
+		},
+		"template with placeholders sharing a segment should error, as namespace a-b and ServiceAccount c would collide with namespace a and ServiceAccount b-c": {
+			tmpl:   "/{namespace}-{service-account}",
+			expErr: true,
+		},
+		"template with adjacent placeholders should error": {
+			tmpl:   "/ns/{namespace}{service-account}",
+			expErr: true,
+		},
+		"template with placeholder prefixed in its segment should error": {
+			tmpl:   "/ns/{namespace}/sa/sa-{service-account}",
+			expErr: true,
+		},
+		"template with placeholder suffixed in its segment should error": {
+			tmpl:   "/ns/{namespace}.svc/sa/{service-account}",
+			expErr: true,
+		},
+		"template with pod label sharing a segment should error": {
+			tmpl:          "/ns/{namespace}/sa/{service-account}/app-{pod-label:app}",
+			allowedLabels: []string{"app"},
+			expErr:        true,
+		},
+		"template with cluster name sharing a segment should error": {
+			tmpl:   "/{cluster-name}-{namespace}/sa/{service-account}",
+			expErr: true,
+		},
+		"template with empty segment should error": {
+			tmpl:   "/ns//{namespace}/sa/{service-account}",
+			expErr: true,
+		},
+		"template with trailing slash should error": {
+			tmpl:   "/ns/{namespace}/sa/{service-account}/",
+			expErr: true,
+		},
+		"template with invalid characters should error": {
+			tmpl:   "/ns/{namespace}/sa/{service-account}/$",
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(test.tmpl, test.allowedLabels)
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+		})
+	}
+}
+
+func Test_ID(t *testing.T) {
+	tests := map[string]struct {
+		tmpl   string
+		params Params
+		expID  string
+		expErr bool
+	}{
+		"default template should render the ns/sa form": {
+			tmpl:   DefaultPathTemplate,
+			params: Params{TrustDomain: "foo.bar", Namespace: "sandbox", ServiceAccount: "sleep"},
+			expID:  "spiffe://foo.bar/ns/sandbox/sa/sleep",
+		},
+		"custom template should render all placeholders": {
+			tmpl: "/cluster/{cluster-name}/ns/{namespace}/sa/{service-account}/app/{pod-label:app}",
+			params: Params{
+				TrustDomain:    "foo.bar",
+				Namespace:      "sandbox",
+				ServiceAccount: "sleep",
+				ClusterName:    "prod-1",
+				PodLabels:      map[string]string{"app": "sleeper", "other": "ignored"},
+			},
+			expID: "spiffe://foo.bar/cluster/prod-1/ns/sandbox/sa/sleep/app/sleeper",
+		},
+		"short template should render": {
+			tmpl:   "/k8s/{namespace}/{service-account}",
+			params: Params{TrustDomain: "foo.bar", Namespace: "sandbox", ServiceAccount: "sleep"},
+			expID:  "spiffe://foo.bar/k8s/sandbox/sleep",
+		},
+		"missing cluster name should error": {
+			tmpl:   "/cluster/{cluster-name}/ns/{namespace}/sa/{service-account}",
+			params: Params{TrustDomain: "foo.bar", Namespace: "sandbox", ServiceAccount: "sleep"},
+			expErr: true,
+		},
+		"missing pod label should error": {
+			tmpl:   "/ns/{namespace}/sa/{service-account}/{pod-label:app}",
+			params: Params{TrustDomain: "foo.bar", Namespace: "sandbox", ServiceAccount: "sleep"},
+			expErr: true,
+		},
+		"invalid trust domain should error": {
+			tmpl:   DefaultPathTemplate,
+			params: Params{TrustDomain: "Foo.Bar", Namespace: "sandbox", ServiceAccount: "sleep"},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tmpl, err := Parse(test.tmpl, []string{"app"})
+			assert.NoError(t, err)
+
+			id, err := tmpl.ID(test.params)
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+			if err == nil {
+				assert.Equal(t, test.expID, id.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package keyalgorithm defines the private key algorithms and sizes which may
+// be requested for SVIDs. The driver uses it to generate keys, and the
+// approver to enforce which keys are acceptable.
+package keyalgorithm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Algorithm is a private key algorithm.
+type Algorithm string
+
+const (
+	// ECDSA is the ECDSA algorithm, with a key size of 256, 384 or 521.
+	ECDSA Algorithm = "ECDSA"
+
+	// RSA is the RSA algorithm, with a key size of 2048, 3072 or 4096.
+	RSA Algorithm = "RSA"
+
+	// Ed25519 is the Ed25519 algorithm. Keys are always 256 bits.
+	Ed25519 Algorithm = "Ed25519"
+)
+
+var (
+	// sizes are the supported key sizes of each algorithm. The first is the
+	// default.
+	sizes = map[Algorithm][]int{
+		ECDSA:   {256, 384, 521},
+		RSA:     {2048, 3072, 4096},
+		Ed25519: {256},
+	}
+
+	// DefaultMinimumSizes allows every supported algorithm, at its default
+	// key size or larger.
+	DefaultMinimumSizes = map[Algorithm]int{
+		ECDSA:   256,
+		RSA:     2048,
+		Ed25519: 256,
+	}
+)
+
+// Parse parses the case-insensitive name of an algorithm. An empty name
+// returns ECDSA.
+func Parse(name string) (Algorithm, error) {
+	if len(name) == 0 {
+		return ECDSA, nil
+	}
+
+	for alg := range sizes {
+		if strings.EqualFold(name, string(alg)) {
+			return alg, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported key algorithm %q, must be one of %s, %s or %s", name, ECDSA, RSA, Ed25519)
+}
+
+// ParseMinimumSizes parses a map of algorithm names to minimum key sizes, as
+// given on the command line.
+func ParseMinimumSizes(in map[string]int) (map[Algorithm]int, error) {
+	out := make(map[Algorithm]int, len(in))
+	for name, size := range in {
+		if len(name) == 0 {
+			return nil, fmt.Errorf("empty key algorithm name")
+		}
+		alg, err := Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		out[alg] = size
+	}
+	return out, nil
+}
+
+// Generate generates a private key of the given algorithm and size. The size
+// is given as a string, as read from a volume attribute. An empty size uses
+// the algorithm's default.
+func Generate(alg Algorithm, size string) (crypto.Signer, error) {
+	supported, ok := sizes[alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported key algorithm %q", alg)
+	}
+
+	bits := supported[0]
+	if len(size) > 0 {
+		var err error
+		bits, err = strconv.Atoi(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key size %q: %w", size, err)
+		}
+
+		if !slices.Contains(supported, bits) {
+			return nil, fmt.Errorf("unsupported key size %d for key algorithm %s, must be one of %v", bits, alg, supported)
+		}
+	}
+
+	switch alg {
+	case RSA:
+		return rsa.GenerateKey(rand.Reader, bits)
+
+	case Ed25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+
+	default:
+		curve := elliptic.P256()
+		switch bits {
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	}
+}
+
+// Of returns the algorithm and size in bits of the given public key.
+func Of(pub crypto.PublicKey) (Algorithm, int, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		return ECDSA, k.Curve.Params().BitSize, nil
+
+	case *rsa.PublicKey:
+		return RSA, k.N.BitLen(), nil
+
+	case ed25519.PublicKey:
+		return Ed25519, 256, nil
+
+	default:
+		return "", 0, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
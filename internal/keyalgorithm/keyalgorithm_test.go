@@ -0,0 +1,125 @@
+/*
+Copyright 2026 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyalgorithm
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Generate(t *testing.T) {
+	tests := map[string]struct {
+		alg     string
+		size    string
+		expAlg  Algorithm
+		expSize int
+		expErr  bool
+	}{
+		"no algorithm or size should default to ECDSA P-256": {
+			expAlg:  ECDSA,
+			expSize: 256,
+		},
+		"ECDSA P-384 should be generated": {
+			alg:     "ecdsa",
+			size:    "384",
+			expAlg:  ECDSA,
+			expSize: 384,
+		},
+		"ECDSA P-521 should be generated": {
+			alg:     "ECDSA",
+			size:    "521",
+			expAlg:  ECDSA,
+			expSize: 521,
+		},
+		"RSA without size should default to 2048": {
+			alg:     "RSA",
+			expAlg:  RSA,
+			expSize: 2048,
+		},
+		"RSA 3072 should be generated": {
+			alg:     "rsa",
+			size:    "3072",
+			expAlg:  RSA,
+			expSize: 3072,
+		},
+		"Ed25519 should be generated": {
+			alg:     "ed25519",
+			expAlg:  Ed25519,
+			expSize: 256,
+		},
+		"unknown algorithm should error": {
+			alg:    "DSA",
+			expErr: true,
+		},
+		"unsupported ECDSA size should error": {
+			alg:    "ECDSA",
+			size:   "224",
+			expErr: true,
+		},
+		"weak RSA size should error": {
+			alg:    "RSA",
+			size:   "1024",
+			expErr: true,
+		},
+		"Ed25519 with another size should error": {
+			alg:    "Ed25519",
+			size:   "448",
+			expErr: true,
+		},
+		"non-numeric size should error": {
+			alg:    "RSA",
+			size:   "big",
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			key, err := func() (crypto.Signer, error) {
+				alg, err := Parse(test.alg)
+				if err != nil {
+					return nil, err
+				}
+				return Generate(alg, test.size)
+			}()
+			assert.Equalf(t, test.expErr, err != nil, "%v", err)
+			if err != nil {
+				return
+			}
+
+			alg, size, err := Of(key.Public())
+			require.NoError(t, err)
+			assert.Equal(t, test.expAlg, alg)
+			assert.Equal(t, test.expSize, size)
+		})
+	}
+}
+
+func Test_ParseMinimumSizes(t *testing.T) {
+	out, err := ParseMinimumSizes(map[string]int{"ecdsa": 384, "RSA": 3072})
+	require.NoError(t, err)
+	assert.Equal(t, map[Algorithm]int{ECDSA: 384, RSA: 3072}, out)
+
+	_, err = ParseMinimumSizes(map[string]int{"DSA": 1024})
+	assert.Error(t, err)
+
+	_, err = ParseMinimumSizes(map[string]int{"": 256})
+	assert.Error(t, err)
+}